@@ -9,9 +9,13 @@ import (
 	"github.com/gin-gonic/gin"
 
 	"github.com/tekenradar/researcher-backend/internal/config"
+	"github.com/tekenradar/researcher-backend/pkg/datasets"
 	"github.com/tekenradar/researcher-backend/pkg/db"
+	"github.com/tekenradar/researcher-backend/pkg/encryption"
 	"github.com/tekenradar/researcher-backend/pkg/grpc/clients"
+	"github.com/tekenradar/researcher-backend/pkg/http/utils"
 	v1 "github.com/tekenradar/researcher-backend/pkg/http/v1"
+	"github.com/tekenradar/researcher-backend/pkg/jwt"
 	"github.com/tekenradar/researcher-backend/pkg/runner"
 )
 
@@ -25,7 +29,29 @@ func healthCheckHandle(c *gin.Context) {
 
 func main() {
 	conf := config.InitConfig()
+	if err := jwt.InitKeys(conf.JWTConfig); err != nil {
+		logger.Error.Fatal(err)
+	}
+	if err := encryption.InitKeys(conf.ContactDataEncryption); err != nil {
+		logger.Error.Fatal(err)
+	}
+	if !encryption.Enabled() {
+		logger.Warning.Println("contact data encryption is not configured, contact data is stored in plain text")
+	}
 	researcherDBService := db.NewResearcherDBService(conf.ResearcherDBConfig)
+	if err := researcherDBService.InitAdminUsers(conf.ResearchAdminEmails); err != nil {
+		logger.Error.Fatal(err)
+	}
+	apiKeyHashes := []string{}
+	for _, k := range conf.APIKeys {
+		if len(k) > 0 {
+			apiKeyHashes = append(apiKeyHashes, utils.HashToken(k))
+		}
+	}
+	if err := researcherDBService.InitAPIKeys(apiKeyHashes, conf.APIKeyImportScopes); err != nil {
+		logger.Error.Fatal(err)
+	}
+	backfillContactNoteIDs(researcherDBService)
 
 	grpcClients := &clients.APIClients{}
 	studyClient, studyServiceClose := clients.ConnectToStudyService(conf.ServiceURLs.StudyService, conf.MaxMsgSize)
@@ -42,8 +68,13 @@ func main() {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
+	datasetStorage, err := datasets.NewStorage(conf.DatasetExport, researcherDBService)
+	if err != nil {
+		logger.Error.Fatal(err)
+	}
+
 	// Start runner
-	backgroundRunner := runner.NewRunner(researcherDBService, runnerCooldownInSeconds)
+	backgroundRunner := runner.NewRunner(researcherDBService, grpcClients, runnerCooldownInSeconds, conf.ContactExpiryWarning, conf.DatasetExport, datasetStorage)
 	backgroundRunner.Run()
 
 	// Start webserver
@@ -63,11 +94,12 @@ func main() {
 	v1APIHandlers := v1.NewHTTPHandler(
 		grpcClients,
 		researcherDBService,
+		datasetStorage,
 		conf.SAMLConfig,
 		conf.UseDummyLogin,
 		conf.LoginSuccessRedirectURL,
-		conf.APIKeys,
 	)
+	v1APIHandlers.AddWellKnownAPI(&router.RouterGroup)
 	v1APIHandlers.AddAuthAPI(v1Root)
 	v1APIHandlers.AddStudyEventsAPI(v1Root)
 	v1APIHandlers.AddStudyAccessAPI(v1Root)
@@ -76,3 +108,22 @@ func main() {
 	logger.Info.Printf("Tekenradar researcher backend started, listening on port %s", conf.Port)
 	logger.Error.Fatal(router.Run(":" + conf.Port))
 }
+
+// backfillContactNoteIDs gives notes added before note IDs were generated by the server an ID
+func backfillContactNoteIDs(researcherDBService *db.ResearcherDBService) {
+	studyInfos, err := researcherDBService.FindAllStudyInfos()
+	if err != nil {
+		logger.Error.Printf("note ID backfill: %v", err)
+		return
+	}
+	for _, info := range studyInfos {
+		count, err := researcherDBService.BackfillContactNoteIDs(info.Key)
+		if err != nil {
+			logger.Error.Printf("note ID backfill for %s failed after %d contacts: %v", info.Key, count, err)
+			continue
+		}
+		if count > 0 {
+			logger.Info.Printf("note IDs assigned for %d contacts of %s", count, info.Key)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"github.com/coneno/logger"
+
+	"github.com/tekenradar/researcher-backend/internal/config"
+	"github.com/tekenradar/researcher-backend/pkg/db"
+	"github.com/tekenradar/researcher-backend/pkg/encryption"
+)
+
+// Re-encrypts the contact data of all studies with the active key (CONTACT_DATA_ACTIVE_KEK_ID). Run it after
+// adding a new key and making it active, the previous keys must stay configured until it has finished.
+// Contacts stored in plain text are encrypted as well, note attachments only get their data keys wrapped again.
+// Payloads of study events in the inbox are re-encrypted too.
+func main() {
+	conf := config.InitConfig()
+	logger.SetLevel(conf.LogLevel)
+	if err := encryption.InitKeys(conf.ContactDataEncryption); err != nil {
+		logger.Error.Fatal(err)
+	}
+	if !encryption.Enabled() {
+		logger.Error.Fatal(encryption.ErrNotConfigured)
+	}
+	researcherDBService := db.NewResearcherDBService(conf.ResearcherDBConfig)
+
+	studyInfos, err := researcherDBService.FindAllStudyInfos()
+	if err != nil {
+		logger.Error.Fatal(err)
+	}
+	failed := false
+	for _, info := range studyInfos {
+		count, err := researcherDBService.ReencryptParticipantContacts(info.Key)
+		if err != nil {
+			logger.Error.Printf("%s: re-encryption failed after %d contacts: %v", info.Key, count, err)
+			failed = true
+			continue
+		}
+		logger.Info.Printf("%s: re-encrypted %d contacts with key '%s'", info.Key, count, encryption.ActiveKeyID())
+
+		count, err = researcherDBService.RewrapNoteAttachmentKeys(info.Key)
+		if err != nil {
+			logger.Error.Printf("%s: re-encryption of note attachments failed after %d files: %v", info.Key, count, err)
+			failed = true
+			continue
+		}
+		logger.Info.Printf("%s: re-encrypted the keys of %d note attachments", info.Key, count)
+	}
+
+	count, err := researcherDBService.ReencryptStudyEventPayloads()
+	if err != nil {
+		logger.Error.Printf("re-encryption of study event payloads failed after %d events: %v", count, err)
+		failed = true
+	} else {
+		logger.Info.Printf("re-encrypted the payloads of %d study events", count)
+	}
+	if failed {
+		logger.Error.Fatal("re-encryption incomplete, run the command again")
+	}
+}
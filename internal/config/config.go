@@ -19,7 +19,8 @@ const (
 	ENV_USE_DUMMY_LOGIN                = "USE_DUMMY_LOGIN"            // if true, test mode for auth is used
 	ENV_LOGIN_SUCCESS_REDIRECT_URL     = "LOGIN_SUCCESS_REDIRECT_URL" // address of the web-application
 
-	ENV_API_KEYS = "API_KEYS"
+	ENV_API_KEYS              = "API_KEYS"
+	ENV_API_KEY_IMPORT_SCOPES = "API_KEY_IMPORT_SCOPES" // comma separated, scopes of keys imported from API_KEYS
 
 	ENV_SAML_IDP_URL                   = "SAML_IDP_URL"
 	ENV_SAML_SERVICE_PROVIDER_ROOT_URL = "SAML_SERVICE_PROVIDER_ROOT_URL"
@@ -32,7 +33,20 @@ const (
 	ENV_SAML_ATTRIBUTE_FOR_TEKENRADAR_ACCESS = "SAML_ATTRIBUTE_FOR_TEKENRADAR_ACCESS"
 	ENV_RESEARCHADMIN_EMAILS                 = "RESEARCHADMIN_EMAILS"
 
-	ENV_JWT_TOKEN_KEY = "JWT_TOKEN_KEY"
+	ENV_JWT_TOKEN_KEY        = "JWT_TOKEN_KEY"
+	ENV_JWT_SIGNING_KEYS     = "JWT_SIGNING_KEYS" // comma separated list of kid=path/to/private-key.pem
+	ENV_JWT_ACTIVE_KEY_ID    = "JWT_ACTIVE_KEY_ID"
+	ENV_JWT_KEY_GRACE_PERIOD = "JWT_KEY_GRACE_PERIOD" // in seconds
+	ENV_JWT_KEYS_RETIRED_AT  = "JWT_KEYS_RETIRED_AT"  // comma separated list of kid=unix-timestamp
+
+	ENV_CONTACT_DATA_KEK_FILES            = "CONTACT_DATA_KEK_FILES" // comma separated list of kid=path/to/key, base64 encoded 256 bit keys
+	ENV_CONTACT_DATA_ACTIVE_KEK_ID        = "CONTACT_DATA_ACTIVE_KEK_ID"
+	ENV_CONTACT_DATA_BLIND_INDEX_KEY_FILE = "CONTACT_DATA_BLIND_INDEX_KEY_FILE"
+
+	ENV_DATASET_EXPORT_STORAGE            = "DATASET_EXPORT_STORAGE" // gridfs (default) or disk
+	ENV_DATASET_EXPORT_DIR                = "DATASET_EXPORT_DIR"
+	ENV_DATASET_EXPORT_RETENTION_HOURS    = "DATASET_EXPORT_RETENTION_HOURS"
+	ENV_RESEARCHER_APP_DATASET_EXPORT_URL = "RESEARCHER_APP_DATASET_EXPORT_URL" // link to an export job, with {{studyKey}}, {{datasetKey}} and {{jobID}} placeholders
 
 	ENV_RESEARCHER_DB_CONNECTION_STR    = "RESEARCHER_DB_CONNECTION_STR"
 	ENV_RESEARCHER_DB_USERNAME          = "RESEARCHER_DB_USERNAME"
@@ -44,6 +58,9 @@ const (
 	ENV_DB_MAX_POOL_SIZE     = "DB_MAX_POOL_SIZE"
 	ENV_DB_NAME_PREFIX       = "DB_DB_NAME_PREFIX"
 
+	ENV_CONTACT_EXPIRY_WARNING_DAYS = "CONTACT_EXPIRY_WARNING_DAYS"
+	ENV_RESEARCHER_APP_CONTACT_URL  = "RESEARCHER_APP_CONTACT_URL" // link to a contact, with {{studyKey}} and {{contactID}} placeholders
+
 	ENV_ADDR_STUDY_SERVICE        = "ADDR_STUDY_SERVICE"
 	ENV_ADDR_EMAIL_CLIENT_SERVICE = "ADDR_EMAIL_CLIENT_SERVICE"
 	ENV_GRPC_MAX_MSG_SIZE         = "GRPC_MAX_MSG_SIZE"
@@ -51,6 +68,11 @@ const (
 
 const (
 	DefaultGRPCMaxMsgSize = 4194304
+	DefaultJWTGracePeriod = 86400
+
+	DefaultContactExpiryWarningDays = 7
+
+	DefaultDatasetExportRetentionHours = 24
 )
 
 // Config is the structure that holds all global configuration data
@@ -58,12 +80,18 @@ type Config struct {
 	Port                    string
 	AllowOrigins            []string
 	APIKeys                 []string
+	APIKeyImportScopes      []string
 	LogLevel                logger.LogLevel
 	GinDebugMode            bool
 	SAMLConfig              *types.SAMLConfig `yaml:"saml_config"`
+	JWTConfig               types.JWTConfig
 	UseDummyLogin           bool
 	LoginSuccessRedirectURL string
+	ResearchAdminEmails     []string // used to create the initial admin users
 	ResearcherDBConfig      types.DBConfig
+	ContactExpiryWarning    types.ContactExpiryWarningConfig
+	ContactDataEncryption   types.ContactDataEncryptionConfig
+	DatasetExport           types.DatasetExportConfig
 	ServiceURLs             struct {
 		StudyService string `yaml:"study_service"`
 		EmailClient  string `yaml:"email_client_service"`
@@ -77,10 +105,15 @@ func InitConfig() Config {
 	conf.AllowOrigins = strings.Split(os.Getenv(ENV_CORS_ALLOW_ORIGINS), ",")
 
 	conf.APIKeys = strings.Split(os.Getenv(ENV_API_KEYS), ",")
+	conf.APIKeyImportScopes = getAPIKeyImportScopes()
 	conf.LogLevel = getLogLevel()
 	conf.GinDebugMode = os.Getenv(ENV_GIN_DEBUG_MODE) == "true"
 	conf.UseDummyLogin = os.Getenv(ENV_USE_DUMMY_LOGIN) == "true"
+	if conf.UseDummyLogin && !conf.GinDebugMode {
+		logger.Error.Fatalf("%s is only allowed together with %s=true", ENV_USE_DUMMY_LOGIN, ENV_GIN_DEBUG_MODE)
+	}
 	conf.LoginSuccessRedirectURL = os.Getenv(ENV_LOGIN_SUCCESS_REDIRECT_URL)
+	conf.ResearchAdminEmails = strings.Split(os.Getenv(ENV_RESEARCHADMIN_EMAILS), ",")
 
 	conf.ServiceURLs.StudyService = os.Getenv(ENV_ADDR_STUDY_SERVICE)
 	conf.ServiceURLs.EmailClient = os.Getenv(ENV_ADDR_EMAIL_CLIENT_SERVICE)
@@ -92,9 +125,16 @@ func InitConfig() Config {
 		MetaDataURL:     os.Getenv(ENV_SAML_IDP_METADATA_URL),
 		SessionCertPath: os.Getenv(ENV_SAML_SESSION_CERT_PATH),
 		SessionKeyPath:  os.Getenv(ENV_SAML_SESSION_KEY_PATH),
+
+		LoginFailedRedirectURL:       os.Getenv(ENV_SAML_LOGIN_FAILED_REDIRECT_URL),
+		AttributeForTekenradarAccess: os.Getenv(ENV_SAML_ATTRIBUTE_FOR_TEKENRADAR_ACCESS), // "attributeName" or "attributeName=requiredValue"
 	}
 
+	conf.JWTConfig = getJWTConfig()
 	conf.ResearcherDBConfig = getResearcherDBConfig()
+	conf.ContactExpiryWarning = getContactExpiryWarningConfig()
+	conf.ContactDataEncryption = getContactDataEncryptionConfig()
+	conf.DatasetExport = getDatasetExportConfig()
 
 	if len(conf.SAMLConfig.IDPUrl) > 0 {
 		conf.AllowOrigins = append(conf.AllowOrigins, conf.SAMLConfig.IDPUrl)
@@ -127,6 +167,125 @@ func getLogLevel() logger.LogLevel {
 	}
 }
 
+// getAPIKeyImportScopes defaults to all scopes, as the keys were accepted by every endpoint before scopes were introduced
+func getAPIKeyImportScopes() []string {
+	scopes := []string{}
+	for _, scope := range strings.Split(os.Getenv(ENV_API_KEY_IMPORT_SCOPES), ",") {
+		scope = strings.TrimSpace(scope)
+		if len(scope) < 1 {
+			continue
+		}
+		if !types.IsValidAPIKeyScope(scope) {
+			logger.Error.Fatalf("%s: unknown scope '%s'", ENV_API_KEY_IMPORT_SCOPES, scope)
+		}
+		scopes = append(scopes, scope)
+	}
+	if len(scopes) == 0 {
+		scopes = types.AllAPIKeyScopes
+	}
+	return scopes
+}
+
+func getJWTConfig() types.JWTConfig {
+	jwtConf := types.JWTConfig{
+		SigningKeyFiles: map[string]string{},
+		ActiveKeyID:     os.Getenv(ENV_JWT_ACTIVE_KEY_ID),
+		KeysRetiredAt:   map[string]int64{},
+		GracePeriod:     DefaultJWTGracePeriod,
+		HMACSecret:      os.Getenv(ENV_JWT_TOKEN_KEY),
+	}
+
+	for _, entry := range strings.Split(os.Getenv(ENV_JWT_SIGNING_KEYS), ",") {
+		entry = strings.TrimSpace(entry)
+		if len(entry) < 1 {
+			continue
+		}
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 {
+			logger.Error.Fatalf("%s: invalid entry '%s', expected kid=path", ENV_JWT_SIGNING_KEYS, entry)
+		}
+		jwtConf.SigningKeyFiles[parts[0]] = parts[1]
+	}
+
+	for _, entry := range strings.Split(os.Getenv(ENV_JWT_KEYS_RETIRED_AT), ",") {
+		entry = strings.TrimSpace(entry)
+		if len(entry) < 1 {
+			continue
+		}
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 {
+			logger.Error.Fatalf("%s: invalid entry '%s', expected kid=unix-timestamp", ENV_JWT_KEYS_RETIRED_AT, entry)
+		}
+		retiredAt, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil {
+			logger.Error.Fatalf("%s: invalid timestamp in '%s': %v", ENV_JWT_KEYS_RETIRED_AT, entry, err)
+		}
+		jwtConf.KeysRetiredAt[parts[0]] = retiredAt
+	}
+
+	gp, err := strconv.Atoi(os.Getenv(ENV_JWT_KEY_GRACE_PERIOD))
+	if err != nil {
+		logger.Debug.Printf("using default JWT key grace period: %d", DefaultJWTGracePeriod)
+	} else {
+		jwtConf.GracePeriod = gp
+	}
+	return jwtConf
+}
+
+func getContactExpiryWarningConfig() types.ContactExpiryWarningConfig {
+	warningConf := types.ContactExpiryWarningConfig{
+		WarningDays:        DefaultContactExpiryWarningDays,
+		ContactURLTemplate: os.Getenv(ENV_RESEARCHER_APP_CONTACT_URL),
+	}
+	days, err := strconv.Atoi(os.Getenv(ENV_CONTACT_EXPIRY_WARNING_DAYS))
+	if err != nil {
+		logger.Debug.Printf("using default contact expiry warning days: %d", DefaultContactExpiryWarningDays)
+	} else {
+		warningConf.WarningDays = days
+	}
+	return warningConf
+}
+
+func getContactDataEncryptionConfig() types.ContactDataEncryptionConfig {
+	encryptionConf := types.ContactDataEncryptionConfig{
+		KEKFiles:          map[string]string{},
+		ActiveKEKID:       os.Getenv(ENV_CONTACT_DATA_ACTIVE_KEK_ID),
+		BlindIndexKeyFile: os.Getenv(ENV_CONTACT_DATA_BLIND_INDEX_KEY_FILE),
+	}
+
+	for _, entry := range strings.Split(os.Getenv(ENV_CONTACT_DATA_KEK_FILES), ",") {
+		entry = strings.TrimSpace(entry)
+		if len(entry) < 1 {
+			continue
+		}
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 {
+			logger.Error.Fatalf("%s: invalid entry '%s', expected kid=path", ENV_CONTACT_DATA_KEK_FILES, entry)
+		}
+		encryptionConf.KEKFiles[parts[0]] = parts[1]
+	}
+	return encryptionConf
+}
+
+func getDatasetExportConfig() types.DatasetExportConfig {
+	exportConf := types.DatasetExportConfig{
+		Storage:        os.Getenv(ENV_DATASET_EXPORT_STORAGE),
+		Directory:      os.Getenv(ENV_DATASET_EXPORT_DIR),
+		RetentionHours: DefaultDatasetExportRetentionHours,
+		JobURLTemplate: os.Getenv(ENV_RESEARCHER_APP_DATASET_EXPORT_URL),
+	}
+	if len(exportConf.Storage) == 0 {
+		exportConf.Storage = types.DATASET_EXPORT_STORAGE_GRIDFS
+	}
+	hours, err := strconv.Atoi(os.Getenv(ENV_DATASET_EXPORT_RETENTION_HOURS))
+	if err != nil || hours < 1 {
+		logger.Debug.Printf("using default dataset export retention: %d hours", DefaultDatasetExportRetentionHours)
+	} else {
+		exportConf.RetentionHours = hours
+	}
+	return exportConf
+}
+
 func getResearcherDBConfig() types.DBConfig {
 	connStr := os.Getenv(ENV_RESEARCHER_DB_CONNECTION_STR)
 	username := os.Getenv(ENV_RESEARCHER_DB_USERNAME)
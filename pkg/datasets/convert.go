@@ -0,0 +1,161 @@
+package datasets
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	studyAPI "github.com/influenzanet/study-service/pkg/api"
+	"github.com/xitongsys/parquet-go/common"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+const (
+	convertedChunkSize  = 64 * 1024
+	parquetRowGroupSize = 32 * 1024 * 1024
+)
+
+type converterFunc func(r io.Reader, w io.Writer) error
+
+// convert runs the conversion in the background, the result is read as chunk stream. Errors of the source stream
+// or the conversion are returned by Recv.
+func convert(ctx context.Context, source ChunkStream, fn converterFunc) ChunkStream {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(fn(NewChunkReader(source), pw))
+	}()
+	go func() {
+		// stops the conversion if the reader is gone
+		<-ctx.Done()
+		pr.CloseWithError(ctx.Err())
+	}()
+	return &readerChunkStream{ctx: ctx, r: pr}
+}
+
+type readerChunkStream struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (s *readerChunkStream) Recv() (*studyAPI.Chunk, error) {
+	buf := make([]byte, convertedChunkSize)
+	n, err := s.r.Read(buf)
+	if n > 0 {
+		return &studyAPI.Chunk{Chunk: buf[:n]}, nil
+	}
+	if err == nil {
+		err = io.ErrNoProgress
+	}
+	if ctxErr := s.ctx.Err(); ctxErr != nil {
+		return nil, ctxErr
+	}
+	return nil, err
+}
+
+// jsonArrayToLines writes each element of the JSON array as one line
+func jsonArrayToLines(r io.Reader, w io.Writer) error {
+	dec := json.NewDecoder(r)
+	if tok, err := dec.Token(); err != nil {
+		return err
+	} else if tok != json.Delim('[') {
+		return fmt.Errorf("expected JSON array, got %v", tok)
+	}
+
+	bw := bufio.NewWriter(w)
+	var line bytes.Buffer
+	for dec.More() {
+		var elem json.RawMessage
+		if err := dec.Decode(&elem); err != nil {
+			return err
+		}
+		line.Reset()
+		if err := json.Compact(&line, elem); err != nil {
+			return err
+		}
+		line.WriteByte('\n')
+		if _, err := bw.Write(line.Bytes()); err != nil {
+			return err
+		}
+	}
+	if _, err := dec.Token(); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// csvToParquet converts a CSV file with header into a Parquet file, with all columns as optional strings
+// (empty values become null). Columns keep the names of the header, made unique if needed.
+func csvToParquet(r io.Reader, w io.Writer) error {
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if err != nil {
+		return err
+	}
+
+	// schema tags cannot hold every header (e.g. with commas), so the columns are defined with placeholder
+	// names and get the names written to the file afterwards
+	names := parquetColumnNames(header)
+	schema := make([]string, len(names))
+	for i := range names {
+		schema[i] = fmt.Sprintf("name=Column%d, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL", i)
+	}
+	pw, err := writer.NewCSVWriterFromWriter(schema, w, 1)
+	if err != nil {
+		return err
+	}
+	for i, name := range names {
+		pw.SchemaHandler.Infos[i+1].ExName = name
+	}
+	pw.SchemaHandler.CreateInExMap()
+	pw.RowGroupSize = parquetRowGroupSize
+
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		values := make([]*string, len(record))
+		for i := range record {
+			if len(record[i]) > 0 {
+				values[i] = &record[i]
+			}
+		}
+		if err := pw.WriteString(values); err != nil {
+			return err
+		}
+	}
+	return pw.WriteStop()
+}
+
+// parquetColumnNames returns the header names, with a suffix for names used before. Names are compared as the
+// identifiers readers like parquet-go derive from them, which ignore the case of the first letter and replace
+// special characters.
+func parquetColumnNames(header []string) []string {
+	names := make([]string, len(header))
+	used := map[string]bool{}
+	for i, col := range header {
+		base := col
+		if len(base) == 0 {
+			base = fmt.Sprintf("column_%d", i+1)
+		}
+		name := base
+		for n := 2; used[parquetColumnKey(name)]; n++ {
+			name = fmt.Sprintf("%s_%d", base, n)
+		}
+		used[parquetColumnKey(name)] = true
+		names[i] = name
+	}
+	return names
+}
+
+func parquetColumnKey(name string) string {
+	return strings.ToLower(common.StringToVariableName(name))
+}
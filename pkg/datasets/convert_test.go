@@ -0,0 +1,178 @@
+package datasets
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/xitongsys/parquet-go-source/buffer"
+	"github.com/xitongsys/parquet-go/common"
+	"github.com/xitongsys/parquet-go/reader"
+)
+
+func TestJSONArrayToLines(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{name: "empty array", input: `[]`, want: ""},
+		{
+			name:  "objects",
+			input: "[\n  {\"a\": 1, \"b\": \"x y\"},\n  {\"a\": 2, \"nested\": {\"c\": [1, 2]}}\n]",
+			want:  "{\"a\":1,\"b\":\"x y\"}\n{\"a\":2,\"nested\":{\"c\":[1,2]}}\n",
+		},
+		{name: "values with newlines", input: `[{"text": "line 1\nline 2"}]`, want: "{\"text\":\"line 1\\nline 2\"}\n"},
+		{name: "mixed values", input: `[1, "a", null]`, want: "1\n\"a\"\nnull\n"},
+		{name: "not an array", input: `{"a": 1}`, wantErr: true},
+		{name: "empty input", input: ``, wantErr: true},
+		{name: "truncated", input: `[{"a": 1}, {"a":`, wantErr: true},
+		{name: "unterminated array", input: `[{"a": 1}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := jsonArrayToLines(strings.NewReader(tt.input), &out)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("jsonArrayToLines() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && out.String() != tt.want {
+				t.Errorf("jsonArrayToLines() = %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestParquetColumnNames(t *testing.T) {
+	tests := []struct {
+		name   string
+		header []string
+		want   []string
+	}{
+		{
+			name:   "unique names are kept",
+			header: []string{"participantID", "T0.Q1-0", "a,b=c"},
+			want:   []string{"participantID", "T0.Q1-0", "a,b=c"},
+		},
+		{
+			name:   "duplicates",
+			header: []string{"Q1", "Q1", "Q1"},
+			want:   []string{"Q1", "Q1_2", "Q1_3"},
+		},
+		{
+			name:   "names differing in case",
+			header: []string{"Q1", "q1", "qA", "QA"},
+			want:   []string{"Q1", "q1_2", "qA", "QA_2"},
+		},
+		{
+			name:   "names with the same identifier",
+			header: []string{"T0.Q1", "T046Q1"},
+			want:   []string{"T0.Q1", "T046Q1_2"},
+		},
+		{
+			name:   "suffix already used",
+			header: []string{"Q1", "Q1_2", "Q1"},
+			want:   []string{"Q1", "Q1_2", "Q1_3"},
+		},
+		{
+			name:   "empty names",
+			header: []string{"", "column_1", ""},
+			want:   []string{"column_1", "column_1_2", "column_3"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parquetColumnNames(tt.header); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parquetColumnNames() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCSVToParquet(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		wantNames  []string
+		wantRows   int64
+		wantValues [][]interface{} // per column
+		wantErr    bool
+	}{
+		{
+			name:      "header only",
+			input:     "participantID,Q1\n",
+			wantNames: []string{"participantID", "Q1"},
+			wantRows:  0,
+		},
+		{
+			name:      "values and empty cells",
+			input:     "participantID,T0.Q1-0,\"a,b=c\"\np1,yes,1\np2,,2\n",
+			wantNames: []string{"participantID", "T0.Q1-0", "a,b=c"},
+			wantRows:  2,
+			wantValues: [][]interface{}{
+				{"p1", "p2"},
+				{"yes", nil},
+				{"1", "2"},
+			},
+		},
+		{
+			name:      "names differing in case",
+			input:     "q1,Q1\na,b\n",
+			wantNames: []string{"q1", "Q1_2"},
+			wantRows:  1,
+			wantValues: [][]interface{}{
+				{"a"},
+				{"b"},
+			},
+		},
+		{name: "empty input", input: "", wantErr: true},
+		{name: "rows with a different number of columns", input: "a,b\n1,2,3\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := csvToParquet(strings.NewReader(tt.input), &out)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("csvToParquet() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			file, err := buffer.NewBufferFile(out.Bytes())
+			if err != nil {
+				t.Fatal(err)
+			}
+			pr, err := reader.NewParquetColumnReader(file, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer pr.ReadStop()
+
+			names := []string{}
+			for i := 1; i < len(pr.SchemaHandler.Infos); i++ {
+				names = append(names, pr.SchemaHandler.GetExName(i))
+			}
+			if !reflect.DeepEqual(names, tt.wantNames) {
+				t.Errorf("columns = %v, want %v", names, tt.wantNames)
+			}
+			if got := pr.GetNumRows(); got != tt.wantRows {
+				t.Errorf("rows = %d, want %d", got, tt.wantRows)
+			}
+			for i, want := range tt.wantValues {
+				values, _, _, err := pr.ReadColumnByPath(common.PathToStr([]string{pr.SchemaHandler.GetRootExName(), tt.wantNames[i]}), tt.wantRows)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(values, want) {
+					t.Errorf("column %s = %v, want %v", tt.wantNames[i], values, want)
+				}
+			}
+		})
+	}
+}
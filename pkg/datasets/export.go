@@ -0,0 +1,139 @@
+package datasets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/influenzanet/go-utils/pkg/api_types"
+	studyAPI "github.com/influenzanet/study-service/pkg/api"
+	"github.com/tekenradar/researcher-backend/pkg/types"
+)
+
+const (
+	studyKey   = "tekenradar"
+	instanceID = "tekenradar"
+)
+
+var (
+	ErrLaterThanAllowed   = errors.New("requested data later than allowed")
+	ErrEarlierThanAllowed = errors.New("requested data earlier than allowed")
+)
+
+// ChunkStream is the stream of an export from the study service
+type ChunkStream interface {
+	Recv() (*studyAPI.Chunk, error)
+}
+
+// CheckAllowedRange returns an error if the query requests data outside of the dataset's time range
+func CheckAllowedRange(dataset types.DatasetInfo, query types.DatasetExportQuery) error {
+	if dataset.EndDate > 0 && query.Until > dataset.EndDate {
+		return ErrLaterThanAllowed
+	}
+	if dataset.StartDate > 0 && query.From < dataset.StartDate {
+		return ErrEarlierThanAllowed
+	}
+	return nil
+}
+
+// NewResponseExportQuery creates the request to the study service, on behalf of the user
+func NewResponseExportQuery(dataset types.DatasetInfo, query types.DatasetExportQuery, userID string) *studyAPI.ResponseExportQuery {
+	return &studyAPI.ResponseExportQuery{
+		Token: &api_types.TokenInfos{
+			Id:         userID,
+			InstanceId: instanceID,
+			Payload: map[string]string{
+				"roles": "SERVICE",
+			},
+		},
+		StudyKey:  studyKey,
+		SurveyKey: dataset.SurveyKey,
+		From:      query.From,
+		Until:     query.Until,
+		IncludeMeta: &studyAPI.ResponseExportQuery_IncludeMeta{
+			Position:       query.WithPositions,
+			InitTimes:      query.WithInitTimes,
+			DisplayedTimes: query.WithDisplayTimes,
+			ResponsedTimes: query.WithResponseTimes,
+		},
+		Separator:         query.Separator,
+		ShortQuestionKeys: query.ShortKeys,
+		ItemFilter: &studyAPI.ResponseExportQuery_ItemFilter{
+			Mode: studyAPI.ResponseExportQuery_ItemFilter_EXCLUDE,
+			Keys: dataset.ExcludeColumns,
+		},
+	}
+}
+
+// Open starts the export in the study service, it is cancelled with the context. Formats the study service cannot
+// produce are converted while reading the stream.
+func Open(ctx context.Context, client studyAPI.StudyServiceApiClient, req *studyAPI.ResponseExportQuery, format string) (ChunkStream, error) {
+	switch format {
+	case "", types.DATASET_FORMAT_WIDE_CSV:
+		return client.GetResponsesWideFormatCSV(ctx, req)
+	case types.DATASET_FORMAT_LONG_CSV:
+		return client.GetResponsesLongFormatCSV(ctx, req)
+	case types.DATASET_FORMAT_JSON_LINES:
+		stream, err := client.GetResponsesFlatJSON(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		return convert(ctx, stream, jsonArrayToLines), nil
+	case types.DATASET_FORMAT_PARQUET:
+		stream, err := client.GetResponsesWideFormatCSV(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		return convert(ctx, stream, csvToParquet), nil
+	default:
+		return nil, fmt.Errorf("unknown format '%s'", format)
+	}
+}
+
+func FileName(studyKey string, dataset types.DatasetInfo, format string) string {
+	switch format {
+	case types.DATASET_FORMAT_LONG_CSV:
+		return fmt.Sprintf("%s_%s_long.csv", studyKey, dataset.SurveyKey)
+	case types.DATASET_FORMAT_JSON_LINES:
+		return fmt.Sprintf("%s_%s.jsonl", studyKey, dataset.SurveyKey)
+	case types.DATASET_FORMAT_PARQUET:
+		return fmt.Sprintf("%s_%s.parquet", studyKey, dataset.SurveyKey)
+	default:
+		return fmt.Sprintf("%s_%s.csv", studyKey, dataset.SurveyKey)
+	}
+}
+
+func ContentType(format string) string {
+	switch format {
+	case types.DATASET_FORMAT_JSON_LINES:
+		return "application/x-ndjson"
+	case types.DATASET_FORMAT_PARQUET:
+		return "application/vnd.apache.parquet"
+	default:
+		return "text/csv"
+	}
+}
+
+type chunkReader struct {
+	stream ChunkStream
+	buf    []byte
+}
+
+// NewChunkReader reads the content of the stream
+func NewChunkReader(stream ChunkStream) io.Reader {
+	return &chunkReader{stream: stream}
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		chunk, err := r.stream.Recv()
+		if err != nil {
+			return 0, err
+		}
+		r.buf = chunk.Chunk
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
@@ -0,0 +1,87 @@
+package datasets
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/tekenradar/researcher-backend/pkg/db"
+	"github.com/tekenradar/researcher-backend/pkg/types"
+)
+
+// Storage keeps the results of export jobs, by job ID
+type Storage interface {
+	Save(jobID string, fileName string, content io.Reader) (size int64, err error)
+	Open(jobID string) (io.ReadCloser, error)
+	Delete(jobID string) error
+}
+
+func NewStorage(conf types.DatasetExportConfig, researcherDB *db.ResearcherDBService) (Storage, error) {
+	switch conf.Storage {
+	case "", types.DATASET_EXPORT_STORAGE_GRIDFS:
+		return gridFSStorage{researcherDB: researcherDB}, nil
+	case types.DATASET_EXPORT_STORAGE_DISK:
+		if len(conf.Directory) == 0 {
+			return nil, fmt.Errorf("directory for dataset exports is not configured")
+		}
+		if err := os.MkdirAll(conf.Directory, 0700); err != nil {
+			return nil, err
+		}
+		return diskStorage{directory: conf.Directory}, nil
+	default:
+		return nil, fmt.Errorf("unknown dataset export storage '%s'", conf.Storage)
+	}
+}
+
+type gridFSStorage struct {
+	researcherDB *db.ResearcherDBService
+}
+
+func (s gridFSStorage) Save(jobID string, fileName string, content io.Reader) (int64, error) {
+	return s.researcherDB.SaveDatasetExportFile(jobID, fileName, content)
+}
+
+func (s gridFSStorage) Open(jobID string) (io.ReadCloser, error) {
+	return s.researcherDB.OpenDatasetExportFile(jobID)
+}
+
+func (s gridFSStorage) Delete(jobID string) error {
+	return s.researcherDB.DeleteDatasetExportFile(jobID)
+}
+
+type diskStorage struct {
+	directory string
+}
+
+func (s diskStorage) path(jobID string) string {
+	return filepath.Join(s.directory, filepath.Base(jobID))
+}
+
+func (s diskStorage) Save(jobID string, fileName string, content io.Reader) (int64, error) {
+	f, err := os.OpenFile(s.path(jobID), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return 0, err
+	}
+	size, err := io.Copy(f, content)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(s.path(jobID))
+		return 0, err
+	}
+	return size, nil
+}
+
+func (s diskStorage) Open(jobID string) (io.ReadCloser, error) {
+	return os.Open(s.path(jobID))
+}
+
+func (s diskStorage) Delete(jobID string) error {
+	err := os.Remove(s.path(jobID))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
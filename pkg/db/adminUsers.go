@@ -0,0 +1,164 @@
+package db
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/coneno/logger"
+	"github.com/tekenradar/researcher-backend/pkg/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrLastAdminUser = errors.New("the last admin user cannot be removed")
+
+func normaliseEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// InitAdminUsers adds the given emails as admin users, if no admin user exists yet
+func (dbService *ResearcherDBService) InitAdminUsers(emails []string) error {
+	count, err := dbService.CountAdminUsers()
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	for _, email := range emails {
+		if len(normaliseEmail(email)) < 1 {
+			continue
+		}
+		_, err := dbService.AddAdminUser(types.AdminUser{
+			Email:   email,
+			AddedAt: time.Now().Unix(),
+			AddedBy: "bootstrap",
+		})
+		if err != nil {
+			return err
+		}
+		logger.Info.Printf("admin user '%s' added from config", email)
+	}
+	return nil
+}
+
+func (dbService *ResearcherDBService) AddAdminUser(adminUser types.AdminUser) (string, error) {
+	ctx, cancel := dbService.getContext()
+	defer cancel()
+
+	dbService.collectionRefAdminUsers().Indexes().CreateOne(ctx,
+		mongo.IndexModel{
+			Keys:    bson.D{{Key: "email", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		})
+
+	adminUser.Email = normaliseEmail(adminUser.Email)
+	res, err := dbService.collectionRefAdminUsers().InsertOne(ctx, adminUser)
+	if err != nil {
+		return "", err
+	}
+	id := res.InsertedID.(primitive.ObjectID)
+	return id.Hex(), err
+}
+
+func (dbService *ResearcherDBService) CountAdminUsers() (int64, error) {
+	ctx, cancel := dbService.getContext()
+	defer cancel()
+
+	return dbService.collectionRefAdminUsers().CountDocuments(ctx, bson.M{})
+}
+
+func (dbService *ResearcherDBService) IsAdminUser(email string) (bool, error) {
+	ctx, cancel := dbService.getContext()
+	defer cancel()
+
+	filter := bson.M{"email": normaliseEmail(email)}
+	count, err := dbService.collectionRefAdminUsers().CountDocuments(ctx, filter)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (dbService *ResearcherDBService) FindAllAdminUsers() (adminUsers []types.AdminUser, err error) {
+	ctx, cancel := dbService.getContext()
+	defer cancel()
+
+	filter := bson.M{}
+	batchSize := int32(32)
+	opts := options.FindOptions{
+		BatchSize: &batchSize,
+	}
+	cur, err := dbService.collectionRefAdminUsers().Find(ctx, filter, &opts)
+	if err != nil {
+		return adminUsers, err
+	}
+	defer cur.Close(ctx)
+
+	adminUsers = []types.AdminUser{}
+	for cur.Next(ctx) {
+		var result types.AdminUser
+		err := cur.Decode(&result)
+
+		if err != nil {
+			return adminUsers, err
+		}
+
+		adminUsers = append(adminUsers, result)
+	}
+	if err := cur.Err(); err != nil {
+		return adminUsers, err
+	}
+
+	return adminUsers, nil
+}
+
+// adminUserRemovalTimeout is the time after which the mark of an unfinished removal is ignored
+const adminUserRemovalTimeout = 60 // seconds
+
+// DeleteAdminUser removes the admin user, unless it is the last one (ErrLastAdminUser). Works without transactions:
+// the user is first marked as being removed, and only deleted if another admin user without such a mark is left.
+// Of two concurrent removals of the last two admin users, at least one sees the mark of the other and is rejected.
+func (dbService *ResearcherDBService) DeleteAdminUser(email string) (count int64, err error) {
+	ctx, cancel := dbService.getContext()
+	defer cancel()
+
+	filter := bson.M{"email": normaliseEmail(email)}
+	now := time.Now().Unix()
+	res, err := dbService.collectionRefAdminUsers().UpdateOne(ctx, filter, bson.M{"$set": bson.M{"removingAt": now}})
+	if err != nil || res.MatchedCount < 1 {
+		return 0, err
+	}
+	unmark := func() {
+		if _, err := dbService.collectionRefAdminUsers().UpdateOne(ctx, filter, bson.M{"$unset": bson.M{"removingAt": ""}}); err != nil {
+			logger.Error.Printf("failed to unmark admin user '%s': %v", email, err)
+		}
+	}
+
+	others, err := dbService.collectionRefAdminUsers().CountDocuments(ctx, bson.M{
+		"email": bson.M{"$ne": normaliseEmail(email)},
+		"$or": bson.A{
+			bson.M{"removingAt": bson.M{"$exists": false}},
+			bson.M{"removingAt": bson.M{"$lt": now - adminUserRemovalTimeout}},
+		},
+	})
+	if err != nil {
+		unmark()
+		return 0, err
+	}
+	if others < 1 {
+		unmark()
+		return 0, ErrLastAdminUser
+	}
+
+	delRes, err := dbService.collectionRefAdminUsers().DeleteOne(ctx, filter)
+	if err != nil {
+		unmark()
+		return 0, err
+	}
+	return delRes.DeletedCount, nil
+}
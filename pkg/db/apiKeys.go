@@ -0,0 +1,213 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/coneno/logger"
+	"github.com/tekenradar/researcher-backend/pkg/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	apiKeyLastUsedUpdateInterval = 60       // seconds
+	apiKeyImportActor            = "config" // createdBy of keys imported from the config
+)
+
+// InitAPIKeys imports the (hashed) keys from the config with the given scopes, if they are not in the DB yet.
+// Keys imported before get the given scopes as well, so changing them in the config takes effect on the next start.
+// Keys revoked once stay revoked. Imports and scope changes are recorded in the audit log.
+func (dbService *ResearcherDBService) InitAPIKeys(keyHashes []string, scopes []string) error {
+	for i, keyHash := range keyHashes {
+		existing, err := dbService.FindAPIKeyByHash(keyHash)
+		if err == nil {
+			if existing.CreatedBy != apiKeyImportActor || sameAPIKeyScopes(existing.Scopes, scopes) {
+				continue
+			}
+			if err := dbService.updateAPIKeyScopes(existing.ID, scopes); err != nil {
+				return err
+			}
+			logger.Info.Printf("scopes of API key %d imported from config changed from %v to %v", i+1, existing.Scopes, scopes)
+			dbService.writeAPIKeyImportAuditLog(types.AUDIT_ACTION_UPDATE_API_KEY_SCOPES, existing.ID, map[string]string{
+				"name":      existing.Name,
+				"oldScopes": strings.Join(existing.Scopes, ","),
+				"scopes":    strings.Join(scopes, ","),
+			})
+			continue
+		}
+		if err != mongo.ErrNoDocuments {
+			return err
+		}
+
+		apiKey, err := dbService.AddAPIKey(types.APIKey{
+			Name:      fmt.Sprintf("imported-from-config-%d", i+1),
+			KeyHash:   keyHash,
+			Scopes:    scopes,
+			CreatedAt: time.Now().Unix(),
+			CreatedBy: apiKeyImportActor,
+		})
+		if err != nil {
+			return err
+		}
+		logger.Info.Printf("API key %d imported from config with scopes %v", i+1, scopes)
+		dbService.writeAPIKeyImportAuditLog(types.AUDIT_ACTION_CREATE_API_KEY, apiKey.ID, map[string]string{
+			"name":   apiKey.Name,
+			"scopes": strings.Join(scopes, ","),
+		})
+	}
+	return nil
+}
+
+func (dbService *ResearcherDBService) writeAPIKeyImportAuditLog(action string, keyID primitive.ObjectID, params map[string]string) {
+	_, err := dbService.AddAuditLogEntry(types.AuditLogEntry{
+		Time:       time.Now().Unix(),
+		Actor:      apiKeyImportActor,
+		Action:     action,
+		TargetID:   keyID.Hex(),
+		Parameters: params,
+		Outcome:    types.AUDIT_OUTCOME_SUCCESS,
+	})
+	if err != nil {
+		logger.Error.Printf("failed to write audit log entry for imported API key: %v", err)
+	}
+}
+
+func sameAPIKeyScopes(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, scope := range b {
+		if !(types.APIKey{Scopes: a}).HasScope(scope) {
+			return false
+		}
+	}
+	return true
+}
+
+func (dbService *ResearcherDBService) AddAPIKey(apiKey types.APIKey) (types.APIKey, error) {
+	ctx, cancel := dbService.getContext()
+	defer cancel()
+
+	dbService.collectionRefAPIKeys().Indexes().CreateOne(ctx,
+		mongo.IndexModel{
+			Keys:    bson.D{{Key: "keyHash", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		})
+
+	res, err := dbService.collectionRefAPIKeys().InsertOne(ctx, apiKey)
+	if err != nil {
+		return apiKey, err
+	}
+	apiKey.ID = res.InsertedID.(primitive.ObjectID)
+	return apiKey, nil
+}
+
+func (dbService *ResearcherDBService) FindAPIKeyByHash(keyHash string) (types.APIKey, error) {
+	ctx, cancel := dbService.getContext()
+	defer cancel()
+
+	filter := bson.M{"keyHash": keyHash}
+
+	elem := types.APIKey{}
+	err := dbService.collectionRefAPIKeys().FindOne(ctx, filter).Decode(&elem)
+	return elem, err
+}
+
+func (dbService *ResearcherDBService) FindAPIKeyByID(id string) (types.APIKey, error) {
+	ctx, cancel := dbService.getContext()
+	defer cancel()
+
+	_id, _ := primitive.ObjectIDFromHex(id)
+	filter := bson.M{"_id": _id}
+
+	elem := types.APIKey{}
+	err := dbService.collectionRefAPIKeys().FindOne(ctx, filter).Decode(&elem)
+	return elem, err
+}
+
+func (dbService *ResearcherDBService) FindAllAPIKeys() (apiKeys []types.APIKey, err error) {
+	ctx, cancel := dbService.getContext()
+	defer cancel()
+
+	filter := bson.M{}
+	batchSize := int32(32)
+	opts := options.FindOptions{
+		BatchSize: &batchSize,
+	}
+	cur, err := dbService.collectionRefAPIKeys().Find(ctx, filter, &opts)
+	if err != nil {
+		return apiKeys, err
+	}
+	defer cur.Close(ctx)
+
+	apiKeys = []types.APIKey{}
+	for cur.Next(ctx) {
+		var result types.APIKey
+		err := cur.Decode(&result)
+
+		if err != nil {
+			return apiKeys, err
+		}
+
+		apiKeys = append(apiKeys, result)
+	}
+	if err := cur.Err(); err != nil {
+		return apiKeys, err
+	}
+
+	return apiKeys, nil
+}
+
+// UpdateAPIKeyLastUsed sets the last used timestamp, but writes at most once per minute for each key
+func (dbService *ResearcherDBService) UpdateAPIKeyLastUsed(id primitive.ObjectID) error {
+	ctx, cancel := dbService.getContext()
+	defer cancel()
+
+	now := time.Now().Unix()
+	filter := bson.M{
+		"_id":        id,
+		"lastUsedAt": bson.M{"$lt": now - apiKeyLastUsedUpdateInterval},
+	}
+	update := bson.M{"$set": bson.M{"lastUsedAt": now}}
+	_, err := dbService.collectionRefAPIKeys().UpdateOne(ctx, filter, update)
+	return err
+}
+
+func (dbService *ResearcherDBService) RevokeAPIKey(id string) (count int64, err error) {
+	ctx, cancel := dbService.getContext()
+	defer cancel()
+
+	_id, _ := primitive.ObjectIDFromHex(id)
+	filter := bson.M{"_id": _id, "revokedAt": 0}
+	update := bson.M{"$set": bson.M{"revokedAt": time.Now().Unix()}}
+	res, err := dbService.collectionRefAPIKeys().UpdateOne(ctx, filter, update)
+	if err != nil {
+		return 0, err
+	}
+	return res.ModifiedCount, nil
+}
+
+func (dbService *ResearcherDBService) UpdateAPIKeyExpiry(id string, expiresAt int64) error {
+	ctx, cancel := dbService.getContext()
+	defer cancel()
+
+	_id, _ := primitive.ObjectIDFromHex(id)
+	filter := bson.M{"_id": _id}
+	update := bson.M{"$set": bson.M{"expiresAt": expiresAt}}
+	_, err := dbService.collectionRefAPIKeys().UpdateOne(ctx, filter, update)
+	return err
+}
+
+func (dbService *ResearcherDBService) updateAPIKeyScopes(id primitive.ObjectID, scopes []string) error {
+	ctx, cancel := dbService.getContext()
+	defer cancel()
+
+	filter := bson.M{"_id": id}
+	update := bson.M{"$set": bson.M{"scopes": scopes}}
+	_, err := dbService.collectionRefAPIKeys().UpdateOne(ctx, filter, update)
+	return err
+}
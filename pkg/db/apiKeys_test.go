@@ -0,0 +1,26 @@
+package db
+
+import "testing"
+
+func TestSameAPIKeyScopes(t *testing.T) {
+	tests := []struct {
+		name string
+		a    []string
+		b    []string
+		want bool
+	}{
+		{name: "both empty", a: nil, b: []string{}, want: true},
+		{name: "same order", a: []string{"auth:init", "auth:renew"}, b: []string{"auth:init", "auth:renew"}, want: true},
+		{name: "other order", a: []string{"auth:renew", "auth:init"}, b: []string{"auth:init", "auth:renew"}, want: true},
+		{name: "scope added", a: []string{"auth:init"}, b: []string{"auth:init", "auth:renew"}, want: false},
+		{name: "scope removed", a: []string{"auth:init", "auth:renew"}, b: []string{"auth:init"}, want: false},
+		{name: "scope replaced", a: []string{"auth:init", "auth:renew"}, b: []string{"auth:init", "researcher-app"}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sameAPIKeyScopes(tt.a, tt.b); got != tt.want {
+				t.Errorf("sameAPIKeyScopes(%v, %v) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
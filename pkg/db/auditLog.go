@@ -0,0 +1,147 @@
+package db
+
+import (
+	"context"
+
+	"github.com/tekenradar/researcher-backend/pkg/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// The audit log is append-only: there are intentionally no methods to update or remove entries.
+
+func (dbService *ResearcherDBService) AddAuditLogEntry(entry types.AuditLogEntry) (string, error) {
+	ctx, cancel := dbService.getContext()
+	defer cancel()
+
+	dbService.collectionRefAuditLog().Indexes().CreateMany(ctx,
+		[]mongo.IndexModel{
+			{Keys: bson.D{{Key: "time", Value: -1}}},
+			{Keys: bson.D{{Key: "actor", Value: 1}, {Key: "time", Value: -1}}},
+			{Keys: bson.D{{Key: "studyKey", Value: 1}, {Key: "time", Value: -1}}},
+		})
+
+	res, err := dbService.collectionRefAuditLog().InsertOne(ctx, entry)
+	if err != nil {
+		return "", err
+	}
+	id := res.InsertedID.(primitive.ObjectID)
+	return id.Hex(), err
+}
+
+func auditLogQueryFilter(query types.AuditLogQuery) bson.M {
+	filter := bson.M{}
+	if len(query.Actor) > 0 {
+		filter["actor"] = query.Actor
+	}
+	if len(query.Action) > 0 {
+		filter["action"] = query.Action
+	}
+	if len(query.StudyKey) > 0 {
+		filter["studyKey"] = query.StudyKey
+	}
+	if len(query.TargetID) > 0 {
+		filter["targetID"] = query.TargetID
+	}
+	timeFilter := bson.M{}
+	if query.From > 0 {
+		timeFilter["$gte"] = query.From
+	}
+	if query.Until > 0 {
+		timeFilter["$lte"] = query.Until
+	}
+	if len(timeFilter) > 0 {
+		filter["time"] = timeFilter
+	}
+	return filter
+}
+
+// FindAuditLogEntries returns one page of the matching entries, newest first, and the cursor for the next page
+// (empty if there are no more entries)
+func (dbService *ResearcherDBService) FindAuditLogEntries(query types.AuditLogQuery) (entries []types.AuditLogEntry, nextCursor string, err error) {
+	ctx, cancel := dbService.getContext()
+	defer cancel()
+
+	filter := auditLogQueryFilter(query)
+	if len(query.Cursor) > 0 {
+		t, id, err := decodeTimeIDCursor(query.Cursor)
+		if err != nil {
+			return entries, "", err
+		}
+		filter = bson.M{"$and": bson.A{
+			filter,
+			bson.M{"$or": bson.A{
+				bson.M{"time": bson.M{"$lt": t}},
+				bson.M{"time": t, "_id": bson.M{"$lt": id}},
+			}},
+		}}
+	}
+
+	batchSize := int32(32)
+	opts := options.FindOptions{
+		BatchSize: &batchSize,
+		Sort:      bson.D{{Key: "time", Value: -1}, {Key: "_id", Value: -1}},
+	}
+	if query.Limit > 0 {
+		// one more to know if there is a next page
+		opts.SetLimit(query.Limit + 1)
+	}
+	cur, err := dbService.collectionRefAuditLog().Find(ctx, filter, &opts)
+	if err != nil {
+		return entries, "", err
+	}
+	defer cur.Close(ctx)
+
+	entries = []types.AuditLogEntry{}
+	for cur.Next(ctx) {
+		var result types.AuditLogEntry
+		err := cur.Decode(&result)
+
+		if err != nil {
+			return entries, "", err
+		}
+
+		entries = append(entries, result)
+	}
+	if err := cur.Err(); err != nil {
+		return entries, "", err
+	}
+
+	if query.Limit > 0 && int64(len(entries)) > query.Limit {
+		entries = entries[:query.Limit]
+		last := entries[len(entries)-1]
+		nextCursor = encodeTimeIDCursor(last.Time, last.ID)
+	}
+	return entries, nextCursor, nil
+}
+
+// ForEachAuditLogEntry calls fn for all matching entries, newest first, without loading them at once. Limit and
+// cursor of the query are ignored. Stops at the first error of fn.
+func (dbService *ResearcherDBService) ForEachAuditLogEntry(query types.AuditLogQuery, fn func(entry types.AuditLogEntry) error) error {
+	// no timeout, the entries are written to the client while reading
+	ctx := context.Background()
+
+	batchSize := int32(256)
+	opts := options.FindOptions{
+		BatchSize: &batchSize,
+		Sort:      bson.D{{Key: "time", Value: -1}, {Key: "_id", Value: -1}},
+	}
+	cur, err := dbService.collectionRefAuditLog().Find(ctx, auditLogQueryFilter(query), &opts)
+	if err != nil {
+		return err
+	}
+	defer cur.Close(ctx)
+
+	for cur.Next(ctx) {
+		var entry types.AuditLogEntry
+		if err := cur.Decode(&entry); err != nil {
+			return err
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+	return cur.Err()
+}
@@ -0,0 +1,113 @@
+package db
+
+import (
+	"errors"
+	"reflect"
+
+	"github.com/tekenradar/researcher-backend/pkg/encryption"
+	"github.com/tekenradar/researcher-backend/pkg/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsoncodec"
+	"go.mongodb.org/mongo-driver/bson/bsonrw"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+// contact data is stored encrypted if encryption is configured, together with blind indexes to search for exact
+// matches. Plain text documents (stored before encryption was enabled) can still be read.
+
+// plainContactData has the same fields, but not the custom codec
+type plainContactData types.ContactDetailsContactData
+
+type encryptedContactData struct {
+	Encrypted      encryption.EncryptedValue `bson:"encrypted"`
+	FirstNameIndex string                    `bson:"firstNameIndex,omitempty"`
+	LastNameIndex  string                    `bson:"lastNameIndex,omitempty"`
+	EmailIndex     string                    `bson:"emailIndex,omitempty"`
+}
+
+var (
+	tContactData          = reflect.TypeOf(types.ContactDetailsContactData{})
+	tPlainContactData     = reflect.TypeOf(plainContactData{})
+	tEncryptedContactData = reflect.TypeOf(encryptedContactData{})
+)
+
+type contactDataCodec struct{}
+
+// registry is used by the client, and to decode raw documents
+var registry = newRegistry()
+
+func newRegistry() *bsoncodec.Registry {
+	codec := contactDataCodec{}
+	payloadCodec := studyEventPayloadCodec{}
+	return bson.NewRegistryBuilder().
+		RegisterTypeEncoder(tContactData, codec).
+		RegisterTypeDecoder(tContactData, codec).
+		RegisterTypeEncoder(tStudyEventPayload, payloadCodec).
+		RegisterTypeDecoder(tStudyEventPayload, payloadCodec).
+		Build()
+}
+
+func (contactDataCodec) EncodeValue(ec bsoncodec.EncodeContext, vw bsonrw.ValueWriter, val reflect.Value) error {
+	if !val.IsValid() || val.Type() != tContactData {
+		return bsoncodec.ValueEncoderError{Name: "contactDataCodec.EncodeValue", Types: []reflect.Type{tContactData}, Received: val}
+	}
+	data := val.Interface().(types.ContactDetailsContactData)
+
+	if !encryption.Enabled() {
+		enc, err := ec.LookupEncoder(tPlainContactData)
+		if err != nil {
+			return err
+		}
+		return enc.EncodeValue(ec, vw, reflect.ValueOf(plainContactData(data)))
+	}
+
+	plaintext, err := bson.Marshal(plainContactData(data))
+	if err != nil {
+		return err
+	}
+	encrypted, err := encryption.Encrypt(plaintext)
+	if err != nil {
+		return err
+	}
+	enc, err := ec.LookupEncoder(tEncryptedContactData)
+	if err != nil {
+		return err
+	}
+	return enc.EncodeValue(ec, vw, reflect.ValueOf(encryptedContactData{
+		Encrypted:      encrypted,
+		FirstNameIndex: encryption.BlindIndex(data.FirstName),
+		LastNameIndex:  encryption.BlindIndex(data.LastName),
+		EmailIndex:     encryption.BlindIndex(data.Email),
+	}))
+}
+
+func (contactDataCodec) DecodeValue(dc bsoncodec.DecodeContext, vr bsonrw.ValueReader, val reflect.Value) error {
+	if !val.CanSet() || val.Type() != tContactData {
+		return bsoncodec.ValueDecoderError{Name: "contactDataCodec.DecodeValue", Types: []reflect.Type{tContactData}, Received: val}
+	}
+	if vr.Type() != bsontype.EmbeddedDocument {
+		return errors.New("contact data must be a document, got " + vr.Type().String())
+	}
+	raw, err := bsonrw.Copier{}.CopyDocumentToBytes(vr)
+	if err != nil {
+		return err
+	}
+
+	if encryptedRaw, err := bson.Raw(raw).LookupErr("encrypted"); err == nil {
+		var encrypted encryption.EncryptedValue
+		if err := encryptedRaw.Unmarshal(&encrypted); err != nil {
+			return err
+		}
+		raw, err = encryption.Decrypt(encrypted)
+		if err != nil {
+			return err
+		}
+	}
+
+	var data plainContactData
+	if err := bson.Unmarshal(raw, &data); err != nil {
+		return err
+	}
+	val.Set(reflect.ValueOf(types.ContactDetailsContactData(data)))
+	return nil
+}
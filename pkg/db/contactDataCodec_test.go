@@ -0,0 +1,132 @@
+package db
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/tekenradar/researcher-backend/pkg/encryption"
+	"github.com/tekenradar/researcher-backend/pkg/types"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// initTestEncryption enables encryption with new keys until the end of the test
+func initTestEncryption(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	writeKey := func(name string) string {
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(base64.StdEncoding.EncodeToString(key)), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	if err := encryption.InitKeys(types.ContactDataEncryptionConfig{
+		KEKFiles:          map[string]string{"kek-1": writeKey("kek-1")},
+		ActiveKEKID:       "kek-1",
+		BlindIndexKeyFile: writeKey("blind-index"),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = encryption.InitKeys(types.ContactDataEncryptionConfig{}) })
+}
+
+type testContactDocument struct {
+	ContactData *types.ContactDetailsContactData `bson:"contactData"`
+}
+
+func TestContactDataCodec(t *testing.T) {
+	data := &types.ContactDetailsContactData{
+		FirstName: "Jane",
+		LastName:  "Doe",
+		Birthday:  631152000,
+		Email:     "Jane@Example.org",
+		Phone:     "0612345678",
+		Gender:    "female",
+		GP:        &types.GPInfos{Office: "Practice", Name: "Dr. Smith", Phone: "0101234567"},
+	}
+
+	tests := []struct {
+		name          string
+		encrypted     bool
+		wantEncrypted bool
+	}{
+		{name: "plain", encrypted: false},
+		{name: "encrypted", encrypted: true, wantEncrypted: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.encrypted {
+				initTestEncryption(t)
+			}
+
+			raw, err := bson.MarshalWithRegistry(registry, testContactDocument{ContactData: data})
+			if err != nil {
+				t.Fatal(err)
+			}
+			stored := bson.Raw(raw).Lookup("contactData").Document()
+			_, hasEncrypted := stored.Lookup("encrypted").DocumentOK()
+			if hasEncrypted != tt.wantEncrypted {
+				t.Fatalf("stored document: %s", stored)
+			}
+			if tt.wantEncrypted {
+				if _, err := stored.LookupErr("email"); err == nil {
+					t.Errorf("plain text stored next to the encrypted value: %s", stored)
+				}
+				if got := stored.Lookup("emailIndex").StringValue(); got != encryption.BlindIndex("jane@example.org") {
+					t.Errorf("unexpected email index: %s", got)
+				}
+			}
+
+			var decoded testContactDocument
+			if err := bson.UnmarshalWithRegistry(registry, raw, &decoded); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(decoded.ContactData, data) {
+				t.Errorf("decoded %+v, want %+v", decoded.ContactData, data)
+			}
+		})
+	}
+
+	t.Run("plain documents are read with encryption enabled", func(t *testing.T) {
+		raw, err := bson.MarshalWithRegistry(registry, testContactDocument{ContactData: data})
+		if err != nil {
+			t.Fatal(err)
+		}
+		initTestEncryption(t)
+
+		var decoded testContactDocument
+		if err := bson.UnmarshalWithRegistry(registry, raw, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(decoded.ContactData, data) {
+			t.Errorf("decoded %+v, want %+v", decoded.ContactData, data)
+		}
+	})
+
+	t.Run("removed contact data", func(t *testing.T) {
+		initTestEncryption(t)
+		raw, err := bson.MarshalWithRegistry(registry, testContactDocument{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := bson.Raw(raw).Lookup("contactData").Type; got != bson.TypeNull {
+			t.Errorf("expected null, got %s", got)
+		}
+		var decoded testContactDocument
+		if err := bson.UnmarshalWithRegistry(registry, raw, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		if decoded.ContactData != nil {
+			t.Errorf("expected no contact data, got %+v", decoded.ContactData)
+		}
+	})
+}
@@ -0,0 +1,248 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	"github.com/tekenradar/researcher-backend/pkg/encryption"
+	"github.com/tekenradar/researcher-backend/pkg/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// attachments of contact notes are stored in one GridFS bucket per substudy. If encryption is configured, the
+// file content is encrypted and the wrapped data key is stored in the file's metadata.
+
+type noteAttachmentMetadata struct {
+	ContactID   string `bson:"contactID"`
+	NoteID      string `bson:"noteID"`
+	ContentType string `bson:"contentType"`
+	KeyID       string `bson:"keyID,omitempty"`
+	WrappedKey  []byte `bson:"wrappedKey,omitempty"`
+}
+
+type noteAttachmentFile struct {
+	ID       primitive.ObjectID     `bson:"_id"`
+	Metadata noteAttachmentMetadata `bson:"metadata"`
+}
+
+func (dbService *ResearcherDBService) noteAttachmentsBucket(substudyKey string) (*gridfs.Bucket, error) {
+	bucket, err := gridfs.NewBucket(
+		dbService.DBClient.Database(dbService.DBNamePrefix+"researcherDB"),
+		options.GridFSBucket().SetName("contact-note-attachments-"+substudyKey),
+	)
+	if err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(time.Duration(dbService.timeout) * time.Second)
+	if err := bucket.SetReadDeadline(deadline); err != nil {
+		return nil, err
+	}
+	if err := bucket.SetWriteDeadline(deadline); err != nil {
+		return nil, err
+	}
+	return bucket, nil
+}
+
+// AddAttachmentToParticipantContactNote stores the file and adds it to the note. Returns mongo.ErrNoDocuments if
+// the note does not exist.
+func (dbService *ResearcherDBService) AddAttachmentToParticipantContactNote(substudyKey string, contactID string, noteID string, attachment types.ContactNoteAttachment, content []byte) (types.ContactNoteAttachment, error) {
+	bucket, err := dbService.noteAttachmentsBucket(substudyKey)
+	if err != nil {
+		return attachment, err
+	}
+
+	metadata := noteAttachmentMetadata{
+		ContactID:   contactID,
+		NoteID:      noteID,
+		ContentType: attachment.ContentType,
+	}
+	if encryption.Enabled() {
+		encrypted, err := encryption.Encrypt(content)
+		if err != nil {
+			return attachment, err
+		}
+		content = encrypted.Ciphertext
+		metadata.KeyID = encrypted.KeyID
+		metadata.WrappedKey = encrypted.WrappedKey
+	}
+
+	fileID := primitive.NewObjectID()
+	opts := options.GridFSUpload().SetMetadata(metadata)
+	if err := bucket.UploadFromStreamWithID(fileID, attachment.FileName, bytes.NewReader(content), opts); err != nil {
+		return attachment, err
+	}
+	attachment.ID = fileID.Hex()
+
+	ctx, cancel := dbService.getContext()
+	defer cancel()
+
+	_id, _ := primitive.ObjectIDFromHex(contactID)
+	filter := bson.M{"_id": _id, "notes.id": noteID}
+	update := bson.M{"$push": bson.M{"notes.$.attachments": attachment}}
+	res, err := dbService.collectionRefParticipantContacts(substudyKey).UpdateOne(ctx, filter, update)
+	if err == nil && res.MatchedCount == 0 {
+		err = mongo.ErrNoDocuments
+	}
+	if err != nil {
+		bucket.Delete(fileID)
+		return attachment, err
+	}
+	return attachment, nil
+}
+
+// FindNoteAttachmentContent returns the (decrypted) content of an attachment
+func (dbService *ResearcherDBService) FindNoteAttachmentContent(substudyKey string, attachmentID string) ([]byte, error) {
+	bucket, err := dbService.noteAttachmentsBucket(substudyKey)
+	if err != nil {
+		return nil, err
+	}
+	fileID, err := primitive.ObjectIDFromHex(attachmentID)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := dbService.getContext()
+	defer cancel()
+
+	var file noteAttachmentFile
+	if err := bucket.GetFilesCollection().FindOne(ctx, bson.M{"_id": fileID}).Decode(&file); err != nil {
+		return nil, err
+	}
+
+	var content bytes.Buffer
+	if _, err := bucket.DownloadToStream(fileID, &content); err != nil {
+		return nil, err
+	}
+	if len(file.Metadata.KeyID) == 0 {
+		return content.Bytes(), nil
+	}
+	return encryption.Decrypt(encryption.EncryptedValue{
+		KeyID:      file.Metadata.KeyID,
+		WrappedKey: file.Metadata.WrappedKey,
+		Ciphertext: content.Bytes(),
+	})
+}
+
+// RemoveAttachmentFromParticipantContactNote removes the attachment from the note and deletes the file
+func (dbService *ResearcherDBService) RemoveAttachmentFromParticipantContactNote(substudyKey string, contactID string, noteID string, attachmentID string) error {
+	ctx, cancel := dbService.getContext()
+	defer cancel()
+
+	_id, _ := primitive.ObjectIDFromHex(contactID)
+	filter := bson.M{"_id": _id, "notes.id": noteID}
+	update := bson.M{"$pull": bson.M{"notes.$.attachments": bson.M{"id": attachmentID}}}
+	if _, err := dbService.collectionRefParticipantContacts(substudyKey).UpdateOne(ctx, filter, update); err != nil {
+		return err
+	}
+	return dbService.DeleteNoteAttachmentFiles(substudyKey, []string{attachmentID})
+}
+
+// DeleteNoteAttachmentFiles deletes the stored files, already missing files are ignored
+func (dbService *ResearcherDBService) DeleteNoteAttachmentFiles(substudyKey string, attachmentIDs []string) error {
+	if len(attachmentIDs) == 0 {
+		return nil
+	}
+	bucket, err := dbService.noteAttachmentsBucket(substudyKey)
+	if err != nil {
+		return err
+	}
+	for _, id := range attachmentIDs {
+		fileID, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			continue
+		}
+		if err := bucket.Delete(fileID); err != nil && err != gridfs.ErrFileNotFound {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteNoteAttachmentsOfContacts deletes the attachment files of all contacts matching the filter, call before
+// deleting the contacts
+func (dbService *ResearcherDBService) deleteNoteAttachmentsOfContacts(ctx context.Context, substudyKey string, filter interface{}) error {
+	filter = bson.M{"$and": bson.A{
+		filter,
+		bson.M{"notes.attachments.0": bson.M{"$exists": true}},
+	}}
+	opts := options.Find().SetProjection(bson.M{"notes.attachments.id": 1})
+	cur, err := dbService.collectionRefParticipantContacts(substudyKey).Find(ctx, filter, opts)
+	if err != nil {
+		return err
+	}
+	defer cur.Close(ctx)
+
+	attachmentIDs := []string{}
+	for cur.Next(ctx) {
+		var pc types.ParticipantContact
+		if err := cur.Decode(&pc); err != nil {
+			return err
+		}
+		for _, note := range pc.Notes {
+			for _, attachment := range note.Attachments {
+				attachmentIDs = append(attachmentIDs, attachment.ID)
+			}
+		}
+	}
+	if err := cur.Err(); err != nil {
+		return err
+	}
+	return dbService.DeleteNoteAttachmentFiles(substudyKey, attachmentIDs)
+}
+
+// RewrapNoteAttachmentKeys wraps the data keys of all encrypted attachments with the active key. Returns the
+// number of updated files. Attachments stored in plain text stay unencrypted.
+func (dbService *ResearcherDBService) RewrapNoteAttachmentKeys(substudyKey string) (int, error) {
+	if !encryption.Enabled() {
+		return 0, encryption.ErrNotConfigured
+	}
+	bucket, err := dbService.noteAttachmentsBucket(substudyKey)
+	if err != nil {
+		return 0, err
+	}
+	files := bucket.GetFilesCollection()
+
+	// may run longer than the usual timeout, only single operations are limited
+	ctx := context.Background()
+
+	filter := bson.M{
+		"metadata.keyID": bson.M{"$exists": true, "$ne": encryption.ActiveKeyID()},
+	}
+	cur, err := files.Find(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	defer cur.Close(ctx)
+
+	count := 0
+	for cur.Next(ctx) {
+		var file noteAttachmentFile
+		if err := cur.Decode(&file); err != nil {
+			return count, err
+		}
+		rewrapped, err := encryption.Rewrap(encryption.EncryptedValue{
+			KeyID:      file.Metadata.KeyID,
+			WrappedKey: file.Metadata.WrappedKey,
+		})
+		if err != nil {
+			return count, err
+		}
+
+		updateCtx, cancel := dbService.getContext()
+		_, err = files.UpdateOne(updateCtx, bson.M{"_id": file.ID}, bson.M{"$set": bson.M{
+			"metadata.keyID":      rewrapped.KeyID,
+			"metadata.wrappedKey": rewrapped.WrappedKey,
+		}})
+		cancel()
+		if err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, cur.Err()
+}
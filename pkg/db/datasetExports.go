@@ -0,0 +1,221 @@
+package db
+
+import (
+	"io"
+	"time"
+
+	"github.com/tekenradar/researcher-backend/pkg/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (dbService *ResearcherDBService) AddDatasetExportJob(job types.DatasetExportJob) (types.DatasetExportJob, error) {
+	ctx, cancel := dbService.getContext()
+	defer cancel()
+
+	dbService.collectionRefDatasetExportJobs().Indexes().CreateOne(ctx,
+		mongo.IndexModel{
+			Keys: bson.D{{Key: "status", Value: 1}, {Key: "createdAt", Value: 1}},
+		})
+
+	res, err := dbService.collectionRefDatasetExportJobs().InsertOne(ctx, job)
+	if err != nil {
+		return job, err
+	}
+	job.ID = res.InsertedID.(primitive.ObjectID)
+	return job, nil
+}
+
+func (dbService *ResearcherDBService) FindDatasetExportJob(studyKey string, jobID string) (types.DatasetExportJob, error) {
+	ctx, cancel := dbService.getContext()
+	defer cancel()
+
+	_id, err := primitive.ObjectIDFromHex(jobID)
+	if err != nil {
+		return types.DatasetExportJob{}, mongo.ErrNoDocuments
+	}
+	filter := bson.M{"_id": _id, "studyKey": studyKey}
+
+	elem := types.DatasetExportJob{}
+	err = dbService.collectionRefDatasetExportJobs().FindOne(ctx, filter).Decode(&elem)
+	return elem, err
+}
+
+// FindDatasetExportJobs returns the jobs of a dataset, newest first
+func (dbService *ResearcherDBService) FindDatasetExportJobs(studyKey string, datasetKey string) (jobs []types.DatasetExportJob, err error) {
+	ctx, cancel := dbService.getContext()
+	defer cancel()
+
+	filter := bson.M{"studyKey": studyKey, "datasetKey": datasetKey}
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}})
+
+	cur, err := dbService.collectionRefDatasetExportJobs().Find(ctx, filter, opts)
+	if err != nil {
+		return jobs, err
+	}
+	defer cur.Close(ctx)
+
+	jobs = []types.DatasetExportJob{}
+	if err := cur.All(ctx, &jobs); err != nil {
+		return jobs, err
+	}
+	return jobs, nil
+}
+
+// ClaimNextDatasetExportJob locks the oldest pending job, or a running job whose lock expired (e.g. after a restart)
+func (dbService *ResearcherDBService) ClaimNextDatasetExportJob(lockFor time.Duration) (types.DatasetExportJob, error) {
+	ctx, cancel := dbService.getContext()
+	defer cancel()
+
+	now := time.Now().Unix()
+	filter := bson.M{"$or": bson.A{
+		bson.M{"status": types.DATASET_EXPORT_JOB_STATUS_PENDING},
+		bson.M{"status": types.DATASET_EXPORT_JOB_STATUS_RUNNING, "lockedUntil": bson.M{"$lt": now}},
+	}}
+	update := bson.M{
+		"$set": bson.M{
+			"status":      types.DATASET_EXPORT_JOB_STATUS_RUNNING,
+			"startedAt":   now,
+			"lockedUntil": time.Now().Add(lockFor).Unix(),
+		},
+		"$inc": bson.M{"attempts": 1},
+	}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "createdAt", Value: 1}}).
+		SetReturnDocument(options.After)
+
+	elem := types.DatasetExportJob{}
+	err := dbService.collectionRefDatasetExportJobs().FindOneAndUpdate(ctx, filter, update, opts).Decode(&elem)
+	return elem, err
+}
+
+func (dbService *ResearcherDBService) MarkDatasetExportJobDone(job types.DatasetExportJob) error {
+	ctx, cancel := dbService.getContext()
+	defer cancel()
+
+	update := bson.M{
+		"$set": bson.M{
+			"status":      types.DATASET_EXPORT_JOB_STATUS_DONE,
+			"finishedAt":  time.Now().Unix(),
+			"fileName":    job.FileName,
+			"contentType": job.ContentType,
+			"fileSize":    job.FileSize,
+			"expiresAt":   job.ExpiresAt,
+		},
+		"$unset": bson.M{"lockedUntil": "", "error": ""},
+	}
+	_, err := dbService.collectionRefDatasetExportJobs().UpdateOne(ctx, bson.M{"_id": job.ID}, update)
+	return err
+}
+
+// MarkDatasetExportJobFailed records the error, the job is kept until expiresAt
+func (dbService *ResearcherDBService) MarkDatasetExportJobFailed(id primitive.ObjectID, errMsg string, expiresAt int64) error {
+	ctx, cancel := dbService.getContext()
+	defer cancel()
+
+	update := bson.M{
+		"$set": bson.M{
+			"status":     types.DATASET_EXPORT_JOB_STATUS_FAILED,
+			"finishedAt": time.Now().Unix(),
+			"error":      errMsg,
+			"expiresAt":  expiresAt,
+		},
+		"$unset": bson.M{"lockedUntil": ""},
+	}
+	_, err := dbService.collectionRefDatasetExportJobs().UpdateOne(ctx, bson.M{"_id": id}, update)
+	return err
+}
+
+// FindExpiredDatasetExportJobs returns finished jobs whose results should be deleted
+func (dbService *ResearcherDBService) FindExpiredDatasetExportJobs(ref int64) (jobs []types.DatasetExportJob, err error) {
+	ctx, cancel := dbService.getContext()
+	defer cancel()
+
+	filter := bson.M{
+		"status":    bson.M{"$in": bson.A{types.DATASET_EXPORT_JOB_STATUS_DONE, types.DATASET_EXPORT_JOB_STATUS_FAILED}},
+		"expiresAt": bson.M{"$lt": ref},
+	}
+	cur, err := dbService.collectionRefDatasetExportJobs().Find(ctx, filter)
+	if err != nil {
+		return jobs, err
+	}
+	defer cur.Close(ctx)
+
+	jobs = []types.DatasetExportJob{}
+	if err := cur.All(ctx, &jobs); err != nil {
+		return jobs, err
+	}
+	return jobs, nil
+}
+
+func (dbService *ResearcherDBService) DeleteDatasetExportJob(id primitive.ObjectID) error {
+	ctx, cancel := dbService.getContext()
+	defer cancel()
+
+	_, err := dbService.collectionRefDatasetExportJobs().DeleteOne(ctx, bson.M{"_id": id})
+	return err
+}
+
+// results of export jobs stored in GridFS, without timeout as exports can be large
+func (dbService *ResearcherDBService) datasetExportsBucket() (*gridfs.Bucket, error) {
+	return gridfs.NewBucket(
+		dbService.DBClient.Database(dbService.DBNamePrefix+"researcherDB"),
+		options.GridFSBucket().SetName("dataset-exports"),
+	)
+}
+
+func (dbService *ResearcherDBService) SaveDatasetExportFile(jobID string, fileName string, content io.Reader) (int64, error) {
+	bucket, err := dbService.datasetExportsBucket()
+	if err != nil {
+		return 0, err
+	}
+	fileID, err := primitive.ObjectIDFromHex(jobID)
+	if err != nil {
+		return 0, err
+	}
+	// a previous attempt may have left a partial file
+	if err := bucket.Delete(fileID); err != nil && err != gridfs.ErrFileNotFound {
+		return 0, err
+	}
+
+	stream, err := bucket.OpenUploadStreamWithID(fileID, fileName)
+	if err != nil {
+		return 0, err
+	}
+	size, err := io.Copy(stream, content)
+	if err != nil {
+		stream.Abort()
+		return 0, err
+	}
+	return size, stream.Close()
+}
+
+func (dbService *ResearcherDBService) OpenDatasetExportFile(jobID string) (io.ReadCloser, error) {
+	bucket, err := dbService.datasetExportsBucket()
+	if err != nil {
+		return nil, err
+	}
+	fileID, err := primitive.ObjectIDFromHex(jobID)
+	if err != nil {
+		return nil, err
+	}
+	return bucket.OpenDownloadStream(fileID)
+}
+
+func (dbService *ResearcherDBService) DeleteDatasetExportFile(jobID string) error {
+	bucket, err := dbService.datasetExportsBucket()
+	if err != nil {
+		return err
+	}
+	fileID, err := primitive.ObjectIDFromHex(jobID)
+	if err != nil {
+		return err
+	}
+	if err := bucket.Delete(fileID); err != nil && err != gridfs.ErrFileNotFound {
+		return err
+	}
+	return nil
+}
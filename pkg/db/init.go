@@ -23,6 +23,7 @@ func NewResearcherDBService(configs types.DBConfig) *ResearcherDBService {
 		options.Client().ApplyURI(configs.URI),
 		options.Client().SetMaxConnIdleTime(time.Duration(configs.IdleConnTimeout)*time.Second),
 		options.Client().SetMaxPoolSize(configs.MaxPoolSize),
+		options.Client().SetRegistry(registry),
 	)
 	if err != nil {
 		logger.Error.Fatal(err)
@@ -65,6 +66,42 @@ func (dbService *ResearcherDBService) collectionRefParticipantContacts(substudyK
 	return dbService.DBClient.Database(dbService.DBNamePrefix + "researcherDB").Collection("participant-contacts-" + substudyKey)
 }
 
+func (dbService *ResearcherDBService) collectionRefRenewTokens() *mongo.Collection {
+	return dbService.DBClient.Database(dbService.DBNamePrefix + "researcherDB").Collection("renew-tokens")
+}
+
+func (dbService *ResearcherDBService) collectionRefLoginCodes() *mongo.Collection {
+	return dbService.DBClient.Database(dbService.DBNamePrefix + "researcherDB").Collection("login-codes")
+}
+
+func (dbService *ResearcherDBService) collectionRefAdminUsers() *mongo.Collection {
+	return dbService.DBClient.Database(dbService.DBNamePrefix + "researcherDB").Collection("admin-users")
+}
+
+func (dbService *ResearcherDBService) collectionRefAuditLog() *mongo.Collection {
+	return dbService.DBClient.Database(dbService.DBNamePrefix + "researcherDB").Collection("audit-log")
+}
+
+func (dbService *ResearcherDBService) collectionRefAPIKeys() *mongo.Collection {
+	return dbService.DBClient.Database(dbService.DBNamePrefix + "researcherDB").Collection("api-keys")
+}
+
+func (dbService *ResearcherDBService) collectionRefProcessedStudyEvents() *mongo.Collection {
+	return dbService.DBClient.Database(dbService.DBNamePrefix + "researcherDB").Collection("processed-study-events")
+}
+
+func (dbService *ResearcherDBService) collectionRefStudyEventInbox() *mongo.Collection {
+	return dbService.DBClient.Database(dbService.DBNamePrefix + "researcherDB").Collection("study-event-inbox")
+}
+
+func (dbService *ResearcherDBService) collectionRefWithdrawnParticipants() *mongo.Collection {
+	return dbService.DBClient.Database(dbService.DBNamePrefix + "researcherDB").Collection("withdrawn-participants")
+}
+
+func (dbService *ResearcherDBService) collectionRefDatasetExportJobs() *mongo.Collection {
+	return dbService.DBClient.Database(dbService.DBNamePrefix + "researcherDB").Collection("dataset-export-jobs")
+}
+
 // DB utils
 func (dbService *ResearcherDBService) getContext() (ctx context.Context, cancel context.CancelFunc) {
 	return context.WithTimeout(context.Background(), time.Duration(dbService.timeout)*time.Second)
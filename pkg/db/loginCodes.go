@@ -0,0 +1,46 @@
+package db
+
+import (
+	"time"
+
+	"github.com/tekenradar/researcher-backend/pkg/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (dbService *ResearcherDBService) AddLoginCode(code types.LoginCode) error {
+	ctx, cancel := dbService.getContext()
+	defer cancel()
+
+	dbService.collectionRefLoginCodes().Indexes().CreateMany(ctx,
+		[]mongo.IndexModel{
+			{
+				Keys:    bson.D{{Key: "codeHash", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			},
+			{
+				Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+				Options: options.Index().SetExpireAfterSeconds(0),
+			},
+		})
+
+	_, err := dbService.collectionRefLoginCodes().InsertOne(ctx, code)
+	return err
+}
+
+// ConsumeLoginCode removes the code and returns it, if it is not expired yet (the TTL index removes expired
+// codes only once a minute). Returns mongo.ErrNoDocuments otherwise.
+func (dbService *ResearcherDBService) ConsumeLoginCode(codeHash string) (types.LoginCode, error) {
+	ctx, cancel := dbService.getContext()
+	defer cancel()
+
+	filter := bson.M{
+		"codeHash":  codeHash,
+		"expiresAt": bson.M{"$gt": time.Now()},
+	}
+
+	elem := types.LoginCode{}
+	err := dbService.collectionRefLoginCodes().FindOneAndDelete(ctx, filter).Decode(&elem)
+	return elem, err
+}
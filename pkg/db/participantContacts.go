@@ -1,18 +1,42 @@
 package db
 
 import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/tekenradar/researcher-backend/pkg/encryption"
 	"github.com/tekenradar/researcher-backend/pkg/types"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+const (
+	maxContactDataHistoryLength = 20 // older changes are dropped
+)
+
 func (dbService *ResearcherDBService) AddParticipantContact(substudyKey string, pc types.ParticipantContact) (string, error) {
 	ctx, cancel := dbService.getContext()
 	defer cancel()
 
+	dbService.collectionRefParticipantContacts(substudyKey).Indexes().CreateOne(ctx,
+		mongo.IndexModel{
+			Keys: bson.D{{Key: "participantID", Value: 1}},
+		})
+	if encryption.Enabled() {
+		dbService.collectionRefParticipantContacts(substudyKey).Indexes().CreateOne(ctx,
+			mongo.IndexModel{
+				Keys: bson.D{{Key: "contactData.emailIndex", Value: 1}},
+			})
+	}
+
 	res, err := dbService.collectionRefParticipantContacts(substudyKey).InsertOne(ctx, pc)
 	if err != nil {
 		return "", err
@@ -21,6 +45,81 @@ func (dbService *ResearcherDBService) AddParticipantContact(substudyKey string,
 	return id.Hex(), err
 }
 
+// FindParticipantContactByParticipantID returns the most recently added contact of the participant
+func (dbService *ResearcherDBService) FindParticipantContactByParticipantID(substudyKey string, participantID string) (types.ParticipantContact, error) {
+	ctx, cancel := dbService.getContext()
+	defer cancel()
+
+	filter := bson.M{"participantID": participantID}
+	opts := options.FindOne().SetSort(bson.D{{Key: "addedAt", Value: -1}})
+
+	elem := types.ParticipantContact{}
+	err := dbService.collectionRefParticipantContacts(substudyKey).FindOne(ctx, filter, opts).Decode(&elem)
+	return elem, err
+}
+
+// UpdateParticipantContactData replaces the data of a contact after a new submission, the previous values are appended to the
+// history, which keeps the last maxContactDataHistoryLength changes
+func (dbService *ResearcherDBService) UpdateParticipantContactData(substudyKey string, pc types.ParticipantContact, previous types.ContactDataChange) error {
+	ctx, cancel := dbService.getContext()
+	defer cancel()
+
+	filter := bson.M{"_id": pc.ID}
+	update := bson.M{
+		"$set": bson.M{
+			"sessionID":   pc.SessionID,
+			"general":     pc.General,
+			"contactData": pc.ContactData,
+			"updatedAt":   pc.UpdatedAt,
+			"updated":     true,
+		},
+		"$push": bson.M{"history": bson.M{
+			"$each":  bson.A{previous},
+			"$slice": -maxContactDataHistoryLength,
+		}},
+	}
+	_, err := dbService.collectionRefParticipantContacts(substudyKey).UpdateOne(ctx, filter, update)
+	return err
+}
+
+func (dbService *ResearcherDBService) AcknowledgeParticipantContactUpdate(substudyKey string, contactID string) error {
+	ctx, cancel := dbService.getContext()
+	defer cancel()
+
+	_id, _ := primitive.ObjectIDFromHex(contactID)
+	filter := bson.M{"_id": _id}
+
+	update := bson.M{"$set": bson.M{"updated": false}}
+	_, err := dbService.collectionRefParticipantContacts(substudyKey).UpdateOne(ctx, filter, update)
+	return err
+}
+
+// UpdateParticipantContactStatus changes the status, if the contact is still in status change.From (as stored,
+// empty for contacts without status). Returns mongo.ErrNoDocuments otherwise.
+func (dbService *ResearcherDBService) UpdateParticipantContactStatus(substudyKey string, contactID string, storedStatus string, change types.ContactStatusChange) error {
+	ctx, cancel := dbService.getContext()
+	defer cancel()
+
+	_id, _ := primitive.ObjectIDFromHex(contactID)
+	filter := bson.M{"_id": _id, "status": storedStatus}
+	if len(storedStatus) == 0 {
+		filter["status"] = bson.M{"$in": bson.A{nil, ""}}
+	}
+
+	update := bson.M{
+		"$set":  bson.M{"status": change.To},
+		"$push": bson.M{"statusHistory": change},
+	}
+	res, err := dbService.collectionRefParticipantContacts(substudyKey).UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount < 1 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
 func (dbService *ResearcherDBService) UpdateKeepParticipantContactStatus(substudyKey string, contactID string, value bool) error {
 	ctx, cancel := dbService.getContext()
 	defer cancel()
@@ -50,6 +149,50 @@ func (dbService *ResearcherDBService) AddNoteToParticipantContact(substudyKey st
 	return err
 }
 
+// UpdateParticipantContactNote replaces the content of the note, the previous content is appended to its edit history.
+// Returns mongo.ErrNoDocuments if the note does not exist.
+func (dbService *ResearcherDBService) UpdateParticipantContactNote(substudyKey string, contactID string, noteID string, content string, previous types.ContactNoteEdit) error {
+	ctx, cancel := dbService.getContext()
+	defer cancel()
+
+	_id, _ := primitive.ObjectIDFromHex(contactID)
+	filter := bson.M{"_id": _id, "notes.id": noteID}
+	update := bson.M{
+		"$set": bson.M{
+			"notes.$.content":  content,
+			"notes.$.editedAt": previous.Time,
+		},
+		"$push": bson.M{"notes.$.editHistory": previous},
+	}
+	res, err := dbService.collectionRefParticipantContacts(substudyKey).UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// DeleteParticipantContactNote removes the note, the files of its attachments are deleted as well
+func (dbService *ResearcherDBService) DeleteParticipantContactNote(substudyKey string, contactID string, note types.ContactNote) error {
+	ctx, cancel := dbService.getContext()
+	defer cancel()
+
+	_id, _ := primitive.ObjectIDFromHex(contactID)
+	filter := bson.M{"_id": _id}
+	update := bson.M{"$pull": bson.M{"notes": bson.M{"id": note.ID}}}
+	if _, err := dbService.collectionRefParticipantContacts(substudyKey).UpdateOne(ctx, filter, update); err != nil {
+		return err
+	}
+
+	attachmentIDs := []string{}
+	for _, attachment := range note.Attachments {
+		attachmentIDs = append(attachmentIDs, attachment.ID)
+	}
+	return dbService.DeleteNoteAttachmentFiles(substudyKey, attachmentIDs)
+}
+
 func (dbService *ResearcherDBService) FindParticipantContactByID(substudyKey string, id string) (pcs types.ParticipantContact, err error) {
 	ctx, cancel := dbService.getContext()
 	defer cancel()
@@ -62,18 +205,138 @@ func (dbService *ResearcherDBService) FindParticipantContactByID(substudyKey str
 	return elem, err
 }
 
-func (dbService *ResearcherDBService) FindParticipantContacts(substudyKey string) (pcs []types.ParticipantContact, err error) {
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+func participantContactQueryFilter(query types.ParticipantContactQuery) bson.M {
+	conditions := bson.A{}
+	if query.KeepContactData != nil {
+		conditions = append(conditions, bson.M{"keepContactData": *query.KeepContactData})
+	}
+	if query.MinAge != nil {
+		conditions = append(conditions, bson.M{"general.age": bson.M{"$gte": *query.MinAge}})
+	}
+	if query.MaxAge != nil {
+		conditions = append(conditions, bson.M{"general.age": bson.M{"$lte": *query.MaxAge}})
+	}
+	if len(query.Gender) > 0 {
+		conditions = append(conditions, bson.M{"general.gender": query.Gender})
+	}
+	if query.OtherStudies != nil {
+		conditions = append(conditions, bson.M{"general.otherStudies": *query.OtherStudies})
+	}
+	if query.HasNotes != nil {
+		conditions = append(conditions, bson.M{"notes.0": bson.M{"$exists": *query.HasNotes}})
+	}
+	if query.Updated != nil {
+		conditions = append(conditions, bson.M{"updated": *query.Updated})
+	}
+	if len(query.Status) > 0 {
+		if query.StatusMissing {
+			conditions = append(conditions, bson.M{"status": bson.M{"$in": bson.A{query.Status, nil, ""}}})
+		} else {
+			conditions = append(conditions, bson.M{"status": query.Status})
+		}
+	}
+	if len(query.Search) > 0 {
+		pattern := primitive.Regex{Pattern: regexp.QuoteMeta(query.Search), Options: "i"}
+		searchConditions := bson.A{
+			bson.M{"contactData.firstName": pattern},
+			bson.M{"contactData.lastName": pattern},
+			bson.M{"contactData.email": pattern},
+		}
+		// encrypted contact data can only be found by the full value
+		if index := encryption.BlindIndex(query.Search); len(index) > 0 {
+			searchConditions = append(searchConditions,
+				bson.M{"contactData.firstNameIndex": index},
+				bson.M{"contactData.lastNameIndex": index},
+				bson.M{"contactData.emailIndex": index},
+			)
+		}
+		conditions = append(conditions, bson.M{"$or": searchConditions})
+	}
+	if len(conditions) == 0 {
+		return bson.M{}
+	}
+	return bson.M{"$and": conditions}
+}
+
+// cursors point to the last contact of a page as "<addedAt>_<id>"
+func encodeParticipantContactCursor(pc types.ParticipantContact) string {
+	return encodeTimeIDCursor(pc.AddedAt, pc.ID)
+}
+
+func decodeParticipantContactCursor(cursor string) (addedAt int64, id primitive.ObjectID, err error) {
+	return decodeTimeIDCursor(cursor)
+}
+
+func encodeTimeIDCursor(t int64, id primitive.ObjectID) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d_%s", t, id.Hex())))
+}
+
+func decodeTimeIDCursor(cursor string) (t int64, id primitive.ObjectID, err error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, id, ErrInvalidCursor
+	}
+	parts := strings.SplitN(string(raw), "_", 2)
+	if len(parts) != 2 {
+		return 0, id, ErrInvalidCursor
+	}
+	t, err = strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return 0, id, ErrInvalidCursor
+	}
+	id, err = primitive.ObjectIDFromHex(parts[1])
+	if err != nil {
+		return 0, id, ErrInvalidCursor
+	}
+	return t, id, nil
+}
+
+// FindParticipantContacts returns one page of the matching contacts sorted by addedAt, and the cursor
+// for the next page (empty if there are no more contacts)
+func (dbService *ResearcherDBService) FindParticipantContacts(substudyKey string, query types.ParticipantContactQuery) (pcs []types.ParticipantContact, nextCursor string, err error) {
 	ctx, cancel := dbService.getContext()
 	defer cancel()
 
-	filter := bson.M{}
+	dbService.collectionRefParticipantContacts(substudyKey).Indexes().CreateOne(ctx,
+		mongo.IndexModel{
+			Keys: bson.D{{Key: "addedAt", Value: -1}, {Key: "_id", Value: -1}},
+		})
+
+	filter := participantContactQueryFilter(query)
+	sortOrder := -1
+	cmp := "$lt"
+	if query.SortAscending {
+		sortOrder = 1
+		cmp = "$gt"
+	}
+	if len(query.Cursor) > 0 {
+		addedAt, id, err := decodeParticipantContactCursor(query.Cursor)
+		if err != nil {
+			return pcs, "", err
+		}
+		filter = bson.M{"$and": bson.A{
+			filter,
+			bson.M{"$or": bson.A{
+				bson.M{"addedAt": bson.M{cmp: addedAt}},
+				bson.M{"addedAt": addedAt, "_id": bson.M{cmp: id}},
+			}},
+		}}
+	}
+
 	batchSize := int32(32)
 	opts := options.FindOptions{
 		BatchSize: &batchSize,
+		Sort:      bson.D{{Key: "addedAt", Value: sortOrder}, {Key: "_id", Value: sortOrder}},
+	}
+	if query.Limit > 0 {
+		// one more to know if there is a next page
+		opts.SetLimit(query.Limit + 1)
 	}
 	cur, err := dbService.collectionRefParticipantContacts(substudyKey).Find(ctx, filter, &opts)
 	if err != nil {
-		return pcs, err
+		return pcs, "", err
 	}
 	defer cur.Close(ctx)
 
@@ -83,37 +346,177 @@ func (dbService *ResearcherDBService) FindParticipantContacts(substudyKey string
 		err := cur.Decode(&result)
 
 		if err != nil {
-			return pcs, err
+			return pcs, "", err
 		}
 
 		pcs = append(pcs, result)
 	}
 	if err := cur.Err(); err != nil {
-		return pcs, err
+		return pcs, "", err
 	}
 
-	return pcs, nil
+	if query.Limit > 0 && int64(len(pcs)) > query.Limit {
+		pcs = pcs[:query.Limit]
+		nextCursor = encodeParticipantContactCursor(pcs[len(pcs)-1])
+	}
+	return pcs, nextCursor, nil
 }
 
-// Remove entries after certain time if not marked as permanent
-func (dbService *ResearcherDBService) CleanUpExpiredParticipantContacts(substudyKey string, deleteAfterInDays int) error {
+// CountParticipantContacts counts the contacts matching the query, ignoring cursor and limit
+func (dbService *ResearcherDBService) CountParticipantContacts(substudyKey string, query types.ParticipantContactQuery) (int64, error) {
 	ctx, cancel := dbService.getContext()
 	defer cancel()
 
-	ref := time.Now().AddDate(0, 0, -deleteAfterInDays).Unix()
+	return dbService.collectionRefParticipantContacts(substudyKey).CountDocuments(ctx, participantContactQueryFilter(query))
+}
+
+// removeContactData also removes the history, as it contains previous contact data
+var removeContactData = bson.M{
+	"$set":   bson.M{"contactData": nil},
+	"$unset": bson.M{"history": ""},
+}
+
+// withContactData matches contacts with contact data, or previous contact data in the history
+func withContactData() bson.M {
+	return bson.M{"$or": bson.A{
+		bson.M{"contactData": bson.M{"$ne": nil}},
+		bson.M{"history.0": bson.M{"$exists": true}},
+	}}
+}
+
+// lastChangedBefore matches contacts that were neither added nor updated after ref
+func lastChangedBefore(ref int64) bson.M {
+	return bson.M{"$and": bson.A{
+		bson.M{"addedAt": bson.M{"$lt": ref}},
+		bson.M{"$or": bson.A{
+			bson.M{"updatedAt": bson.M{"$exists": false}},
+			bson.M{"updatedAt": bson.M{"$lt": ref}},
+		}},
+	}}
+}
+
+// CleanUpExpiredParticipantContacts applies the retention policy (with defaults applied): contact data and its history are removed
+// after RemoveContactDataAfterDays unless marked as permanent or in one of the keepStatuses, kept contacts lose their
+// contact data after KeptContactsMaxDays, and documents without contact data are deleted after DeleteAfterDays.
+func (dbService *ResearcherDBService) CleanUpExpiredParticipantContacts(substudyKey string, policy types.ContactRetentionPolicy, keepStatuses []string) error {
+	ctx, cancel := dbService.getContext()
+	defer cancel()
+
+	coll := dbService.collectionRefParticipantContacts(substudyKey)
+
+	ref := time.Now().AddDate(0, 0, -policy.RemoveContactDataAfterDays).Unix()
 	filter := bson.M{
 		"$and": bson.A{
-			bson.M{"addedAt": bson.M{"$lt": ref}},
+			lastChangedBefore(ref),
 			bson.M{"keepContactData": false},
+			bson.M{"status": bson.M{"$nin": keepStatuses}},
 		},
 	}
-	update := bson.M{"$set": bson.M{
-		"contactData": nil,
-	}}
+	if _, err := coll.UpdateMany(ctx, filter, removeContactData); err != nil {
+		return err
+	}
+
+	if policy.KeptContactsMaxDays > 0 {
+		ref := time.Now().AddDate(0, 0, -policy.KeptContactsMaxDays).Unix()
+		filter := bson.M{
+			"$and": bson.A{
+				lastChangedBefore(ref),
+				withContactData(),
+			},
+		}
+		if _, err := coll.UpdateMany(ctx, filter, removeContactData); err != nil {
+			return err
+		}
+	}
+
+	if policy.DeleteAfterDays > 0 {
+		ref := time.Now().AddDate(0, 0, -policy.DeleteAfterDays).Unix()
+		filter := bson.M{
+			"$and": bson.A{
+				lastChangedBefore(ref),
+				bson.M{"contactData": nil},
+			},
+		}
+		if err := dbService.deleteNoteAttachmentsOfContacts(ctx, substudyKey, filter); err != nil {
+			return err
+		}
+		if _, err := coll.DeleteMany(ctx, filter); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FindParticipantContactsWithContactDataChangedBefore returns contacts which still have contact data and were
+// neither added nor updated after ref
+func (dbService *ResearcherDBService) FindParticipantContactsWithContactDataChangedBefore(substudyKey string, ref int64) (pcs []types.ParticipantContact, err error) {
+	ctx, cancel := dbService.getContext()
+	defer cancel()
+
+	filter := bson.M{
+		"$and": bson.A{
+			lastChangedBefore(ref),
+			bson.M{"contactData": bson.M{"$ne": nil}},
+		},
+	}
+	batchSize := int32(32)
+	opts := options.FindOptions{
+		BatchSize: &batchSize,
+		Sort:      bson.D{{Key: "addedAt", Value: 1}},
+	}
+	cur, err := dbService.collectionRefParticipantContacts(substudyKey).Find(ctx, filter, &opts)
+	if err != nil {
+		return pcs, err
+	}
+	defer cur.Close(ctx)
+
+	pcs = []types.ParticipantContact{}
+	for cur.Next(ctx) {
+		var result types.ParticipantContact
+		err := cur.Decode(&result)
+
+		if err != nil {
+			return pcs, err
+		}
+
+		pcs = append(pcs, result)
+	}
+	if err := cur.Err(); err != nil {
+		return pcs, err
+	}
+
+	return pcs, nil
+}
+
+func (dbService *ResearcherDBService) MarkExpiryWarningSent(substudyKey string, contactIDs []primitive.ObjectID) error {
+	ctx, cancel := dbService.getContext()
+	defer cancel()
+
+	filter := bson.M{"_id": bson.M{"$in": contactIDs}}
+	update := bson.M{"$set": bson.M{"expiryWarningSentAt": time.Now().Unix()}}
 	_, err := dbService.collectionRefParticipantContacts(substudyKey).UpdateMany(ctx, filter, update)
 	return err
 }
 
+// RemoveContactDataForStatuses removes the contact data (and its history) of entries in one of the statuses, if not marked as permanent
+func (dbService *ResearcherDBService) RemoveContactDataForStatuses(substudyKey string, statuses []string) error {
+	if len(statuses) == 0 {
+		return nil
+	}
+	ctx, cancel := dbService.getContext()
+	defer cancel()
+
+	filter := bson.M{"$and": bson.A{
+		bson.M{
+			"status":          bson.M{"$in": statuses},
+			"keepContactData": false,
+		},
+		withContactData(),
+	}}
+	_, err := dbService.collectionRefParticipantContacts(substudyKey).UpdateMany(ctx, filter, removeContactData)
+	return err
+}
+
 // Remove entries after certain time if not marked as permanent
 func (dbService *ResearcherDBService) DeleteParticipantContact(substudyKey string, contactID string) error {
 	ctx, cancel := dbService.getContext()
@@ -121,6 +524,184 @@ func (dbService *ResearcherDBService) DeleteParticipantContact(substudyKey strin
 
 	_id, _ := primitive.ObjectIDFromHex(contactID)
 	filter := bson.M{"_id": _id}
+	if err := dbService.deleteNoteAttachmentsOfContacts(ctx, substudyKey, filter); err != nil {
+		return err
+	}
 	_, err := dbService.collectionRefParticipantContacts(substudyKey).DeleteOne(ctx, filter)
 	return err
 }
+
+// DeleteParticipantContactsByParticipantID removes all contacts of the participant from the substudy
+func (dbService *ResearcherDBService) CountParticipantContactsByParticipantID(substudyKey string, participantID string) (int64, error) {
+	ctx, cancel := dbService.getContext()
+	defer cancel()
+
+	filter := bson.M{"participantID": participantID}
+	return dbService.collectionRefParticipantContacts(substudyKey).CountDocuments(ctx, filter)
+}
+
+func (dbService *ResearcherDBService) DeleteParticipantContactsByParticipantID(substudyKey string, participantID string) (count int64, err error) {
+	ctx, cancel := dbService.getContext()
+	defer cancel()
+
+	filter := bson.M{"participantID": participantID}
+	if err := dbService.deleteNoteAttachmentsOfContacts(ctx, substudyKey, filter); err != nil {
+		return 0, err
+	}
+	res, err := dbService.collectionRefParticipantContacts(substudyKey).DeleteMany(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	return res.DeletedCount, nil
+}
+
+// ReencryptParticipantContacts writes the contact data of all contacts, which is not yet encrypted with the
+// active key, again. Returns the number of updated contacts.
+func (dbService *ResearcherDBService) ReencryptParticipantContacts(substudyKey string) (int, error) {
+	if !encryption.Enabled() {
+		return 0, encryption.ErrNotConfigured
+	}
+	// may run longer than the usual timeout, only single operations are limited
+	ctx := context.Background()
+
+	cur, err := dbService.collectionRefParticipantContacts(substudyKey).Find(ctx, notEncryptedWithActiveKey(), reencryptProjection)
+	if err != nil {
+		return 0, err
+	}
+	defer cur.Close(ctx)
+
+	count := 0
+	for cur.Next(ctx) {
+		updated, err := dbService.reencryptParticipantContact(substudyKey, cur.Current)
+		if err != nil {
+			return count, err
+		}
+		if updated {
+			count++
+		}
+	}
+	return count, cur.Err()
+}
+
+var reencryptProjection = options.Find().SetProjection(bson.M{"contactData": 1, "history": 1})
+
+const maxReencryptAttempts = 5
+
+func notEncryptedWithActiveKey() bson.M {
+	notActiveKey := bson.M{"$ne": encryption.ActiveKeyID()}
+	return bson.M{"$or": bson.A{
+		bson.M{
+			"contactData":                 bson.M{"$ne": nil},
+			"contactData.encrypted.keyID": notActiveKey,
+		},
+		bson.M{"history": bson.M{"$elemMatch": bson.M{
+			"contactData":                 bson.M{"$ne": nil},
+			"contactData.encrypted.keyID": notActiveKey,
+		}}},
+	}}
+}
+
+// reencryptParticipantContact writes the decoded contact data and history of the contact again. The update only
+// applies if both are still stored as read (a new submission may have changed them in the meantime), otherwise the
+// contact is read again. Returns false if the contact does not need to be re-encrypted anymore.
+func (dbService *ResearcherDBService) reencryptParticipantContact(substudyKey string, raw bson.Raw) (bool, error) {
+	coll := dbService.collectionRefParticipantContacts(substudyKey)
+	for attempt := 1; ; attempt++ {
+		var pc types.ParticipantContact
+		if err := bson.UnmarshalWithRegistry(registry, raw, &pc); err != nil {
+			return false, err
+		}
+
+		filter := bson.M{"_id": pc.ID}
+		set := bson.M{"contactData": pc.ContactData}
+		for _, field := range []string{"contactData", "history"} {
+			if value, err := raw.LookupErr(field); err == nil {
+				filter[field] = value
+			} else {
+				filter[field] = bson.M{"$exists": false}
+			}
+		}
+		if pc.History != nil {
+			set["history"] = pc.History
+		}
+
+		ctx, cancel := dbService.getContext()
+		res, err := coll.UpdateOne(ctx, filter, bson.M{"$set": set})
+		if err != nil {
+			cancel()
+			return false, err
+		}
+		if res.MatchedCount > 0 {
+			cancel()
+			return true, nil
+		}
+		if attempt >= maxReencryptAttempts {
+			cancel()
+			return false, fmt.Errorf("contact %s keeps changing, re-encryption skipped", pc.ID.Hex())
+		}
+
+		// changed in the meantime, read the current state
+		filter = bson.M{"$and": bson.A{bson.M{"_id": pc.ID}, notEncryptedWithActiveKey()}}
+		opts := options.FindOne().SetProjection(bson.M{"contactData": 1, "history": 1})
+		raw, err = coll.FindOne(ctx, filter, opts).DecodeBytes()
+		cancel()
+		if err == mongo.ErrNoDocuments {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+}
+
+// BackfillContactNoteIDs assigns new IDs to notes without ID (added before IDs were generated by the server) or with
+// the ID of an earlier note of the same contact, so that every note can be edited and deleted. Contacts whose notes
+// change in the meantime are skipped and handled by the next run. Returns the number of updated contacts.
+func (dbService *ResearcherDBService) BackfillContactNoteIDs(substudyKey string) (int, error) {
+	// may run longer than the usual timeout, only single operations are limited
+	ctx := context.Background()
+
+	filter := bson.M{"$or": bson.A{
+		bson.M{"notes.id": ""},
+		// ids missing or not unique
+		bson.M{"$expr": bson.M{"$ne": bson.A{
+			bson.M{"$size": bson.M{"$setUnion": bson.A{bson.M{"$ifNull": bson.A{"$notes.id", bson.A{}}}, bson.A{}}}},
+			bson.M{"$size": bson.M{"$ifNull": bson.A{"$notes", bson.A{}}}},
+		}}},
+	}}
+	opts := options.Find().SetProjection(bson.M{"notes": 1})
+
+	coll := dbService.collectionRefParticipantContacts(substudyKey)
+	cur, err := coll.Find(ctx, filter, opts)
+	if err != nil {
+		return 0, err
+	}
+	defer cur.Close(ctx)
+
+	count := 0
+	for cur.Next(ctx) {
+		var pc types.ParticipantContact
+		if err := cur.Decode(&pc); err != nil {
+			return count, err
+		}
+		seen := map[string]bool{}
+		for i, note := range pc.Notes {
+			if len(note.ID) == 0 || seen[note.ID] {
+				pc.Notes[i].ID = primitive.NewObjectID().Hex()
+			}
+			seen[pc.Notes[i].ID] = true
+		}
+
+		updateFilter := bson.M{"_id": pc.ID, "notes": cur.Current.Lookup("notes")}
+		updateCtx, cancel := dbService.getContext()
+		res, err := coll.UpdateOne(updateCtx, updateFilter, bson.M{"$set": bson.M{"notes": pc.Notes}})
+		cancel()
+		if err != nil {
+			return count, err
+		}
+		if res.MatchedCount > 0 {
+			count++
+		}
+	}
+	return count, cur.Err()
+}
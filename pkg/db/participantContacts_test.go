@@ -0,0 +1,138 @@
+package db
+
+import (
+	"encoding/base64"
+	"reflect"
+	"testing"
+
+	"github.com/tekenradar/researcher-backend/pkg/encryption"
+	"github.com/tekenradar/researcher-backend/pkg/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func boolPtr(v bool) *bool {
+	return &v
+}
+
+func intPtr(v int) *int {
+	return &v
+}
+
+func TestParticipantContactQueryFilter(t *testing.T) {
+	tests := []struct {
+		name  string
+		query types.ParticipantContactQuery
+		want  bson.M
+	}{
+		{
+			name:  "no filters",
+			query: types.ParticipantContactQuery{Limit: 50, Cursor: "abc", SortAscending: true},
+			want:  bson.M{},
+		},
+		{
+			name: "flags and age range",
+			query: types.ParticipantContactQuery{
+				KeepContactData: boolPtr(true),
+				MinAge:          intPtr(18),
+				MaxAge:          intPtr(65),
+				OtherStudies:    boolPtr(false),
+				HasNotes:        boolPtr(true),
+				Updated:         boolPtr(false),
+			},
+			want: bson.M{"$and": bson.A{
+				bson.M{"keepContactData": true},
+				bson.M{"general.age": bson.M{"$gte": 18}},
+				bson.M{"general.age": bson.M{"$lte": 65}},
+				bson.M{"general.otherStudies": false},
+				bson.M{"notes.0": bson.M{"$exists": true}},
+				bson.M{"updated": false},
+			}},
+		},
+		{
+			name:  "gender and status",
+			query: types.ParticipantContactQuery{Gender: "female", Status: "invited"},
+			want: bson.M{"$and": bson.A{
+				bson.M{"general.gender": "female"},
+				bson.M{"status": "invited"},
+			}},
+		},
+		{
+			name:  "status including contacts without status",
+			query: types.ParticipantContactQuery{Status: "new", StatusMissing: true},
+			want: bson.M{"$and": bson.A{
+				bson.M{"status": bson.M{"$in": bson.A{"new", nil, ""}}},
+			}},
+		},
+		{
+			name:  "search is escaped and case insensitive",
+			query: types.ParticipantContactQuery{Search: "a.b+c"},
+			want: bson.M{"$and": bson.A{
+				bson.M{"$or": bson.A{
+					bson.M{"contactData.firstName": primitive.Regex{Pattern: `a\.b\+c`, Options: "i"}},
+					bson.M{"contactData.lastName": primitive.Regex{Pattern: `a\.b\+c`, Options: "i"}},
+					bson.M{"contactData.email": primitive.Regex{Pattern: `a\.b\+c`, Options: "i"}},
+				}},
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := participantContactQueryFilter(tt.query); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("participantContactQueryFilter() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParticipantContactCursor(t *testing.T) {
+	id := primitive.NewObjectID()
+	cursor := encodeParticipantContactCursor(types.ParticipantContact{ID: id, AddedAt: 1650000000})
+
+	addedAt, decodedID, err := decodeParticipantContactCursor(cursor)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if addedAt != 1650000000 || decodedID != id {
+		t.Errorf("decoded %d %s, want %d %s", addedAt, decodedID.Hex(), 1650000000, id.Hex())
+	}
+
+	invalid := []struct {
+		name   string
+		cursor string
+	}{
+		{name: "not base64", cursor: "%%%"},
+		{name: "no separator", cursor: base64.RawURLEncoding.EncodeToString([]byte("1650000000"))},
+		{name: "invalid time", cursor: base64.RawURLEncoding.EncodeToString([]byte("abc_" + id.Hex()))},
+		{name: "invalid id", cursor: base64.RawURLEncoding.EncodeToString([]byte("1650000000_xyz"))},
+		{name: "empty", cursor: ""},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := decodeParticipantContactCursor(tt.cursor); err != ErrInvalidCursor {
+				t.Errorf("expected ErrInvalidCursor, got %v", err)
+			}
+		})
+	}
+}
+
+func TestParticipantContactQueryFilterEncrypted(t *testing.T) {
+	initTestEncryption(t)
+	index := encryption.BlindIndex("Jane@Example.org")
+	pattern := primitive.Regex{Pattern: `Jane@Example\.org`, Options: "i"}
+
+	want := bson.M{"$and": bson.A{
+		bson.M{"$or": bson.A{
+			bson.M{"contactData.firstName": pattern},
+			bson.M{"contactData.lastName": pattern},
+			bson.M{"contactData.email": pattern},
+			bson.M{"contactData.firstNameIndex": index},
+			bson.M{"contactData.lastNameIndex": index},
+			bson.M{"contactData.emailIndex": index},
+		}},
+	}}
+	if got := participantContactQueryFilter(types.ParticipantContactQuery{Search: "Jane@Example.org"}); !reflect.DeepEqual(got, want) {
+		t.Errorf("participantContactQueryFilter() = %v, want %v", got, want)
+	}
+}
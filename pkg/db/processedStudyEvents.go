@@ -0,0 +1,94 @@
+package db
+
+import (
+	"regexp"
+	"time"
+
+	"github.com/tekenradar/researcher-backend/pkg/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// StartProcessingStudyEvent claims the event key. If the key was already claimed, the existing entry is
+// returned together with started=false. Entries which are still processing after staleAfter are taken over.
+func (dbService *ResearcherDBService) StartProcessingStudyEvent(event types.ProcessedStudyEvent, staleAfter time.Duration) (existing types.ProcessedStudyEvent, started bool, err error) {
+	ctx, cancel := dbService.getContext()
+	defer cancel()
+
+	dbService.collectionRefProcessedStudyEvents().Indexes().CreateMany(ctx,
+		[]mongo.IndexModel{
+			{
+				Keys:    bson.D{{Key: "key", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			},
+			{
+				Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+				Options: options.Index().SetExpireAfterSeconds(0),
+			},
+		})
+
+	_, err = dbService.collectionRefProcessedStudyEvents().InsertOne(ctx, event)
+	if err == nil {
+		return event, true, nil
+	}
+	if !mongo.IsDuplicateKeyError(err) {
+		return existing, false, err
+	}
+
+	filter := bson.M{
+		"key":       event.Key,
+		"status":    types.STUDY_EVENT_STATUS_PROCESSING,
+		"startedAt": bson.M{"$lt": time.Now().Add(-staleAfter).Unix()},
+	}
+	update := bson.M{"$set": bson.M{"startedAt": event.StartedAt, "expiresAt": event.ExpiresAt}}
+	err = dbService.collectionRefProcessedStudyEvents().FindOneAndUpdate(ctx, filter, update).Decode(&existing)
+	if err == nil {
+		return existing, true, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return existing, false, err
+	}
+
+	err = dbService.collectionRefProcessedStudyEvents().FindOne(ctx, bson.M{"key": event.Key}).Decode(&existing)
+	return existing, false, err
+}
+
+func (dbService *ResearcherDBService) FinishProcessingStudyEvent(key string, statusCode int, result map[string]interface{}) error {
+	ctx, cancel := dbService.getContext()
+	defer cancel()
+
+	filter := bson.M{"key": key}
+	update := bson.M{"$set": bson.M{
+		"status":      types.STUDY_EVENT_STATUS_DONE,
+		"completedAt": time.Now().Unix(),
+		"statusCode":  statusCode,
+		"result":      result,
+	}}
+	_, err := dbService.collectionRefProcessedStudyEvents().UpdateOne(ctx, filter, update)
+	return err
+}
+
+// DeleteProcessedStudyEvent releases the key, so that the event can be retried
+func (dbService *ResearcherDBService) DeleteProcessedStudyEvent(key string) error {
+	ctx, cancel := dbService.getContext()
+	defer cancel()
+
+	_, err := dbService.collectionRefProcessedStudyEvents().DeleteOne(ctx, bson.M{"key": key})
+	return err
+}
+
+// DeleteProcessedStudyEventsOfParticipant removes the entries whose key contains the participant ID
+// ("<eventType>:<participantID>:<responseID>"). Returns the number of deleted entries.
+func (dbService *ResearcherDBService) DeleteProcessedStudyEventsOfParticipant(participantID string) (int64, error) {
+	ctx, cancel := dbService.getContext()
+	defer cancel()
+
+	filter := bson.M{"key": primitive.Regex{Pattern: "^[^:]+:" + regexp.QuoteMeta(participantID) + ":"}}
+	res, err := dbService.collectionRefProcessedStudyEvents().DeleteMany(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	return res.DeletedCount, nil
+}
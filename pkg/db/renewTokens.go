@@ -0,0 +1,85 @@
+package db
+
+import (
+	"time"
+
+	"github.com/tekenradar/researcher-backend/pkg/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (dbService *ResearcherDBService) AddRenewToken(rt types.RenewToken) (string, error) {
+	ctx, cancel := dbService.getContext()
+	defer cancel()
+
+	dbService.collectionRefRenewTokens().Indexes().CreateMany(ctx,
+		[]mongo.IndexModel{
+			{
+				Keys:    bson.D{{Key: "tokenHash", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			},
+			{
+				Keys: bson.D{{Key: "familyID", Value: 1}},
+			},
+			{
+				Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+				Options: options.Index().SetExpireAfterSeconds(0),
+			},
+		})
+
+	res, err := dbService.collectionRefRenewTokens().InsertOne(ctx, rt)
+	if err != nil {
+		return "", err
+	}
+	id := res.InsertedID.(primitive.ObjectID)
+	return id.Hex(), err
+}
+
+func (dbService *ResearcherDBService) FindRenewToken(tokenHash string) (types.RenewToken, error) {
+	ctx, cancel := dbService.getContext()
+	defer cancel()
+
+	filter := bson.M{"tokenHash": tokenHash}
+
+	elem := types.RenewToken{}
+	err := dbService.collectionRefRenewTokens().FindOne(ctx, filter).Decode(&elem)
+	return elem, err
+}
+
+// MarkRenewTokenUsed consumes the token, if it was neither used, revoked nor expired yet.
+// Returns mongo.ErrNoDocuments otherwise.
+func (dbService *ResearcherDBService) MarkRenewTokenUsed(tokenHash string) (types.RenewToken, error) {
+	ctx, cancel := dbService.getContext()
+	defer cancel()
+
+	filter := bson.M{
+		"tokenHash": tokenHash,
+		"usedAt":    0,
+		"revokedAt": 0,
+		"expiresAt": bson.M{"$gt": time.Now()},
+	}
+	update := bson.M{"$set": bson.M{"usedAt": time.Now().Unix()}}
+
+	elem := types.RenewToken{}
+	err := dbService.collectionRefRenewTokens().FindOneAndUpdate(ctx, filter, update).Decode(&elem)
+	return elem, err
+}
+
+func (dbService *ResearcherDBService) RevokeRenewTokenFamily(familyID string) (count int64, err error) {
+	ctx, cancel := dbService.getContext()
+	defer cancel()
+
+	filter := bson.M{
+		"familyID":  familyID,
+		"revokedAt": 0,
+	}
+	update := bson.M{"$set": bson.M{"revokedAt": time.Now().Unix()}}
+
+	res, err := dbService.collectionRefRenewTokens().UpdateMany(ctx, filter, update)
+	if err != nil {
+		return 0, err
+	}
+	return res.ModifiedCount, nil
+}
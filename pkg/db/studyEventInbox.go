@@ -0,0 +1,267 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/tekenradar/researcher-backend/pkg/encryption"
+	"github.com/tekenradar/researcher-backend/pkg/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (dbService *ResearcherDBService) AddStudyEventToInbox(entry types.StudyEventInboxEntry) (string, error) {
+	ctx, cancel := dbService.getContext()
+	defer cancel()
+
+	dbService.collectionRefStudyEventInbox().Indexes().CreateMany(ctx,
+		[]mongo.IndexModel{
+			{
+				Keys: bson.D{{Key: "status", Value: 1}, {Key: "nextAttemptAt", Value: 1}},
+			},
+			{
+				Keys: bson.D{{Key: "participantID", Value: 1}},
+			},
+			{
+				Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+				Options: options.Index().SetExpireAfterSeconds(0),
+			},
+		})
+
+	if entry.CompletedTasks == nil {
+		entry.CompletedTasks = []string{}
+	}
+	res, err := dbService.collectionRefStudyEventInbox().InsertOne(ctx, entry)
+	if err != nil {
+		return "", err
+	}
+	id := res.InsertedID.(primitive.ObjectID)
+	return id.Hex(), err
+}
+
+// ClaimNextStudyEvent locks the oldest due entry for processing, or an entry whose lock has run out.
+// Returns mongo.ErrNoDocuments if there is nothing to do.
+func (dbService *ResearcherDBService) ClaimNextStudyEvent(lockFor time.Duration) (types.StudyEventInboxEntry, error) {
+	ctx, cancel := dbService.getContext()
+	defer cancel()
+
+	now := time.Now().Unix()
+	filter := bson.M{"$or": bson.A{
+		bson.M{"status": types.STUDY_EVENT_INBOX_STATUS_PENDING, "nextAttemptAt": bson.M{"$lte": now}},
+		bson.M{"status": types.STUDY_EVENT_INBOX_STATUS_PROCESSING, "lockedUntil": bson.M{"$lt": now}},
+	}}
+	update := bson.M{
+		"$set": bson.M{
+			"status":      types.STUDY_EVENT_INBOX_STATUS_PROCESSING,
+			"lockedUntil": time.Now().Add(lockFor).Unix(),
+		},
+		"$inc": bson.M{"attempts": 1},
+	}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "nextAttemptAt", Value: 1}}).
+		SetReturnDocument(options.After)
+
+	elem := types.StudyEventInboxEntry{}
+	err := dbService.collectionRefStudyEventInbox().FindOneAndUpdate(ctx, filter, update, opts).Decode(&elem)
+	return elem, err
+}
+
+func (dbService *ResearcherDBService) AddCompletedStudyEventTask(id primitive.ObjectID, task string) error {
+	ctx, cancel := dbService.getContext()
+	defer cancel()
+
+	update := bson.M{"$addToSet": bson.M{"completedTasks": task}}
+	_, err := dbService.collectionRefStudyEventInbox().UpdateOne(ctx, bson.M{"_id": id}, update)
+	return err
+}
+
+// MarkStudyEventDone sets the entry to done and removes the payload, which is not needed anymore. The entry
+// is removed after keepFor.
+func (dbService *ResearcherDBService) MarkStudyEventDone(id primitive.ObjectID, keepFor time.Duration) error {
+	ctx, cancel := dbService.getContext()
+	defer cancel()
+
+	expiresAt := time.Now().Add(keepFor)
+	update := bson.M{
+		"$set": bson.M{
+			"status":      types.STUDY_EVENT_INBOX_STATUS_DONE,
+			"processedAt": time.Now().Unix(),
+			"lastError":   "",
+			"expiresAt":   expiresAt,
+		},
+		"$unset": bson.M{"payload": ""},
+	}
+	_, err := dbService.collectionRefStudyEventInbox().UpdateOne(ctx, bson.M{"_id": id}, update)
+	return err
+}
+
+// MarkStudyEventFailed schedules the next attempt, or moves the entry to the dead-letter state if nextAttemptAt is 0.
+// Dead-lettered entries are removed after keepFailedFor, unless replayed.
+func (dbService *ResearcherDBService) MarkStudyEventFailed(id primitive.ObjectID, errMsg string, nextAttemptAt int64, keepFailedFor time.Duration) error {
+	ctx, cancel := dbService.getContext()
+	defer cancel()
+
+	set := bson.M{
+		"status":        types.STUDY_EVENT_INBOX_STATUS_PENDING,
+		"lastError":     errMsg,
+		"nextAttemptAt": nextAttemptAt,
+		"lockedUntil":   0,
+	}
+	if nextAttemptAt == 0 {
+		set["status"] = types.STUDY_EVENT_INBOX_STATUS_FAILED
+		set["expiresAt"] = time.Now().Add(keepFailedFor)
+	}
+	update := bson.M{"$set": set}
+	_, err := dbService.collectionRefStudyEventInbox().UpdateOne(ctx, bson.M{"_id": id}, update)
+	return err
+}
+
+// ReplayStudyEvent puts a failed entry back into the queue, already completed tasks are kept and it does not expire anymore
+func (dbService *ResearcherDBService) ReplayStudyEvent(id string) (types.StudyEventInboxEntry, error) {
+	ctx, cancel := dbService.getContext()
+	defer cancel()
+
+	_id, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return types.StudyEventInboxEntry{}, err
+	}
+	filter := bson.M{"_id": _id, "status": types.STUDY_EVENT_INBOX_STATUS_FAILED}
+	update := bson.M{
+		"$set": bson.M{
+			"status":        types.STUDY_EVENT_INBOX_STATUS_PENDING,
+			"attempts":      0,
+			"nextAttemptAt": time.Now().Unix(),
+			"lockedUntil":   0,
+		},
+		"$unset": bson.M{"expiresAt": ""},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	elem := types.StudyEventInboxEntry{}
+	err = dbService.collectionRefStudyEventInbox().FindOneAndUpdate(ctx, filter, update, opts).Decode(&elem)
+	return elem, err
+}
+
+// PurgeStudyEventsOfParticipant deletes the participant's entries which are not processed yet (so that e.g. a pending
+// t0-invite does not create the contact again) and removes the payloads of the processed ones. The entry exceptID
+// (the withdrawal being processed) is kept. Returns the number of deleted and redacted entries.
+func (dbService *ResearcherDBService) PurgeStudyEventsOfParticipant(participantID string, exceptID primitive.ObjectID) (deleted int64, redacted int64, err error) {
+	ctx, cancel := dbService.getContext()
+	defer cancel()
+
+	filter := bson.M{
+		"_id": bson.M{"$ne": exceptID},
+		"$or": bson.A{
+			bson.M{"participantID": participantID},
+			// entries added before the participant ID was stored next to the payload, if not encrypted
+			bson.M{"payload.participantstate.participantID": participantID},
+		},
+	}
+
+	notProcessed := bson.M{"$and": bson.A{
+		filter,
+		bson.M{"status": bson.M{"$ne": types.STUDY_EVENT_INBOX_STATUS_DONE}},
+	}}
+	res, err := dbService.collectionRefStudyEventInbox().DeleteMany(ctx, notProcessed)
+	if err != nil {
+		return 0, 0, err
+	}
+	deleted = res.DeletedCount
+
+	withPayload := bson.M{"$and": bson.A{
+		filter,
+		bson.M{"payload": bson.M{"$exists": true}},
+	}}
+	updateRes, err := dbService.collectionRefStudyEventInbox().UpdateMany(ctx, withPayload, bson.M{"$unset": bson.M{"payload": ""}})
+	if err != nil {
+		return deleted, 0, err
+	}
+	return deleted, updateRes.ModifiedCount, nil
+}
+
+// FindStudyEventsInInbox returns the matching entries, newest first
+func (dbService *ResearcherDBService) FindStudyEventsInInbox(query types.StudyEventInboxQuery) (entries []types.StudyEventInboxEntry, err error) {
+	ctx, cancel := dbService.getContext()
+	defer cancel()
+
+	filter := bson.M{}
+	if len(query.Status) > 0 {
+		filter["status"] = query.Status
+	}
+	if len(query.EventType) > 0 {
+		filter["eventType"] = query.EventType
+	}
+
+	batchSize := int32(32)
+	opts := options.FindOptions{
+		BatchSize: &batchSize,
+		Sort:      bson.D{{Key: "receivedAt", Value: -1}},
+	}
+	if query.Limit > 0 {
+		opts.SetLimit(query.Limit)
+	}
+	cur, err := dbService.collectionRefStudyEventInbox().Find(ctx, filter, &opts)
+	if err != nil {
+		return entries, err
+	}
+	defer cur.Close(ctx)
+
+	entries = []types.StudyEventInboxEntry{}
+	for cur.Next(ctx) {
+		var result types.StudyEventInboxEntry
+		err := cur.Decode(&result)
+
+		if err != nil {
+			return entries, err
+		}
+
+		entries = append(entries, result)
+	}
+	if err := cur.Err(); err != nil {
+		return entries, err
+	}
+
+	return entries, nil
+}
+
+// ReencryptStudyEventPayloads writes the payloads of the entries, which are not yet encrypted with the active
+// key, again. Returns the number of updated entries.
+func (dbService *ResearcherDBService) ReencryptStudyEventPayloads() (int, error) {
+	if !encryption.Enabled() {
+		return 0, encryption.ErrNotConfigured
+	}
+	// may run longer than the usual timeout, only single operations are limited
+	ctx := context.Background()
+
+	filter := bson.M{
+		"payload":                 bson.M{"$exists": true},
+		"payload.encrypted.keyID": bson.M{"$ne": encryption.ActiveKeyID()},
+	}
+	opts := options.Find().SetProjection(bson.M{"payload": 1})
+
+	cur, err := dbService.collectionRefStudyEventInbox().Find(ctx, filter, opts)
+	if err != nil {
+		return 0, err
+	}
+	defer cur.Close(ctx)
+
+	count := 0
+	for cur.Next(ctx) {
+		var entry types.StudyEventInboxEntry
+		if err := cur.Decode(&entry); err != nil {
+			return count, err
+		}
+		// the entry may have been processed in the meantime, its payload is removed then
+		updateFilter := bson.M{"_id": entry.ID, "payload": cur.Current.Lookup("payload")}
+		updateCtx, cancel := dbService.getContext()
+		_, err := dbService.collectionRefStudyEventInbox().UpdateOne(updateCtx, updateFilter, bson.M{"$set": bson.M{"payload": entry.Payload}})
+		cancel()
+		if err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, cur.Err()
+}
@@ -0,0 +1,91 @@
+package db
+
+import (
+	"errors"
+	"reflect"
+
+	"github.com/influenzanet/study-service/pkg/studyengine"
+	"github.com/tekenradar/researcher-backend/pkg/encryption"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsoncodec"
+	"go.mongodb.org/mongo-driver/bson/bsonrw"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+// payloads of study events in the inbox contain the survey responses with all contact data, they are
+// encrypted like the contact data if encryption is configured
+
+// plainStudyEventPayload has the same fields, but not the custom codec
+type plainStudyEventPayload studyengine.ExternalEventPayload
+
+type encryptedStudyEventPayload struct {
+	Encrypted encryption.EncryptedValue `bson:"encrypted"`
+}
+
+var (
+	tStudyEventPayload          = reflect.TypeOf(studyengine.ExternalEventPayload{})
+	tPlainStudyEventPayload     = reflect.TypeOf(plainStudyEventPayload{})
+	tEncryptedStudyEventPayload = reflect.TypeOf(encryptedStudyEventPayload{})
+)
+
+type studyEventPayloadCodec struct{}
+
+func (studyEventPayloadCodec) EncodeValue(ec bsoncodec.EncodeContext, vw bsonrw.ValueWriter, val reflect.Value) error {
+	if !val.IsValid() || val.Type() != tStudyEventPayload {
+		return bsoncodec.ValueEncoderError{Name: "studyEventPayloadCodec.EncodeValue", Types: []reflect.Type{tStudyEventPayload}, Received: val}
+	}
+	payload := plainStudyEventPayload(val.Interface().(studyengine.ExternalEventPayload))
+
+	if !encryption.Enabled() {
+		enc, err := ec.LookupEncoder(tPlainStudyEventPayload)
+		if err != nil {
+			return err
+		}
+		return enc.EncodeValue(ec, vw, reflect.ValueOf(payload))
+	}
+
+	plaintext, err := bson.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	encrypted, err := encryption.Encrypt(plaintext)
+	if err != nil {
+		return err
+	}
+	enc, err := ec.LookupEncoder(tEncryptedStudyEventPayload)
+	if err != nil {
+		return err
+	}
+	return enc.EncodeValue(ec, vw, reflect.ValueOf(encryptedStudyEventPayload{Encrypted: encrypted}))
+}
+
+func (studyEventPayloadCodec) DecodeValue(dc bsoncodec.DecodeContext, vr bsonrw.ValueReader, val reflect.Value) error {
+	if !val.CanSet() || val.Type() != tStudyEventPayload {
+		return bsoncodec.ValueDecoderError{Name: "studyEventPayloadCodec.DecodeValue", Types: []reflect.Type{tStudyEventPayload}, Received: val}
+	}
+	if vr.Type() != bsontype.EmbeddedDocument {
+		return errors.New("study event payload must be a document, got " + vr.Type().String())
+	}
+	raw, err := bsonrw.Copier{}.CopyDocumentToBytes(vr)
+	if err != nil {
+		return err
+	}
+
+	if encryptedRaw, err := bson.Raw(raw).LookupErr("encrypted"); err == nil {
+		var encrypted encryption.EncryptedValue
+		if err := encryptedRaw.Unmarshal(&encrypted); err != nil {
+			return err
+		}
+		raw, err = encryption.Decrypt(encrypted)
+		if err != nil {
+			return err
+		}
+	}
+
+	var payload plainStudyEventPayload
+	if err := bson.Unmarshal(raw, &payload); err != nil {
+		return err
+	}
+	val.Set(reflect.ValueOf(studyengine.ExternalEventPayload(payload)))
+	return nil
+}
@@ -0,0 +1,77 @@
+package db
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/influenzanet/study-service/pkg/studyengine"
+	studyTypes "github.com/influenzanet/study-service/pkg/types"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type testStudyEventDocument struct {
+	Payload studyengine.ExternalEventPayload `bson:"payload"`
+}
+
+func TestStudyEventPayloadCodec(t *testing.T) {
+	payload := studyengine.ExternalEventPayload{
+		ParticipantState: studyTypes.ParticipantState{ParticipantID: "participant-1", EnteredAt: 1650000000},
+		EventType:        "SUBMIT",
+		StudyKey:         "tekenradar",
+		InstanceID:       "instance",
+		Response:         studyTypes.SurveyResponse{Key: "T0_Invites", ParticipantID: "participant-1", SubmittedAt: 1650000100},
+	}
+
+	tests := []struct {
+		name      string
+		encrypted bool
+	}{
+		{name: "plain", encrypted: false},
+		{name: "encrypted", encrypted: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.encrypted {
+				initTestEncryption(t)
+			}
+
+			raw, err := bson.MarshalWithRegistry(registry, testStudyEventDocument{Payload: payload})
+			if err != nil {
+				t.Fatal(err)
+			}
+			stored := bson.Raw(raw).Lookup("payload").Document()
+			if _, ok := stored.Lookup("encrypted").DocumentOK(); ok != tt.encrypted {
+				t.Fatalf("stored document: %s", stored)
+			}
+			if tt.encrypted && bytes.Contains(raw, []byte("participant-1")) {
+				t.Error("participant ID stored in plain text")
+			}
+
+			var decoded testStudyEventDocument
+			if err := bson.UnmarshalWithRegistry(registry, raw, &decoded); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(decoded.Payload, payload) {
+				t.Errorf("decoded %+v, want %+v", decoded.Payload, payload)
+			}
+		})
+	}
+
+	t.Run("plain payloads are read with encryption enabled", func(t *testing.T) {
+		raw, err := bson.MarshalWithRegistry(registry, testStudyEventDocument{Payload: payload})
+		if err != nil {
+			t.Fatal(err)
+		}
+		initTestEncryption(t)
+
+		var decoded testStudyEventDocument
+		if err := bson.UnmarshalWithRegistry(registry, raw, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(decoded.Payload, payload) {
+			t.Errorf("decoded %+v, want %+v", decoded.Payload, payload)
+		}
+	})
+}
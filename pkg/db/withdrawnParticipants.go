@@ -0,0 +1,49 @@
+package db
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/tekenradar/researcher-backend/pkg/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func participantIDHash(participantID string) string {
+	h := sha256.Sum256([]byte(participantID))
+	return hex.EncodeToString(h[:])
+}
+
+// AddWithdrawnParticipant marks the participant as withdrawn at the given time, or updates the time if it is later
+func (dbService *ResearcherDBService) AddWithdrawnParticipant(participantID string, withdrawnAt int64) error {
+	ctx, cancel := dbService.getContext()
+	defer cancel()
+
+	dbService.collectionRefWithdrawnParticipants().Indexes().CreateOne(ctx,
+		mongo.IndexModel{
+			Keys:    bson.D{{Key: "participantIDHash", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		})
+
+	filter := bson.M{"participantIDHash": participantIDHash(participantID)}
+	update := bson.M{"$max": bson.M{"withdrawnAt": withdrawnAt}}
+	_, err := dbService.collectionRefWithdrawnParticipants().UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	return err
+}
+
+// FindParticipantWithdrawnAt returns the time of the participant's latest withdrawal, or 0 if the participant
+// never withdrew
+func (dbService *ResearcherDBService) FindParticipantWithdrawnAt(participantID string) (int64, error) {
+	ctx, cancel := dbService.getContext()
+	defer cancel()
+
+	filter := bson.M{"participantIDHash": participantIDHash(participantID)}
+
+	elem := types.WithdrawnParticipant{}
+	err := dbService.collectionRefWithdrawnParticipants().FindOne(ctx, filter).Decode(&elem)
+	if err == mongo.ErrNoDocuments {
+		return 0, nil
+	}
+	return elem.WithdrawnAt, err
+}
@@ -0,0 +1,206 @@
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/tekenradar/researcher-backend/pkg/types"
+)
+
+// Envelope encryption: every value is encrypted with its own data encryption key (DEK), which is stored
+// next to it, encrypted ("wrapped") with a key encryption key (KEK) from the configuration.
+
+const keySize = 32 // AES-256
+
+var ErrNotConfigured = errors.New("contact data encryption is not configured")
+
+// EncryptedValue is stored in place of the plain value
+type EncryptedValue struct {
+	KeyID      string `bson:"keyID" json:"keyID"`           // KEK used to wrap the DEK
+	WrappedKey []byte `bson:"wrappedKey" json:"wrappedKey"` // nonce + DEK encrypted with the KEK
+	Ciphertext []byte `bson:"ciphertext" json:"ciphertext"` // nonce + value encrypted with the DEK
+}
+
+type keyRing struct {
+	keks          map[string][]byte
+	activeKeyID   string
+	blindIndexKey []byte
+}
+
+var keys *keyRing
+
+// InitKeys loads the KEKs and the blind index key. Without configured KEKs, encryption stays disabled.
+func InitKeys(conf types.ContactDataEncryptionConfig) error {
+	if len(conf.KEKFiles) == 0 {
+		keys = nil
+		return nil
+	}
+
+	ring := &keyRing{
+		keks:        map[string][]byte{},
+		activeKeyID: conf.ActiveKEKID,
+	}
+	for kid, path := range conf.KEKFiles {
+		key, err := readKeyFile(path)
+		if err != nil {
+			return fmt.Errorf("KEK '%s': %v", kid, err)
+		}
+		ring.keks[kid] = key
+	}
+	if _, ok := ring.keks[ring.activeKeyID]; !ok {
+		return fmt.Errorf("active KEK '%s' is not configured", ring.activeKeyID)
+	}
+
+	if len(conf.BlindIndexKeyFile) == 0 {
+		return errors.New("blind index key file is not configured")
+	}
+	key, err := readKeyFile(conf.BlindIndexKeyFile)
+	if err != nil {
+		return fmt.Errorf("blind index key: %v", err)
+	}
+	ring.blindIndexKey = key
+
+	keys = ring
+	return nil
+}
+
+// readKeyFile reads a base64 encoded 256 bit key
+func readKeyFile(path string) ([]byte, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(content)))
+	if err != nil {
+		return nil, fmt.Errorf("key must be base64 encoded: %v", err)
+	}
+	if len(key) != keySize {
+		return nil, fmt.Errorf("key must be %d bytes long, got %d", keySize, len(key))
+	}
+	return key, nil
+}
+
+func Enabled() bool {
+	return keys != nil
+}
+
+func ActiveKeyID() string {
+	if keys == nil {
+		return ""
+	}
+	return keys.activeKeyID
+}
+
+// Encrypt encrypts the value with a new DEK, wrapped with the active KEK
+func Encrypt(plaintext []byte) (EncryptedValue, error) {
+	if keys == nil {
+		return EncryptedValue{}, ErrNotConfigured
+	}
+
+	dek := make([]byte, keySize)
+	if _, err := rand.Read(dek); err != nil {
+		return EncryptedValue{}, err
+	}
+	ciphertext, err := seal(dek, plaintext)
+	if err != nil {
+		return EncryptedValue{}, err
+	}
+	wrappedKey, err := seal(keys.keks[keys.activeKeyID], dek)
+	if err != nil {
+		return EncryptedValue{}, err
+	}
+	return EncryptedValue{
+		KeyID:      keys.activeKeyID,
+		WrappedKey: wrappedKey,
+		Ciphertext: ciphertext,
+	}, nil
+}
+
+func Decrypt(value EncryptedValue) ([]byte, error) {
+	if keys == nil {
+		return nil, ErrNotConfigured
+	}
+	kek, ok := keys.keks[value.KeyID]
+	if !ok {
+		return nil, fmt.Errorf("unknown KEK '%s'", value.KeyID)
+	}
+	dek, err := open(kek, value.WrappedKey)
+	if err != nil {
+		return nil, fmt.Errorf("cannot unwrap data key: %v", err)
+	}
+	return open(dek, value.Ciphertext)
+}
+
+// Rewrap wraps the data key of the value with the active KEK, the ciphertext stays the same
+func Rewrap(value EncryptedValue) (EncryptedValue, error) {
+	if keys == nil {
+		return EncryptedValue{}, ErrNotConfigured
+	}
+	kek, ok := keys.keks[value.KeyID]
+	if !ok {
+		return EncryptedValue{}, fmt.Errorf("unknown KEK '%s'", value.KeyID)
+	}
+	dek, err := open(kek, value.WrappedKey)
+	if err != nil {
+		return EncryptedValue{}, fmt.Errorf("cannot unwrap data key: %v", err)
+	}
+	wrappedKey, err := seal(keys.keks[keys.activeKeyID], dek)
+	if err != nil {
+		return EncryptedValue{}, err
+	}
+	value.KeyID = keys.activeKeyID
+	value.WrappedKey = wrappedKey
+	return value, nil
+}
+
+// BlindIndex returns a keyed hash of the normalised value (trimmed, lower case), to search for exact matches
+// without decrypting. Empty values have no index.
+func BlindIndex(value string) string {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if keys == nil || len(value) == 0 {
+		return ""
+	}
+	mac := hmac.New(sha256.New, keys.blindIndexKey)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func seal(key []byte, plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func open(key []byte, data []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	return gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
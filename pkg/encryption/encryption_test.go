@@ -0,0 +1,258 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tekenradar/researcher-backend/pkg/types"
+)
+
+func writeTestKey(t *testing.T, dir string, name string) string {
+	t.Helper()
+	key := make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(base64.StdEncoding.EncodeToString(key)+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func mustInitKeys(t *testing.T, conf types.ContactDataEncryptionConfig) {
+	t.Helper()
+	if err := InitKeys(conf); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { keys = nil })
+}
+
+func TestInitKeys(t *testing.T) {
+	dir := t.TempDir()
+	kek := writeTestKey(t, dir, "kek-1")
+	blindIndexKey := writeTestKey(t, dir, "blind-index")
+	shortKey := filepath.Join(dir, "short")
+	if err := os.WriteFile(shortKey, []byte(base64.StdEncoding.EncodeToString(make([]byte, 16))), 0600); err != nil {
+		t.Fatal(err)
+	}
+	notBase64 := filepath.Join(dir, "not-base64")
+	if err := os.WriteFile(notBase64, []byte("%%%"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		conf        types.ContactDataEncryptionConfig
+		wantErr     bool
+		wantEnabled bool
+	}{
+		{
+			name: "not configured",
+			conf: types.ContactDataEncryptionConfig{},
+		},
+		{
+			name:        "valid",
+			conf:        types.ContactDataEncryptionConfig{KEKFiles: map[string]string{"kek-1": kek}, ActiveKEKID: "kek-1", BlindIndexKeyFile: blindIndexKey},
+			wantEnabled: true,
+		},
+		{
+			name:    "unknown active key",
+			conf:    types.ContactDataEncryptionConfig{KEKFiles: map[string]string{"kek-1": kek}, ActiveKEKID: "kek-2", BlindIndexKeyFile: blindIndexKey},
+			wantErr: true,
+		},
+		{
+			name:    "missing blind index key",
+			conf:    types.ContactDataEncryptionConfig{KEKFiles: map[string]string{"kek-1": kek}, ActiveKEKID: "kek-1"},
+			wantErr: true,
+		},
+		{
+			name:    "missing key file",
+			conf:    types.ContactDataEncryptionConfig{KEKFiles: map[string]string{"kek-1": filepath.Join(dir, "missing")}, ActiveKEKID: "kek-1", BlindIndexKeyFile: blindIndexKey},
+			wantErr: true,
+		},
+		{
+			name:    "key too short",
+			conf:    types.ContactDataEncryptionConfig{KEKFiles: map[string]string{"kek-1": shortKey}, ActiveKEKID: "kek-1", BlindIndexKeyFile: blindIndexKey},
+			wantErr: true,
+		},
+		{
+			name:    "key not base64 encoded",
+			conf:    types.ContactDataEncryptionConfig{KEKFiles: map[string]string{"kek-1": kek}, ActiveKEKID: "kek-1", BlindIndexKeyFile: notBase64},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys = nil
+			defer func() { keys = nil }()
+			err := InitKeys(tt.conf)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("InitKeys() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if Enabled() != tt.wantEnabled {
+				t.Errorf("Enabled() = %v, want %v", Enabled(), tt.wantEnabled)
+			}
+		})
+	}
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	dir := t.TempDir()
+	mustInitKeys(t, types.ContactDataEncryptionConfig{
+		KEKFiles:          map[string]string{"kek-1": writeTestKey(t, dir, "kek-1")},
+		ActiveKEKID:       "kek-1",
+		BlindIndexKeyFile: writeTestKey(t, dir, "blind-index"),
+	})
+
+	for _, plaintext := range [][]byte{[]byte("jane@example.org"), {}, bytes.Repeat([]byte{0}, 4096)} {
+		value, err := Encrypt(plaintext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if value.KeyID != "kek-1" {
+			t.Errorf("unexpected key id: %s", value.KeyID)
+		}
+		if len(plaintext) > 0 && bytes.Contains(value.Ciphertext, plaintext) {
+			t.Error("ciphertext contains the plaintext")
+		}
+		decrypted, err := Decrypt(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(decrypted, plaintext) {
+			t.Errorf("decrypted %q, want %q", decrypted, plaintext)
+		}
+	}
+
+	a, _ := Encrypt([]byte("same"))
+	b, _ := Encrypt([]byte("same"))
+	if bytes.Equal(a.Ciphertext, b.Ciphertext) || bytes.Equal(a.WrappedKey, b.WrappedKey) {
+		t.Error("equal values must be encrypted with different data keys")
+	}
+
+	value, _ := Encrypt([]byte("jane@example.org"))
+	tampered := []struct {
+		name  string
+		value EncryptedValue
+	}{
+		{name: "unknown key", value: EncryptedValue{KeyID: "kek-2", WrappedKey: value.WrappedKey, Ciphertext: value.Ciphertext}},
+		{name: "modified ciphertext", value: EncryptedValue{KeyID: value.KeyID, WrappedKey: value.WrappedKey, Ciphertext: flipLastBit(value.Ciphertext)}},
+		{name: "modified data key", value: EncryptedValue{KeyID: value.KeyID, WrappedKey: flipLastBit(value.WrappedKey), Ciphertext: value.Ciphertext}},
+		{name: "ciphertext too short", value: EncryptedValue{KeyID: value.KeyID, WrappedKey: value.WrappedKey, Ciphertext: value.Ciphertext[:4]}},
+	}
+	for _, tt := range tampered {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Decrypt(tt.value); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func flipLastBit(data []byte) []byte {
+	c := append([]byte{}, data...)
+	c[len(c)-1] ^= 1
+	return c
+}
+
+func TestRewrap(t *testing.T) {
+	dir := t.TempDir()
+	kekFiles := map[string]string{
+		"kek-1": writeTestKey(t, dir, "kek-1"),
+		"kek-2": writeTestKey(t, dir, "kek-2"),
+	}
+	blindIndexKey := writeTestKey(t, dir, "blind-index")
+
+	mustInitKeys(t, types.ContactDataEncryptionConfig{KEKFiles: kekFiles, ActiveKEKID: "kek-1", BlindIndexKeyFile: blindIndexKey})
+	value, err := Encrypt([]byte("jane@example.org"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mustInitKeys(t, types.ContactDataEncryptionConfig{KEKFiles: kekFiles, ActiveKEKID: "kek-2", BlindIndexKeyFile: blindIndexKey})
+	rewrapped, err := Rewrap(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rewrapped.KeyID != "kek-2" {
+		t.Errorf("unexpected key id: %s", rewrapped.KeyID)
+	}
+	if !bytes.Equal(rewrapped.Ciphertext, value.Ciphertext) {
+		t.Error("ciphertext changed")
+	}
+
+	// the old KEK is no longer needed
+	mustInitKeys(t, types.ContactDataEncryptionConfig{KEKFiles: map[string]string{"kek-2": kekFiles["kek-2"]}, ActiveKEKID: "kek-2", BlindIndexKeyFile: blindIndexKey})
+	decrypted, err := Decrypt(rewrapped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(decrypted) != "jane@example.org" {
+		t.Errorf("unexpected value: %s", decrypted)
+	}
+	if _, err := Rewrap(value); err == nil {
+		t.Error("expected an error for a value of a removed KEK")
+	}
+}
+
+func TestBlindIndex(t *testing.T) {
+	if got := BlindIndex("jane@example.org"); got != "" {
+		t.Errorf("expected no index without configured keys, got %s", got)
+	}
+
+	dir := t.TempDir()
+	mustInitKeys(t, types.ContactDataEncryptionConfig{
+		KEKFiles:          map[string]string{"kek-1": writeTestKey(t, dir, "kek-1")},
+		ActiveKEKID:       "kek-1",
+		BlindIndexKeyFile: writeTestKey(t, dir, "blind-index"),
+	})
+	index := BlindIndex("jane@example.org")
+
+	tests := []struct {
+		name      string
+		value     string
+		wantEqual bool
+		wantEmpty bool
+	}{
+		{name: "same value", value: "jane@example.org", wantEqual: true},
+		{name: "different case", value: "Jane@Example.org", wantEqual: true},
+		{name: "surrounding spaces", value: "  jane@example.org\t", wantEqual: true},
+		{name: "different value", value: "john@example.org"},
+		{name: "part of the value", value: "jane"},
+		{name: "empty", value: "", wantEmpty: true},
+		{name: "only spaces", value: "   ", wantEmpty: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := BlindIndex(tt.value)
+			if tt.wantEmpty {
+				if got != "" {
+					t.Errorf("expected no index, got %s", got)
+				}
+				return
+			}
+			if (got == index) != tt.wantEqual {
+				t.Errorf("BlindIndex(%q) = %s, index of the value %s", tt.value, got, index)
+			}
+		})
+	}
+}
+
+func TestNotConfigured(t *testing.T) {
+	keys = nil
+	if _, err := Encrypt([]byte("a")); err != ErrNotConfigured {
+		t.Errorf("Encrypt: expected ErrNotConfigured, got %v", err)
+	}
+	if _, err := Decrypt(EncryptedValue{}); err != ErrNotConfigured {
+		t.Errorf("Decrypt: expected ErrNotConfigured, got %v", err)
+	}
+	if _, err := Rewrap(EncryptedValue{}); err != ErrNotConfigured {
+		t.Errorf("Rewrap: expected ErrNotConfigured, got %v", err)
+	}
+}
@@ -7,9 +7,10 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/tekenradar/researcher-backend/pkg/db"
 	"github.com/tekenradar/researcher-backend/pkg/jwt"
+	"github.com/tekenradar/researcher-backend/pkg/types"
 )
 
-// ValidateToken reads the token from the request and validates it
+// HasAccessToStudy checks if the user has any role in the substudy, and stores the study info and role in the context
 func HasAccessToStudy(dbRef *db.ResearcherDBService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		substudyKey := c.Param("substudyKey")
@@ -25,7 +26,10 @@ func HasAccessToStudy(dbRef *db.ResearcherDBService) gin.HandlerFunc {
 
 		email := token.ID
 
-		if contains(substudyInfo.AccessControl.Emails, email) {
+		role := substudyInfo.GetRoleOfUser(email)
+		if len(role) > 0 {
+			c.Set("studyInfo", substudyInfo)
+			c.Set("studyRole", role)
 			c.Next()
 			return
 		}
@@ -35,12 +39,18 @@ func HasAccessToStudy(dbRef *db.ResearcherDBService) gin.HandlerFunc {
 	}
 }
 
-// method to check if value is in array
-func contains(s []string, e string) bool {
-	for _, a := range s {
-		if a == e {
-			return true
+// RequireStudyPermission checks if the user's role in the substudy grants the permission - use after HasAccessToStudy
+func RequireStudyPermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.MustGet("validatedToken").(*jwt.UserClaims)
+		role := c.MustGet("studyRole").(string)
+
+		if types.StudyRoleHasPermission(role, permission) {
+			c.Next()
+			return
 		}
+
+		logger.Error.Printf("user %s (%s) tried to use %s in study %s without permission", token.ID, role, permission, c.Param("substudyKey"))
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "missing permission: " + permission})
 	}
-	return false
 }
@@ -2,35 +2,52 @@ package middlewares
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/coneno/logger"
 	"github.com/gin-gonic/gin"
+	"github.com/tekenradar/researcher-backend/pkg/db"
+	"github.com/tekenradar/researcher-backend/pkg/http/utils"
 )
 
-func HasValidAPIKey(validKeys []string) gin.HandlerFunc {
+// HasValidAPIKey checks if the request has an active API key with the required scope
+func HasValidAPIKey(dbRef *db.ResearcherDBService, scope string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		req := c.Request
 
 		keysInHeader, ok := req.Header["Api-Key"]
 		if !ok || len(keysInHeader) < 1 {
 			logger.Warning.Println("request made without a valid API key")
-			c.JSON(http.StatusBadRequest, gin.H{"error": "API key missing"})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "API key missing"})
 			c.Abort()
 			return
 		}
 
+		now := time.Now().Unix()
 		for _, k := range keysInHeader {
-			for _, vk := range validKeys {
-				if k == vk {
-					c.Next()
-					return
-				}
+			apiKey, err := dbRef.FindAPIKeyByHash(utils.HashToken(k))
+			if err != nil || !apiKey.IsActive(now) {
+				continue
 			}
+
+			if !apiKey.HasScope(scope) {
+				logger.Warning.Printf("API key '%s' used without scope %s", apiKey.Name, scope)
+				c.JSON(http.StatusForbidden, gin.H{"error": "API key is not allowed to use this endpoint"})
+				c.Abort()
+				return
+			}
+
+			if err := dbRef.UpdateAPIKeyLastUsed(apiKey.ID); err != nil {
+				logger.Error.Printf("error: %v", err)
+			}
+			c.Set("apiKeyName", apiKey.Name)
+			c.Next()
+			return
 		}
 
 		// If no keys matched:
 		logger.Warning.Println("request made without a valid API key")
-		c.JSON(http.StatusBadRequest, gin.H{"error": "A valid API key missing"})
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "A valid API key missing"})
 		c.Abort()
 	}
 }
@@ -5,16 +5,26 @@ import (
 
 	"github.com/coneno/logger"
 	"github.com/gin-gonic/gin"
+	"github.com/tekenradar/researcher-backend/pkg/db"
 	"github.com/tekenradar/researcher-backend/pkg/jwt"
 )
 
-// ValidateToken reads the token from the request and validates it
-func IsAdmin() gin.HandlerFunc {
+// IsAdmin checks if the token has the admin role, and the user is still listed as admin in the DB
+func IsAdmin(dbRef *db.ResearcherDBService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		token := c.MustGet("validatedToken").(*jwt.UserClaims)
 
 		for _, r := range token.Roles {
-			if r == jwt.ROLE_ADMIN {
+			if r != jwt.ROLE_ADMIN {
+				continue
+			}
+			isAdmin, err := dbRef.IsAdminUser(token.ID)
+			if err != nil {
+				logger.Error.Printf("error: %v", err)
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if isAdmin {
 				c.Next()
 				return
 			}
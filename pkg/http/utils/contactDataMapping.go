@@ -0,0 +1,251 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/influenzanet/study-service/pkg/studyengine"
+	"github.com/tekenradar/researcher-backend/pkg/types"
+)
+
+type contactFieldKind int
+
+const (
+	contactFieldText contactFieldKind = iota
+	contactFieldNumber
+	contactFieldBool
+)
+
+// contactDataTargets lists the fields of the participant contact that can be filled by a mapping
+var contactDataTargets = map[string]contactFieldKind{
+	"general.age":                     contactFieldNumber,
+	"general.gender":                  contactFieldText,
+	"general.otherStudies":            contactFieldBool,
+	"contactData.firstName":           contactFieldText,
+	"contactData.lastName":            contactFieldText,
+	"contactData.birthday":            contactFieldNumber,
+	"contactData.email":               contactFieldText,
+	"contactData.phone":               contactFieldText,
+	"contactData.gender":              contactFieldText,
+	"contactData.gp.office":           contactFieldText,
+	"contactData.gp.name":             contactFieldText,
+	"contactData.gp.phone":            contactFieldText,
+	"contactData.gp.address.street":   contactFieldText,
+	"contactData.gp.address.nr":       contactFieldText,
+	"contactData.gp.address.postcode": contactFieldText,
+	"contactData.gp.address.city":     contactFieldText,
+}
+
+// DefaultContactDataMapping is used for studies without their own mapping (contact details survey of tekenradar)
+var DefaultContactDataMapping = []types.ContactDataMapping{
+	{Target: "general.age", Source: types.CONTACT_MAPPING_SOURCE_FLAG, FlagKey: "ageFromPDiff", Transform: types.CONTACT_MAPPING_TRANSFORM_NUMBER},
+	{Target: "general.gender", Source: types.CONTACT_MAPPING_SOURCE_FLAG, FlagKey: "gender"},
+	{Target: "general.otherStudies", Source: types.CONTACT_MAPPING_SOURCE_FLAG, FlagKey: "consentAdditionalStudies", Transform: types.CONTACT_MAPPING_TRANSFORM_BOOL},
+	{Target: "contactData.firstName", Source: types.CONTACT_MAPPING_SOURCE_RESPONSE, ItemKey: "Contactgegevens.Naam", SlotKey: "rg.cloze.vn"},
+	{Target: "contactData.lastName", Source: types.CONTACT_MAPPING_SOURCE_RESPONSE, ItemKey: "Contactgegevens.Naam", SlotKey: "rg.cloze.an"},
+	{Target: "contactData.email", Source: types.CONTACT_MAPPING_SOURCE_RESPONSE, ItemKey: "Contactgegevens.Email", SlotKey: "rg.ic"},
+	{Target: "contactData.phone", Source: types.CONTACT_MAPPING_SOURCE_RESPONSE, ItemKey: "Contactgegevens.Tel", SlotKey: "rg.ic"},
+	{Target: "contactData.gender", Source: types.CONTACT_MAPPING_SOURCE_RESPONSE, ItemKey: "Contactgegevens.GENDER", Transform: types.CONTACT_MAPPING_TRANSFORM_SINGLE_CHOICE, Mapping: map[string]string{
+		"a": "male",
+		"b": "female",
+		"c": "other",
+	}},
+	{Target: "contactData.birthday", Source: types.CONTACT_MAPPING_SOURCE_RESPONSE, ItemKey: "Contactgegevens.Birthday", SlotKey: "rg.date", Transform: types.CONTACT_MAPPING_TRANSFORM_DATE},
+	{Target: "contactData.gp.office", Source: types.CONTACT_MAPPING_SOURCE_RESPONSE, ItemKey: "Contactgegevens.GP", SlotKey: "rg.cloze.pn"},
+	{Target: "contactData.gp.name", Source: types.CONTACT_MAPPING_SOURCE_RESPONSE, ItemKey: "Contactgegevens.GP", SlotKey: "rg.cloze.nh"},
+	{Target: "contactData.gp.phone", Source: types.CONTACT_MAPPING_SOURCE_RESPONSE, ItemKey: "Contactgegevens.GP", SlotKey: "rg.cloze.tel"},
+	{Target: "contactData.gp.address.street", Source: types.CONTACT_MAPPING_SOURCE_RESPONSE, ItemKey: "Contactgegevens.GP", SlotKey: "rg.cloze.str"},
+	{Target: "contactData.gp.address.nr", Source: types.CONTACT_MAPPING_SOURCE_RESPONSE, ItemKey: "Contactgegevens.GP", SlotKey: "rg.cloze.hnr"},
+	{Target: "contactData.gp.address.postcode", Source: types.CONTACT_MAPPING_SOURCE_RESPONSE, ItemKey: "Contactgegevens.GP", SlotKey: "rg.cloze.pc"},
+	{Target: "contactData.gp.address.city", Source: types.CONTACT_MAPPING_SOURCE_RESPONSE, ItemKey: "Contactgegevens.GP", SlotKey: "rg.cloze.plaats"},
+}
+
+// ValidateContactDataMapping checks that all targets, sources and transforms are known and fit together
+func ValidateContactDataMapping(mappings []types.ContactDataMapping) error {
+	for i, m := range mappings {
+		kind, ok := contactDataTargets[m.Target]
+		if !ok {
+			return fmt.Errorf("mapping %d: unknown target '%s'", i, m.Target)
+		}
+
+		switch m.Source {
+		case types.CONTACT_MAPPING_SOURCE_FLAG:
+			if len(m.FlagKey) < 1 {
+				return fmt.Errorf("mapping %d (%s): flagKey missing", i, m.Target)
+			}
+			if m.Transform == types.CONTACT_MAPPING_TRANSFORM_SINGLE_CHOICE {
+				return fmt.Errorf("mapping %d (%s): singleChoice can only be used for responses", i, m.Target)
+			}
+		case types.CONTACT_MAPPING_SOURCE_RESPONSE:
+			if len(m.ItemKey) < 1 {
+				return fmt.Errorf("mapping %d (%s): itemKey missing", i, m.Target)
+			}
+			if len(m.SlotKey) < 1 && m.Transform != types.CONTACT_MAPPING_TRANSFORM_SINGLE_CHOICE {
+				return fmt.Errorf("mapping %d (%s): slotKey missing", i, m.Target)
+			}
+		default:
+			return fmt.Errorf("mapping %d (%s): unknown source '%s'", i, m.Target, m.Source)
+		}
+
+		switch m.Transform {
+		case "", types.CONTACT_MAPPING_TRANSFORM_TEXT:
+			if kind != contactFieldText {
+				return fmt.Errorf("mapping %d (%s): text cannot be used for this target", i, m.Target)
+			}
+		case types.CONTACT_MAPPING_TRANSFORM_SINGLE_CHOICE:
+			if kind != contactFieldText {
+				return fmt.Errorf("mapping %d (%s): singleChoice cannot be used for this target", i, m.Target)
+			}
+			if len(m.Mapping) < 1 {
+				return fmt.Errorf("mapping %d (%s): mapping for singleChoice missing", i, m.Target)
+			}
+		case types.CONTACT_MAPPING_TRANSFORM_NUMBER, types.CONTACT_MAPPING_TRANSFORM_DATE:
+			if kind != contactFieldNumber {
+				return fmt.Errorf("mapping %d (%s): %s cannot be used for this target", i, m.Target, m.Transform)
+			}
+		case types.CONTACT_MAPPING_TRANSFORM_BOOL:
+			if kind != contactFieldBool {
+				return fmt.Errorf("mapping %d (%s): bool cannot be used for this target", i, m.Target)
+			}
+		default:
+			return fmt.Errorf("mapping %d (%s): unknown transform '%s'", i, m.Target, m.Transform)
+		}
+	}
+	return nil
+}
+
+// ApplyContactDataMapping fills the participant contact with the values found in the event.
+// Values that cannot be found or converted are skipped and reported as warnings.
+func ApplyContactDataMapping(pc *types.ParticipantContact, event studyengine.ExternalEventPayload, mappings []types.ContactDataMapping) (warnings []string) {
+	warnings = []string{}
+	if pc.General == nil {
+		pc.General = &types.ContactDetailsGeneralData{}
+	}
+	if pc.ContactData == nil {
+		pc.ContactData = &types.ContactDetailsContactData{}
+	}
+
+	for _, m := range mappings {
+		// as before mappings were introduced, the GP infos are created as soon as the item is in the response,
+		// even if all of its slots are empty
+		if strings.HasPrefix(m.Target, "contactData.gp.") && m.Source == types.CONTACT_MAPPING_SOURCE_RESPONSE && pc.ContactData.GP == nil {
+			if _, err := FindSurveyItemResponse(event.Response.Responses, m.ItemKey); err == nil {
+				pc.ContactData.GP = &types.GPInfos{}
+			}
+		}
+
+		raw, err := readMappingSourceValue(event, m)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("%s: %v", m.Target, err))
+			continue
+		}
+
+		if err := setContactDataTarget(pc, m, raw); err != nil {
+			warnings = append(warnings, fmt.Sprintf("%s: %v", m.Target, err))
+		}
+	}
+	return warnings
+}
+
+func readMappingSourceValue(event studyengine.ExternalEventPayload, m types.ContactDataMapping) (string, error) {
+	switch m.Source {
+	case types.CONTACT_MAPPING_SOURCE_FLAG:
+		v, ok := event.ParticipantState.Flags[m.FlagKey]
+		if !ok {
+			return "", fmt.Errorf("flag '%s' not found", m.FlagKey)
+		}
+		return v, nil
+	case types.CONTACT_MAPPING_SOURCE_RESPONSE:
+		if m.Transform == types.CONTACT_MAPPING_TRANSFORM_SINGLE_CHOICE {
+			slotKey := m.SlotKey
+			if len(slotKey) < 1 {
+				slotKey = "rg.scg"
+			}
+			return MapSingleChoiceSlot(event.Response.Responses, m.ItemKey, slotKey, m.Mapping)
+		}
+		return ExtractResponseValue(event.Response.Responses, m.ItemKey, m.SlotKey)
+	}
+	return "", fmt.Errorf("unknown source '%s'", m.Source)
+}
+
+func convertMappingNumber(m types.ContactDataMapping, raw string) (int64, error) {
+	if m.Transform == types.CONTACT_MAPPING_TRANSFORM_DATE {
+		if n, err := strconv.ParseInt(raw, 10, 64); err == nil {
+			return n, nil
+		}
+		t, err := time.Parse("2006-01-02", raw)
+		if err != nil {
+			return 0, fmt.Errorf("'%s' is not a date", raw)
+		}
+		return t.Unix(), nil
+	}
+
+	n, err := strconv.ParseInt(strings.Split(strings.TrimSpace(raw), ".")[0], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("'%s' is not a number", raw)
+	}
+	return n, nil
+}
+
+func setContactDataTarget(pc *types.ParticipantContact, m types.ContactDataMapping, raw string) error {
+	kind, ok := contactDataTargets[m.Target]
+	if !ok {
+		return errors.New("unknown target")
+	}
+
+	switch kind {
+	case contactFieldNumber:
+		n, err := convertMappingNumber(m, raw)
+		if err != nil {
+			return err
+		}
+		switch m.Target {
+		case "general.age":
+			pc.General.Age = int(n)
+		case "contactData.birthday":
+			pc.ContactData.Birthday = n
+		}
+		return nil
+	case contactFieldBool:
+		if m.Target == "general.otherStudies" {
+			pc.General.OtherStudies = raw == "true"
+		}
+		return nil
+	}
+
+	if strings.HasPrefix(m.Target, "contactData.gp.") && pc.ContactData.GP == nil {
+		pc.ContactData.GP = &types.GPInfos{}
+	}
+	switch m.Target {
+	case "general.gender":
+		pc.General.Gender = raw
+	case "contactData.firstName":
+		pc.ContactData.FirstName = raw
+	case "contactData.lastName":
+		pc.ContactData.LastName = raw
+	case "contactData.email":
+		pc.ContactData.Email = raw
+	case "contactData.phone":
+		pc.ContactData.Phone = raw
+	case "contactData.gender":
+		pc.ContactData.Gender = raw
+	case "contactData.gp.office":
+		pc.ContactData.GP.Office = raw
+	case "contactData.gp.name":
+		pc.ContactData.GP.Name = raw
+	case "contactData.gp.phone":
+		pc.ContactData.GP.Phone = raw
+	case "contactData.gp.address.street":
+		pc.ContactData.GP.Address.Street = raw
+	case "contactData.gp.address.nr":
+		pc.ContactData.GP.Address.Nr = raw
+	case "contactData.gp.address.postcode":
+		pc.ContactData.GP.Address.Postcode = raw
+	case "contactData.gp.address.city":
+		pc.ContactData.GP.Address.City = raw
+	}
+	return nil
+}
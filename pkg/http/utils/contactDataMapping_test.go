@@ -0,0 +1,147 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/influenzanet/study-service/pkg/studyengine"
+	studyTypes "github.com/influenzanet/study-service/pkg/types"
+	"github.com/tekenradar/researcher-backend/pkg/types"
+)
+
+func responseSlot(key string, value string) *studyTypes.ResponseItem {
+	return &studyTypes.ResponseItem{Key: key, Value: value}
+}
+
+func clozeResponse(itemKey string, slots ...*studyTypes.ResponseItem) studyTypes.SurveyItemResponse {
+	return studyTypes.SurveyItemResponse{
+		Key: itemKey,
+		Response: &studyTypes.ResponseItem{Key: "rg", Items: []*studyTypes.ResponseItem{
+			{Key: "cloze", Items: slots},
+		}},
+	}
+}
+
+// contactDetailsEvent returns an event as sent for the tekenradar contact details survey
+func contactDetailsEvent(withGP bool, gpSlots ...*studyTypes.ResponseItem) studyengine.ExternalEventPayload {
+	responses := []studyTypes.SurveyItemResponse{
+		clozeResponse("T0_Invites.Contactgegevens.Naam", responseSlot("vn", "Jane"), responseSlot("an", "Doe")),
+		{Key: "T0_Invites.Contactgegevens.Email", Response: &studyTypes.ResponseItem{Key: "rg", Items: []*studyTypes.ResponseItem{responseSlot("ic", "jane@example.org")}}},
+		{Key: "T0_Invites.Contactgegevens.Tel", Response: &studyTypes.ResponseItem{Key: "rg", Items: []*studyTypes.ResponseItem{responseSlot("ic", "0612345678")}}},
+		{Key: "T0_Invites.Contactgegevens.GENDER", Response: &studyTypes.ResponseItem{Key: "rg", Items: []*studyTypes.ResponseItem{
+			{Key: "scg", Items: []*studyTypes.ResponseItem{{Key: "b"}}},
+		}}},
+		{Key: "T0_Invites.Contactgegevens.Birthday", Response: &studyTypes.ResponseItem{Key: "rg", Items: []*studyTypes.ResponseItem{responseSlot("date", "631152000")}}},
+	}
+	if withGP {
+		responses = append(responses, clozeResponse("T0_Invites.Contactgegevens.GP", gpSlots...))
+	}
+
+	return studyengine.ExternalEventPayload{
+		ParticipantState: studyTypes.ParticipantState{
+			ParticipantID: "p1",
+			Flags: map[string]string{
+				"ageFromPDiff":             "34.6",
+				"gender":                   "female",
+				"consentAdditionalStudies": "true",
+			},
+		},
+		Response: studyTypes.SurveyResponse{Key: "T0_Invites", Responses: responses},
+	}
+}
+
+func TestDefaultContactDataMappingIsValid(t *testing.T) {
+	if err := ValidateContactDataMapping(DefaultContactDataMapping); err != nil {
+		t.Errorf("default mapping invalid: %v", err)
+	}
+}
+
+func TestApplyContactDataMapping(t *testing.T) {
+	general := &types.ContactDetailsGeneralData{Age: 34, Gender: "female", OtherStudies: true}
+	contactData := func(gp *types.GPInfos) *types.ContactDetailsContactData {
+		return &types.ContactDetailsContactData{
+			FirstName: "Jane",
+			LastName:  "Doe",
+			Birthday:  631152000,
+			Email:     "jane@example.org",
+			Phone:     "0612345678",
+			Gender:    "female",
+			GP:        gp,
+		}
+	}
+
+	tests := []struct {
+		name         string
+		event        studyengine.ExternalEventPayload
+		mapping      []types.ContactDataMapping
+		wantGeneral  *types.ContactDetailsGeneralData
+		wantContact  *types.ContactDetailsContactData
+		wantWarnings int
+	}{
+		{
+			name: "default mapping with GP",
+			event: contactDetailsEvent(true,
+				responseSlot("pn", "Praktijk Centrum"),
+				responseSlot("nh", "Dr. Smit"),
+				responseSlot("tel", "0201234567"),
+				responseSlot("str", "Dorpsstraat"),
+				responseSlot("hnr", "12a"),
+				responseSlot("pc", "1234 AB"),
+				responseSlot("plaats", "Utrecht"),
+			),
+			mapping:     DefaultContactDataMapping,
+			wantGeneral: general,
+			wantContact: contactData(&types.GPInfos{
+				Office:  "Praktijk Centrum",
+				Name:    "Dr. Smit",
+				Phone:   "0201234567",
+				Address: types.Address{Street: "Dorpsstraat", Nr: "12a", Postcode: "1234 AB", City: "Utrecht"},
+			}),
+		},
+		{
+			name:         "default mapping with empty GP item",
+			event:        contactDetailsEvent(true),
+			mapping:      DefaultContactDataMapping,
+			wantGeneral:  general,
+			wantContact:  contactData(&types.GPInfos{}),
+			wantWarnings: 7,
+		},
+		{
+			name:         "default mapping without GP item",
+			event:        contactDetailsEvent(false),
+			mapping:      DefaultContactDataMapping,
+			wantGeneral:  general,
+			wantContact:  contactData(nil),
+			wantWarnings: 7,
+		},
+		{
+			name:  "values that cannot be converted are skipped",
+			event: contactDetailsEvent(false),
+			mapping: []types.ContactDataMapping{
+				{Target: "general.age", Source: types.CONTACT_MAPPING_SOURCE_FLAG, FlagKey: "gender", Transform: types.CONTACT_MAPPING_TRANSFORM_NUMBER},
+				{Target: "contactData.birthday", Source: types.CONTACT_MAPPING_SOURCE_RESPONSE, ItemKey: "Contactgegevens.Naam", SlotKey: "rg.cloze.vn", Transform: types.CONTACT_MAPPING_TRANSFORM_DATE},
+				{Target: "contactData.gender", Source: types.CONTACT_MAPPING_SOURCE_RESPONSE, ItemKey: "Contactgegevens.GENDER", Transform: types.CONTACT_MAPPING_TRANSFORM_SINGLE_CHOICE, Mapping: map[string]string{"a": "male"}},
+				{Target: "general.gender", Source: types.CONTACT_MAPPING_SOURCE_FLAG, FlagKey: "missing"},
+			},
+			wantGeneral:  &types.ContactDetailsGeneralData{},
+			wantContact:  &types.ContactDetailsContactData{},
+			wantWarnings: 4,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pc := types.ParticipantContact{}
+			warnings := ApplyContactDataMapping(&pc, tt.event, tt.mapping)
+			if len(warnings) != tt.wantWarnings {
+				t.Errorf("got %d warnings, want %d: %v", len(warnings), tt.wantWarnings, warnings)
+			}
+			if !reflect.DeepEqual(pc.General, tt.wantGeneral) {
+				t.Errorf("general = %+v, want %+v", pc.General, tt.wantGeneral)
+			}
+			if !reflect.DeepEqual(pc.ContactData, tt.wantContact) {
+				t.Errorf("contact data = %+v, want %+v", pc.ContactData, tt.wantContact)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tekenradar/researcher-backend/pkg/types"
+)
+
+type contactExportColumn struct {
+	Key   string
+	Value func(pc types.ParticipantContact) string
+}
+
+func formatUnixDate(t int64) string {
+	if t == 0 {
+		return ""
+	}
+	return time.Unix(t, 0).UTC().Format("2006-01-02")
+}
+
+func formatUnixTime(t int64) string {
+	if t == 0 {
+		return ""
+	}
+	return time.Unix(t, 0).UTC().Format(time.RFC3339)
+}
+
+func contactData(pc types.ParticipantContact) types.ContactDetailsContactData {
+	if pc.ContactData == nil {
+		return types.ContactDetailsContactData{}
+	}
+	return *pc.ContactData
+}
+
+func gpInfos(pc types.ParticipantContact) types.GPInfos {
+	if pc.ContactData == nil || pc.ContactData.GP == nil {
+		return types.GPInfos{}
+	}
+	return *pc.ContactData.GP
+}
+
+func generalData(pc types.ParticipantContact) types.ContactDetailsGeneralData {
+	if pc.General == nil {
+		return types.ContactDetailsGeneralData{}
+	}
+	return *pc.General
+}
+
+// contactExportColumns in the default order of the export
+var contactExportColumns = []contactExportColumn{
+	{"id", func(pc types.ParticipantContact) string { return pc.ID.Hex() }},
+	{"addedAt", func(pc types.ParticipantContact) string { return formatUnixTime(pc.AddedAt) }},
+	{"updatedAt", func(pc types.ParticipantContact) string { return formatUnixTime(pc.UpdatedAt) }},
+	{"expiresAt", func(pc types.ParticipantContact) string { return formatUnixTime(pc.ExpiresAt) }},
+	{"participantID", func(pc types.ParticipantContact) string { return pc.ParticipantID }},
+	{"status", func(pc types.ParticipantContact) string { return pc.Status }},
+	{"keepContactData", func(pc types.ParticipantContact) string { return strconv.FormatBool(pc.KeepContactData) }},
+	{"age", func(pc types.ParticipantContact) string { return strconv.Itoa(generalData(pc).Age) }},
+	{"gender", func(pc types.ParticipantContact) string { return generalData(pc).Gender }},
+	{"otherStudies", func(pc types.ParticipantContact) string { return strconv.FormatBool(generalData(pc).OtherStudies) }},
+	{"firstName", func(pc types.ParticipantContact) string { return contactData(pc).FirstName }},
+	{"lastName", func(pc types.ParticipantContact) string { return contactData(pc).LastName }},
+	{"birthday", func(pc types.ParticipantContact) string { return formatUnixDate(contactData(pc).Birthday) }},
+	{"email", func(pc types.ParticipantContact) string { return contactData(pc).Email }},
+	{"phone", func(pc types.ParticipantContact) string { return contactData(pc).Phone }},
+	{"contactGender", func(pc types.ParticipantContact) string { return contactData(pc).Gender }},
+	{"gpOffice", func(pc types.ParticipantContact) string { return gpInfos(pc).Office }},
+	{"gpName", func(pc types.ParticipantContact) string { return gpInfos(pc).Name }},
+	{"gpPhone", func(pc types.ParticipantContact) string { return gpInfos(pc).Phone }},
+	{"gpStreet", func(pc types.ParticipantContact) string { return gpInfos(pc).Address.Street }},
+	{"gpNr", func(pc types.ParticipantContact) string { return gpInfos(pc).Address.Nr }},
+	{"gpPostcode", func(pc types.ParticipantContact) string { return gpInfos(pc).Address.Postcode }},
+	{"gpCity", func(pc types.ParticipantContact) string { return gpInfos(pc).Address.City }},
+	{"notes", func(pc types.ParticipantContact) string { return flattenContactNotes(pc.Notes) }},
+}
+
+// ContactExportColumnKeys lists all available columns in their default order
+func ContactExportColumnKeys() []string {
+	keys := make([]string, len(contactExportColumns))
+	for i, col := range contactExportColumns {
+		keys[i] = col.Key
+	}
+	return keys
+}
+
+// flattenContactNotes joins all notes into one cell, oldest first: "<time> <author>: <content>"
+func flattenContactNotes(notes []types.ContactNote) string {
+	parts := make([]string, 0, len(notes))
+	for i := len(notes) - 1; i >= 0; i-- {
+		n := notes[i]
+		parts = append(parts, fmt.Sprintf("%s %s: %s", formatUnixTime(n.Time), n.Author, n.Content))
+	}
+	return strings.Join(parts, "\n")
+}
+
+// ParticipantContactExportRows creates the header and one row per contact for the selected columns
+// (all if none are selected)
+func ParticipantContactExportRows(pcs []types.ParticipantContact, columnKeys []string) ([][]string, error) {
+	if len(columnKeys) == 0 {
+		columnKeys = ContactExportColumnKeys()
+	}
+
+	columns := make([]contactExportColumn, 0, len(columnKeys))
+	for _, key := range columnKeys {
+		found := false
+		for _, col := range contactExportColumns {
+			if col.Key == key {
+				columns = append(columns, col)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown column '%s'", key)
+		}
+	}
+
+	rows := make([][]string, 0, len(pcs)+1)
+	rows = append(rows, columnKeys)
+	for _, pc := range pcs {
+		row := make([]string, len(columns))
+		for i, col := range columns {
+			row[i] = col.Value(pc)
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
@@ -0,0 +1,26 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/tekenradar/researcher-backend/pkg/types"
+)
+
+// ValidateContactRetentionPolicy checks that periods are not negative and contacts are not deleted before their contact data is removed
+func ValidateContactRetentionPolicy(policy types.ContactRetentionPolicy) error {
+	if policy.RemoveContactDataAfterDays < 0 || policy.DeleteAfterDays < 0 || policy.KeptContactsMaxDays < 0 {
+		return errors.New("retention periods cannot be negative")
+	}
+	removeAfter := policy.RemoveContactDataAfterDays
+	if removeAfter == 0 {
+		removeAfter = types.DEFAULT_CONTACT_DATA_RETENTION_DAYS
+	}
+	if policy.DeleteAfterDays > 0 && policy.DeleteAfterDays < removeAfter {
+		return fmt.Errorf("deleteAfterDays must be at least removeContactDataAfterDays (%d)", removeAfter)
+	}
+	if policy.KeptContactsMaxDays > 0 && policy.KeptContactsMaxDays < removeAfter {
+		return fmt.Errorf("keptContactsMaxDays must be at least removeContactDataAfterDays (%d)", removeAfter)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/tekenradar/researcher-backend/pkg/types"
+)
+
+func TestValidateContactRetentionPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  types.ContactRetentionPolicy
+		wantErr bool
+	}{
+		{
+			name:   "defaults",
+			policy: types.ContactRetentionPolicy{},
+		},
+		{
+			name:   "all periods set",
+			policy: types.ContactRetentionPolicy{RemoveContactDataAfterDays: 30, DeleteAfterDays: 365, KeptContactsMaxDays: 730},
+		},
+		{
+			name:   "periods equal to the contact data retention",
+			policy: types.ContactRetentionPolicy{RemoveContactDataAfterDays: 30, DeleteAfterDays: 30, KeptContactsMaxDays: 30},
+		},
+		{
+			name:    "negative contact data retention",
+			policy:  types.ContactRetentionPolicy{RemoveContactDataAfterDays: -1},
+			wantErr: true,
+		},
+		{
+			name:    "negative delete period",
+			policy:  types.ContactRetentionPolicy{DeleteAfterDays: -1},
+			wantErr: true,
+		},
+		{
+			name:    "negative max days of kept contacts",
+			policy:  types.ContactRetentionPolicy{KeptContactsMaxDays: -1},
+			wantErr: true,
+		},
+		{
+			name:    "delete before contact data removal",
+			policy:  types.ContactRetentionPolicy{RemoveContactDataAfterDays: 30, DeleteAfterDays: 29},
+			wantErr: true,
+		},
+		{
+			name:    "delete before the default contact data retention",
+			policy:  types.ContactRetentionPolicy{DeleteAfterDays: types.DEFAULT_CONTACT_DATA_RETENTION_DAYS - 1},
+			wantErr: true,
+		},
+		{
+			name:    "kept contacts removed before the others",
+			policy:  types.ContactRetentionPolicy{RemoveContactDataAfterDays: 30, KeptContactsMaxDays: 10},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateContactRetentionPolicy(tt.policy); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateContactRetentionPolicy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
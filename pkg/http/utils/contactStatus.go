@@ -0,0 +1,35 @@
+package utils
+
+import (
+	"fmt"
+
+	"github.com/tekenradar/researcher-backend/pkg/types"
+)
+
+// ValidateContactStatusPipeline checks that status keys are unique and transitions only point to existing statuses
+func ValidateContactStatusPipeline(pipeline []types.ContactStatus) error {
+	keys := map[string]bool{}
+	for i, s := range pipeline {
+		if len(s.Key) < 1 {
+			return fmt.Errorf("status %d: key missing", i)
+		}
+		if keys[s.Key] {
+			return fmt.Errorf("status %d: key '%s' used twice", i, s.Key)
+		}
+		keys[s.Key] = true
+
+		switch s.Retention {
+		case types.CONTACT_STATUS_RETENTION_DEFAULT, types.CONTACT_STATUS_RETENTION_KEEP, types.CONTACT_STATUS_RETENTION_REMOVE:
+		default:
+			return fmt.Errorf("status %d (%s): unknown retention '%s'", i, s.Key, s.Retention)
+		}
+	}
+	for i, s := range pipeline {
+		for _, n := range s.Next {
+			if !keys[n] {
+				return fmt.Errorf("status %d (%s): unknown next status '%s'", i, s.Key, n)
+			}
+		}
+	}
+	return nil
+}
@@ -3,5 +3,27 @@ package utils
 const (
 	AuthCookieName      = "auth"
 	TokenMaxAge         = 86400 // in seconds
-	InitSessionTokenAge = 120   // seconds
+	RenewTokenMaxAge    = 86400 * 14
+	InitSessionTokenAge = 120 // seconds
+	LoginCodeMaxAge     = 60  // seconds
+
+	SAMLRequestIDCookieName = "saml-request-id"
+
+	IdempotencyKeyHeader          = "Idempotency-Key"
+	ProcessedStudyEventMaxAge     = 86400 * 7 // how long replays of a study event are recognised
+	StudyEventProcessingStaleTime = 600       // seconds until an unfinished event may be processed again
+
+	// trailers of streamed dataset downloads, the status is "complete" or "failed"
+	DatasetExportStatusTrailer = "X-Export-Status"
+	DatasetExportErrorTrailer  = "X-Export-Error"
+
+	MaxNoteAttachmentSize     = 5 << 20 // bytes
+	MaxNoteAttachmentsPerNote = 10
 )
+
+// AllowedNoteAttachmentTypes are detected from the file content
+var AllowedNoteAttachmentTypes = []string{
+	"application/pdf",
+	"image/png",
+	"image/jpeg",
+}
@@ -0,0 +1,16 @@
+package utils
+
+import (
+	"fmt"
+
+	"github.com/tekenradar/researcher-backend/pkg/types"
+)
+
+func ValidateDatasetInfos(datasets []types.DatasetInfo) error {
+	for _, dataset := range datasets {
+		if len(dataset.DefaultFormat) > 0 && !types.IsValidDatasetFormat(dataset.DefaultFormat) {
+			return fmt.Errorf("dataset %s: unknown default format '%s'", dataset.ID, dataset.DefaultFormat)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/influenzanet/study-service/pkg/studyengine"
+	"github.com/tekenradar/researcher-backend/pkg/types"
+)
+
+const maxInclusionRuleDepth = 10
+
+// ValidateInclusionRule checks that the rule tree only uses known rule types with all required fields
+func ValidateInclusionRule(rule *types.InclusionRule) error {
+	if rule == nil {
+		return nil
+	}
+	return validateInclusionRule(*rule, "rule", 0)
+}
+
+func validateInclusionRule(rule types.InclusionRule, path string, depth int) error {
+	if depth > maxInclusionRuleDepth {
+		return fmt.Errorf("%s: rules nested deeper than %d levels", path, maxInclusionRuleDepth)
+	}
+
+	switch rule.Type {
+	case types.INCLUSION_RULE_AND, types.INCLUSION_RULE_OR:
+		if len(rule.Rules) < 1 {
+			return fmt.Errorf("%s (%s): rules missing", path, rule.Type)
+		}
+	case types.INCLUSION_RULE_NOT:
+		if len(rule.Rules) != 1 {
+			return fmt.Errorf("%s (%s): exactly one rule expected", path, rule.Type)
+		}
+	case types.INCLUSION_RULE_FLAG_EQUALS, types.INCLUSION_RULE_FLAG_NOT_EQUALS, types.INCLUSION_RULE_FLAG_EXISTS:
+		if len(rule.FlagKey) < 1 {
+			return fmt.Errorf("%s (%s): flagKey missing", path, rule.Type)
+		}
+	case types.INCLUSION_RULE_FLAG_IN_RANGE:
+		if len(rule.FlagKey) < 1 {
+			return fmt.Errorf("%s (%s): flagKey missing", path, rule.Type)
+		}
+		if rule.Min == nil && rule.Max == nil {
+			return fmt.Errorf("%s (%s): min or max needed", path, rule.Type)
+		}
+		if rule.Min != nil && rule.Max != nil && *rule.Min > *rule.Max {
+			return fmt.Errorf("%s (%s): min is larger than max", path, rule.Type)
+		}
+	case types.INCLUSION_RULE_RESPONSE_EQUALS:
+		if len(rule.ItemKey) < 1 || len(rule.SlotKey) < 1 {
+			return fmt.Errorf("%s (%s): itemKey and slotKey needed", path, rule.Type)
+		}
+	default:
+		return fmt.Errorf("%s: unknown rule type '%s'", path, rule.Type)
+	}
+
+	if len(rule.Rules) > 0 && rule.Type != types.INCLUSION_RULE_AND && rule.Type != types.INCLUSION_RULE_OR && rule.Type != types.INCLUSION_RULE_NOT {
+		return fmt.Errorf("%s (%s): rules are only used for and, or and not", path, rule.Type)
+	}
+	for i, r := range rule.Rules {
+		if err := validateInclusionRule(r, fmt.Sprintf("%s.rules[%d]", path, i), depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EvaluateInclusionRule checks if the event matches the rule, the rule is expected to be validated
+func EvaluateInclusionRule(rule types.InclusionRule, event studyengine.ExternalEventPayload) bool {
+	switch rule.Type {
+	case types.INCLUSION_RULE_AND:
+		for _, r := range rule.Rules {
+			if !EvaluateInclusionRule(r, event) {
+				return false
+			}
+		}
+		return len(rule.Rules) > 0
+	case types.INCLUSION_RULE_OR:
+		for _, r := range rule.Rules {
+			if EvaluateInclusionRule(r, event) {
+				return true
+			}
+		}
+		return false
+	case types.INCLUSION_RULE_NOT:
+		if len(rule.Rules) != 1 {
+			return false
+		}
+		return !EvaluateInclusionRule(rule.Rules[0], event)
+	case types.INCLUSION_RULE_FLAG_EQUALS:
+		v, ok := event.ParticipantState.Flags[rule.FlagKey]
+		return ok && v == rule.Value
+	case types.INCLUSION_RULE_FLAG_NOT_EQUALS:
+		v, ok := event.ParticipantState.Flags[rule.FlagKey]
+		return !ok || v != rule.Value
+	case types.INCLUSION_RULE_FLAG_EXISTS:
+		_, ok := event.ParticipantState.Flags[rule.FlagKey]
+		return ok
+	case types.INCLUSION_RULE_FLAG_IN_RANGE:
+		v, ok := event.ParticipantState.Flags[rule.FlagKey]
+		if !ok {
+			return false
+		}
+		n, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return false
+		}
+		if rule.Min != nil && n < *rule.Min {
+			return false
+		}
+		if rule.Max != nil && n > *rule.Max {
+			return false
+		}
+		return true
+	case types.INCLUSION_RULE_RESPONSE_EQUALS:
+		surveyItem, err := FindSurveyItemResponse(event.Response.Responses, rule.ItemKey)
+		if err != nil {
+			return false
+		}
+		slot, err := FindResponseSlot(surveyItem.Response, rule.SlotKey)
+		if err != nil {
+			return false
+		}
+		if slot.Value == rule.Value {
+			return true
+		}
+		for _, item := range slot.Items {
+			if item != nil && item.Key == rule.Value {
+				return true
+			}
+		}
+		return false
+	}
+	return false
+}
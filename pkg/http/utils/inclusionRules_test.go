@@ -0,0 +1,135 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/influenzanet/study-service/pkg/studyengine"
+	studyTypes "github.com/influenzanet/study-service/pkg/types"
+	"github.com/tekenradar/researcher-backend/pkg/types"
+)
+
+func floatPtr(v float64) *float64 {
+	return &v
+}
+
+func flagEquals(key string, value string) types.InclusionRule {
+	return types.InclusionRule{Type: types.INCLUSION_RULE_FLAG_EQUALS, FlagKey: key, Value: value}
+}
+
+func TestValidateInclusionRule(t *testing.T) {
+	deep := flagEquals("a", "1")
+	for i := 0; i <= maxInclusionRuleDepth; i++ {
+		deep = types.InclusionRule{Type: types.INCLUSION_RULE_NOT, Rules: []types.InclusionRule{deep}}
+	}
+
+	tests := []struct {
+		name    string
+		rule    *types.InclusionRule
+		wantErr bool
+	}{
+		{name: "no rule", rule: nil},
+		{
+			name: "nested rules",
+			rule: &types.InclusionRule{Type: types.INCLUSION_RULE_AND, Rules: []types.InclusionRule{
+				flagEquals("country", "nl"),
+				{Type: types.INCLUSION_RULE_OR, Rules: []types.InclusionRule{
+					{Type: types.INCLUSION_RULE_FLAG_IN_RANGE, FlagKey: "age", Min: floatPtr(18)},
+					{Type: types.INCLUSION_RULE_RESPONSE_EQUALS, ItemKey: "Q1", SlotKey: "rg.scg", Value: "1"},
+				}},
+				{Type: types.INCLUSION_RULE_NOT, Rules: []types.InclusionRule{{Type: types.INCLUSION_RULE_FLAG_EXISTS, FlagKey: "withdrawn"}}},
+			}},
+		},
+		{name: "unknown type", rule: &types.InclusionRule{Type: "flagContains", FlagKey: "a"}, wantErr: true},
+		{name: "and without rules", rule: &types.InclusionRule{Type: types.INCLUSION_RULE_AND}, wantErr: true},
+		{name: "not with two rules", rule: &types.InclusionRule{Type: types.INCLUSION_RULE_NOT, Rules: []types.InclusionRule{flagEquals("a", "1"), flagEquals("b", "1")}}, wantErr: true},
+		{name: "flag key missing", rule: &types.InclusionRule{Type: types.INCLUSION_RULE_FLAG_EQUALS, Value: "1"}, wantErr: true},
+		{name: "range without bounds", rule: &types.InclusionRule{Type: types.INCLUSION_RULE_FLAG_IN_RANGE, FlagKey: "age"}, wantErr: true},
+		{name: "range min larger than max", rule: &types.InclusionRule{Type: types.INCLUSION_RULE_FLAG_IN_RANGE, FlagKey: "age", Min: floatPtr(65), Max: floatPtr(18)}, wantErr: true},
+		{name: "response without slot key", rule: &types.InclusionRule{Type: types.INCLUSION_RULE_RESPONSE_EQUALS, ItemKey: "Q1", Value: "1"}, wantErr: true},
+		{name: "rules below a flag rule", rule: &types.InclusionRule{Type: types.INCLUSION_RULE_FLAG_EXISTS, FlagKey: "a", Rules: []types.InclusionRule{flagEquals("b", "1")}}, wantErr: true},
+		{name: "invalid nested rule", rule: &types.InclusionRule{Type: types.INCLUSION_RULE_OR, Rules: []types.InclusionRule{flagEquals("a", "1"), {Type: types.INCLUSION_RULE_FLAG_EXISTS}}}, wantErr: true},
+		{name: "nested too deep", rule: &deep, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateInclusionRule(tt.rule); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateInclusionRule() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEvaluateInclusionRule(t *testing.T) {
+	event := studyengine.ExternalEventPayload{
+		ParticipantState: studyTypes.ParticipantState{Flags: map[string]string{
+			"country": "nl",
+			"age":     "42",
+			"name":    "abc",
+		}},
+		Response: studyTypes.SurveyResponse{Responses: []studyTypes.SurveyItemResponse{
+			{Key: "T0.Q1", Response: &studyTypes.ResponseItem{Key: "rg", Items: []*studyTypes.ResponseItem{
+				{Key: "scg", Items: []*studyTypes.ResponseItem{{Key: "2"}}},
+				{Key: "input", Value: "yes"},
+			}}},
+		}},
+	}
+
+	tests := []struct {
+		name string
+		rule types.InclusionRule
+		want bool
+	}{
+		{name: "flag equals", rule: flagEquals("country", "nl"), want: true},
+		{name: "flag has other value", rule: flagEquals("country", "be"), want: false},
+		{name: "flag missing", rule: flagEquals("region", "nl"), want: false},
+		{name: "flag not equals", rule: types.InclusionRule{Type: types.INCLUSION_RULE_FLAG_NOT_EQUALS, FlagKey: "country", Value: "be"}, want: true},
+		{name: "flag not equals with same value", rule: types.InclusionRule{Type: types.INCLUSION_RULE_FLAG_NOT_EQUALS, FlagKey: "country", Value: "nl"}, want: false},
+		{name: "missing flag is not equal", rule: types.InclusionRule{Type: types.INCLUSION_RULE_FLAG_NOT_EQUALS, FlagKey: "region", Value: "nl"}, want: true},
+		{name: "flag exists", rule: types.InclusionRule{Type: types.INCLUSION_RULE_FLAG_EXISTS, FlagKey: "age"}, want: true},
+		{name: "flag does not exist", rule: types.InclusionRule{Type: types.INCLUSION_RULE_FLAG_EXISTS, FlagKey: "region"}, want: false},
+		{name: "flag in range", rule: types.InclusionRule{Type: types.INCLUSION_RULE_FLAG_IN_RANGE, FlagKey: "age", Min: floatPtr(18), Max: floatPtr(65)}, want: true},
+		{name: "flag at range bounds", rule: types.InclusionRule{Type: types.INCLUSION_RULE_FLAG_IN_RANGE, FlagKey: "age", Min: floatPtr(42), Max: floatPtr(42)}, want: true},
+		{name: "flag below range", rule: types.InclusionRule{Type: types.INCLUSION_RULE_FLAG_IN_RANGE, FlagKey: "age", Min: floatPtr(50)}, want: false},
+		{name: "flag above range", rule: types.InclusionRule{Type: types.INCLUSION_RULE_FLAG_IN_RANGE, FlagKey: "age", Max: floatPtr(40)}, want: false},
+		{name: "flag not a number", rule: types.InclusionRule{Type: types.INCLUSION_RULE_FLAG_IN_RANGE, FlagKey: "name", Min: floatPtr(0)}, want: false},
+		{name: "selected option", rule: types.InclusionRule{Type: types.INCLUSION_RULE_RESPONSE_EQUALS, ItemKey: "Q1", SlotKey: "rg.scg", Value: "2"}, want: true},
+		{name: "other option", rule: types.InclusionRule{Type: types.INCLUSION_RULE_RESPONSE_EQUALS, ItemKey: "Q1", SlotKey: "rg.scg", Value: "1"}, want: false},
+		{name: "slot value", rule: types.InclusionRule{Type: types.INCLUSION_RULE_RESPONSE_EQUALS, ItemKey: "Q1", SlotKey: "rg.input", Value: "yes"}, want: true},
+		{name: "missing item", rule: types.InclusionRule{Type: types.INCLUSION_RULE_RESPONSE_EQUALS, ItemKey: "Q2", SlotKey: "rg.scg", Value: "2"}, want: false},
+		{name: "missing slot", rule: types.InclusionRule{Type: types.INCLUSION_RULE_RESPONSE_EQUALS, ItemKey: "Q1", SlotKey: "rg.mcg", Value: "2"}, want: false},
+		{
+			name: "and",
+			rule: types.InclusionRule{Type: types.INCLUSION_RULE_AND, Rules: []types.InclusionRule{flagEquals("country", "nl"), flagEquals("name", "abc")}},
+			want: true,
+		},
+		{
+			name: "and with one failing rule",
+			rule: types.InclusionRule{Type: types.INCLUSION_RULE_AND, Rules: []types.InclusionRule{flagEquals("country", "nl"), flagEquals("name", "xyz")}},
+			want: false,
+		},
+		{name: "and without rules", rule: types.InclusionRule{Type: types.INCLUSION_RULE_AND}, want: false},
+		{
+			name: "or",
+			rule: types.InclusionRule{Type: types.INCLUSION_RULE_OR, Rules: []types.InclusionRule{flagEquals("country", "be"), flagEquals("name", "abc")}},
+			want: true,
+		},
+		{
+			name: "or without matching rule",
+			rule: types.InclusionRule{Type: types.INCLUSION_RULE_OR, Rules: []types.InclusionRule{flagEquals("country", "be"), flagEquals("name", "xyz")}},
+			want: false,
+		},
+		{name: "not", rule: types.InclusionRule{Type: types.INCLUSION_RULE_NOT, Rules: []types.InclusionRule{flagEquals("country", "be")}}, want: true},
+		{name: "not of matching rule", rule: types.InclusionRule{Type: types.INCLUSION_RULE_NOT, Rules: []types.InclusionRule{flagEquals("country", "nl")}}, want: false},
+		{name: "not without rule", rule: types.InclusionRule{Type: types.INCLUSION_RULE_NOT}, want: false},
+		{name: "unknown type", rule: types.InclusionRule{Type: "flagContains", FlagKey: "country", Value: "nl"}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := EvaluateInclusionRule(tt.rule, event); got != tt.want {
+				t.Errorf("EvaluateInclusionRule() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
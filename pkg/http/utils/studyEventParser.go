@@ -18,6 +18,9 @@ func FindSurveyItemResponse(response []types.SurveyItemResponse, itemKey string)
 }
 
 func FindResponseSlot(rootItem *types.ResponseItem, slotKey string) (*types.ResponseItem, error) {
+	if rootItem == nil {
+		return nil, errors.New("could not find response slot")
+	}
 	keyParts := strings.Split(slotKey, ".")
 	if len(keyParts) > 1 {
 		for _, item := range rootItem.Items {
@@ -68,12 +71,16 @@ func ExtractResponseValueAsNum(responses []types.SurveyItemResponse, itemKey str
 }
 
 func MapSingleChoiceResponse(responses []types.SurveyItemResponse, itemKey string, mapping map[string]string) (string, error) {
+	return MapSingleChoiceSlot(responses, itemKey, "rg.scg", mapping)
+}
+
+func MapSingleChoiceSlot(responses []types.SurveyItemResponse, itemKey string, slotKey string, mapping map[string]string) (string, error) {
 	surveyItem, err := FindSurveyItemResponse(responses, itemKey)
 	if err != nil {
 		return "", err
 	}
 
-	slotResponse, err := FindResponseSlot(surveyItem.Response, "rg.scg")
+	slotResponse, err := FindResponseSlot(surveyItem.Response, slotKey)
 	if err != nil {
 		return "", err
 	}
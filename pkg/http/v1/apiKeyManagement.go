@@ -0,0 +1,171 @@
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/coneno/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/tekenradar/researcher-backend/pkg/http/utils"
+	"github.com/tekenradar/researcher-backend/pkg/jwt"
+	"github.com/tekenradar/researcher-backend/pkg/types"
+)
+
+const (
+	apiKeyPrefix                = "trk_"
+	defaultAPIKeyRotationPeriod = 86400 // seconds the old key stays valid after rotation
+)
+
+func (h *HttpEndpoints) SM_getAPIKeys(c *gin.Context) {
+	token := c.MustGet("validatedToken").(*jwt.UserClaims)
+
+	apiKeys, err := h.researcherDB.FindAllAPIKeys()
+	if err != nil {
+		logger.Error.Printf("error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	logger.Info.Printf("API keys fetched by '%s'", token.ID)
+	c.JSON(http.StatusOK, gin.H{"apiKeys": apiKeys})
+}
+
+type CreateAPIKeyRequest struct {
+	Name      string   `json:"name" binding:"required"`
+	Scopes    []string `json:"scopes" binding:"required"`
+	ExpiresAt int64    `json:"expiresAt"`
+}
+
+func (h *HttpEndpoints) SM_createAPIKey(c *gin.Context) {
+	token := c.MustGet("validatedToken").(*jwt.UserClaims)
+
+	var req CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Error.Printf("error: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	for _, scope := range req.Scopes {
+		if !types.IsValidAPIKeyScope(scope) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unknown scope: %s", scope)})
+			return
+		}
+	}
+	if req.ExpiresAt > 0 && req.ExpiresAt < time.Now().Unix() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expiresAt is in the past"})
+		return
+	}
+
+	key, apiKey, err := h.createAPIKey(req.Name, req.Scopes, req.ExpiresAt, token.ID)
+	h.writeAuditLog(c, types.AUDIT_ACTION_CREATE_API_KEY, "", apiKey.ID.Hex(), map[string]string{
+		"name":      req.Name,
+		"scopes":    strings.Join(req.Scopes, ","),
+		"expiresAt": strconv.FormatInt(req.ExpiresAt, 10),
+	}, err)
+	if err != nil {
+		logger.Error.Printf("error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	logger.Info.Printf("API key '%s' created by '%s'", apiKey.Name, token.ID)
+	c.JSON(http.StatusOK, gin.H{"key": key, "apiKey": apiKey})
+}
+
+// createAPIKey stores a new key and returns the plain key, which is not retrievable afterwards
+func (h *HttpEndpoints) createAPIKey(name string, scopes []string, expiresAt int64, createdBy string) (string, types.APIKey, error) {
+	key, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return "", types.APIKey{}, err
+	}
+	key = apiKeyPrefix + key
+
+	apiKey, err := h.researcherDB.AddAPIKey(types.APIKey{
+		Name:      name,
+		KeyHash:   utils.HashToken(key),
+		Scopes:    scopes,
+		CreatedAt: time.Now().Unix(),
+		CreatedBy: createdBy,
+		ExpiresAt: expiresAt,
+	})
+	return key, apiKey, err
+}
+
+type RotateAPIKeyRequest struct {
+	GracePeriod int64 `json:"gracePeriod"` // seconds the old key stays valid
+}
+
+// SM_rotateAPIKey creates a new key with the same name and scopes, the old key expires after the grace period
+func (h *HttpEndpoints) SM_rotateAPIKey(c *gin.Context) {
+	token := c.MustGet("validatedToken").(*jwt.UserClaims)
+	keyID := c.Param("keyID")
+
+	req := RotateAPIKeyRequest{GracePeriod: defaultAPIKeyRotationPeriod}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			logger.Error.Printf("error: %v", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	oldKey, err := h.researcherDB.FindAPIKeyByID(keyID)
+	if err != nil {
+		logger.Error.Printf("error: %v", err)
+		c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+		return
+	}
+	now := time.Now().Unix()
+	if !oldKey.IsActive(now) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "API key is not active anymore"})
+		return
+	}
+
+	key, apiKey, err := h.createAPIKey(oldKey.Name, oldKey.Scopes, oldKey.ExpiresAt, token.ID)
+	if err == nil {
+		oldKeyExpiresAt := now + req.GracePeriod
+		if oldKey.ExpiresAt == 0 || oldKeyExpiresAt < oldKey.ExpiresAt {
+			err = h.researcherDB.UpdateAPIKeyExpiry(keyID, oldKeyExpiresAt)
+		}
+	}
+	h.writeAuditLog(c, types.AUDIT_ACTION_ROTATE_API_KEY, "", keyID, map[string]string{
+		"newKeyID":    apiKey.ID.Hex(),
+		"gracePeriod": strconv.FormatInt(req.GracePeriod, 10),
+	}, err)
+	if err != nil {
+		logger.Error.Printf("error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	logger.Info.Printf("API key '%s' rotated by '%s'", apiKey.Name, token.ID)
+	c.JSON(http.StatusOK, gin.H{"key": key, "apiKey": apiKey})
+}
+
+func (h *HttpEndpoints) SM_revokeAPIKey(c *gin.Context) {
+	token := c.MustGet("validatedToken").(*jwt.UserClaims)
+	keyID := c.Param("keyID")
+
+	count, err := h.researcherDB.RevokeAPIKey(keyID)
+	if err == nil && count < 1 {
+		h.writeAuditLog(c, types.AUDIT_ACTION_REVOKE_API_KEY, "", keyID, nil, errors.New("no active API key found"))
+	} else {
+		h.writeAuditLog(c, types.AUDIT_ACTION_REVOKE_API_KEY, "", keyID, nil, err)
+	}
+	if err != nil {
+		logger.Error.Printf("error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if count < 1 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no active API key found"})
+		return
+	}
+
+	logger.Info.Printf("API key %s revoked by '%s'", keyID, token.ID)
+	c.JSON(http.StatusOK, gin.H{"message": "API key revoked"})
+}
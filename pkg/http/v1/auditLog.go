@@ -0,0 +1,183 @@
+package v1
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/coneno/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/tekenradar/researcher-backend/pkg/db"
+	"github.com/tekenradar/researcher-backend/pkg/jwt"
+	"github.com/tekenradar/researcher-backend/pkg/types"
+)
+
+const (
+	defaultAuditLogLimit = 50
+	maxAuditLogLimit     = 500
+)
+
+// writeAuditLog records the action of the current user, the outcome is a failure if err is not nil
+func (h *HttpEndpoints) writeAuditLog(c *gin.Context, action string, studyKey string, targetID string, params map[string]string, err error) {
+	actor := ""
+	if token, ok := c.Get("validatedToken"); ok {
+		actor = token.(*jwt.UserClaims).ID
+	}
+
+	entry := types.AuditLogEntry{
+		Time:       time.Now().Unix(),
+		Actor:      actor,
+		Action:     action,
+		StudyKey:   studyKey,
+		TargetID:   targetID,
+		Parameters: params,
+		Outcome:    types.AUDIT_OUTCOME_SUCCESS,
+		ClientIP:   c.ClientIP(),
+	}
+	if err != nil {
+		entry.Outcome = types.AUDIT_OUTCOME_FAILURE
+		entry.Error = err.Error()
+	}
+
+	if _, err := h.researcherDB.AddAuditLogEntry(entry); err != nil {
+		logger.Error.Printf("failed to write audit log entry %v: %v", entry, err)
+	}
+}
+
+// SM_getAuditLog returns one page of the matching entries as JSON, or all of them as CSV with format=csv
+func (h *HttpEndpoints) SM_getAuditLog(c *gin.Context) {
+	token := c.MustGet("validatedToken").(*jwt.UserClaims)
+
+	query := types.AuditLogQuery{
+		Actor:    c.DefaultQuery("actor", ""),
+		Action:   c.DefaultQuery("action", ""),
+		StudyKey: c.DefaultQuery("studyKey", ""),
+		TargetID: c.DefaultQuery("targetID", ""),
+		Limit:    defaultAuditLogLimit,
+		Cursor:   c.DefaultQuery("cursor", ""),
+	}
+	if n, err := strconv.ParseInt(c.DefaultQuery("from", ""), 10, 64); err == nil {
+		query.From = n
+	}
+	if n, err := strconv.ParseInt(c.DefaultQuery("until", ""), 10, 64); err == nil {
+		query.Until = n
+	}
+	if v := c.DefaultQuery("limit", ""); len(v) > 0 {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 1 || n > maxAuditLogLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", maxAuditLogLimit)})
+			return
+		}
+		query.Limit = n
+	}
+	format := c.DefaultQuery("format", "json")
+
+	auditParams := map[string]string{
+		"actor":    query.Actor,
+		"action":   query.Action,
+		"targetID": query.TargetID,
+		"from":     strconv.FormatInt(query.From, 10),
+		"until":    strconv.FormatInt(query.Until, 10),
+		"format":   format,
+	}
+
+	if format == "csv" {
+		query.Limit = 0
+		query.Cursor = ""
+		count, err := h.writeAuditLogCSV(c, query)
+		auditParams["count"] = strconv.Itoa(count)
+		h.writeAuditLog(c, types.AUDIT_ACTION_READ_AUDIT_LOG, query.StudyKey, "", auditParams, err)
+		if err != nil {
+			logger.Error.Printf("error: %v", err)
+			if count == 0 {
+				c.Header("Content-Disposition", "")
+				c.Header("Content-Type", "")
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			}
+			return
+		}
+		logger.Info.Printf("audit log exported by '%s'", token.ID)
+		return
+	}
+
+	entries, nextCursor, err := h.researcherDB.FindAuditLogEntries(query)
+	auditParams["limit"] = strconv.FormatInt(query.Limit, 10)
+	auditParams["cursor"] = query.Cursor
+	auditParams["count"] = strconv.Itoa(len(entries))
+	h.writeAuditLog(c, types.AUDIT_ACTION_READ_AUDIT_LOG, query.StudyKey, "", auditParams, err)
+	if err != nil {
+		logger.Error.Printf("error: %v", err)
+		if err == db.ErrInvalidCursor {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	logger.Info.Printf("audit log fetched by '%s'", token.ID)
+
+	c.JSON(http.StatusOK, gin.H{"auditLog": entries, "nextCursor": nextCursor})
+}
+
+// writeAuditLogCSV streams all entries matching the query as CSV, returns the number of entries written
+func (h *HttpEndpoints) writeAuditLogCSV(c *gin.Context, query types.AuditLogQuery) (int, error) {
+	w := csv.NewWriter(c.Writer)
+	// the response starts with the first entry, so that errors of the query can still be returned as JSON
+	writeHeader := func() error {
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=audit-log_%d.csv", time.Now().Unix()))
+		c.Header("Content-Type", "text/csv")
+		c.Status(http.StatusOK)
+		return w.Write([]string{"time", "actor", "action", "studyKey", "targetID", "parameters", "outcome", "error", "clientIP"})
+	}
+
+	count := 0
+	err := h.researcherDB.ForEachAuditLogEntry(query, func(e types.AuditLogEntry) error {
+		if count == 0 {
+			if err := writeHeader(); err != nil {
+				return err
+			}
+		}
+		row := []string{
+			time.Unix(e.Time, 0).UTC().Format(time.RFC3339),
+			e.Actor,
+			e.Action,
+			e.StudyKey,
+			e.TargetID,
+			formatAuditLogParameters(e.Parameters),
+			e.Outcome,
+			e.Error,
+			e.ClientIP,
+		}
+		for i := range row {
+			row[i] = escapeCSVFormula(row[i])
+		}
+		count++
+		return w.Write(row)
+	})
+	if err == nil && count == 0 {
+		err = writeHeader()
+	}
+	w.Flush()
+	if err == nil {
+		err = w.Error()
+	}
+	return count, err
+}
+
+func formatAuditLogParameters(params map[string]string) string {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, k+"="+params[k])
+	}
+	return strings.Join(parts, ";")
+}
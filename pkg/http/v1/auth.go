@@ -2,15 +2,16 @@ package v1
 
 import (
 	"net/http"
-	"os"
-	"strings"
+	"net/url"
 	"time"
 
 	"github.com/coneno/logger"
-	"github.com/tekenradar/researcher-backend/internal/config"
 	mw "github.com/tekenradar/researcher-backend/pkg/http/middlewares"
 	"github.com/tekenradar/researcher-backend/pkg/http/utils"
 	"github.com/tekenradar/researcher-backend/pkg/jwt"
+	"github.com/tekenradar/researcher-backend/pkg/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 
 	"github.com/gin-gonic/gin"
 )
@@ -18,9 +19,32 @@ import (
 func (h *HttpEndpoints) AddAuthAPI(rg *gin.RouterGroup) {
 	auth := rg.Group("/auth")
 
-	auth.POST("/init-token", mw.HasValidAPIKey(h.apiKeys), h.initToken)
-	auth.POST("/renew-token", mw.HasValidAPIKey(h.apiKeys), h.renewToken)
+	auth.POST("/init-token", mw.HasValidAPIKey(h.researcherDB, types.API_KEY_SCOPE_AUTH_INIT), h.initToken)
+	auth.POST("/renew-token", mw.HasValidAPIKey(h.researcherDB, types.API_KEY_SCOPE_AUTH_RENEW), h.renewToken)
 	auth.POST("/logout", h.logout)
+	auth.POST("/login-code", h.exchangeLoginCode)
+
+	if h.samlSP != nil {
+		samlGroup := auth.Group("/saml")
+		samlGroup.GET("/metadata", h.samlMetadata)
+		samlGroup.GET("/login", h.samlLogin)
+		samlGroup.POST("/acs", h.samlACS)
+	}
+
+	if h.useDummyLogin {
+		logger.Warning.Println("dummy login is enabled - do not use this in production")
+		auth.GET("/dummy-login", h.dummyLoginPage)
+		auth.POST("/dummy-login", h.dummyLogin)
+	}
+}
+
+func (h *HttpEndpoints) AddWellKnownAPI(rg *gin.RouterGroup) {
+	rg.GET("/.well-known/jwks.json", h.getJWKS)
+}
+
+func (h *HttpEndpoints) getJWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, jwt.GetJWKS())
 }
 
 type InitTokenRequest struct {
@@ -35,39 +59,228 @@ func (h *HttpEndpoints) initToken(c *gin.Context) {
 		return
 	}
 
+	// prepare token
+	token, err := jwt.GenerateNewToken(
+		req.Email,
+		utils.TokenMaxAge*time.Second,
+		h.getRolesForUser(req.Email),
+	)
+
+	if err != nil {
+		logger.Error.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err})
+		return
+	}
+
+	// prepare renew token
+	renewToken, err := h.createRenewToken(req.Email, "")
+	if err != nil {
+		logger.Error.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"accessToken": token, "renewToken": renewToken, "expiresIn": utils.TokenMaxAge})
+}
+
+// redirectLoginSuccess redirects to the web-application with a one-time login code, which is exchanged for the
+// tokens with POST /v1/auth/login-code. Tokens are not put into the url, where they would end up in the browser
+// history, access logs and referrers.
+func (h *HttpEndpoints) redirectLoginSuccess(c *gin.Context, email string, roles []string) {
+	code, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		logger.Error.Println(err)
+		h.redirectLoginFailed(c)
+		return
+	}
+
+	err = h.researcherDB.AddLoginCode(types.LoginCode{
+		UserID:    email,
+		Roles:     roles,
+		CodeHash:  utils.HashToken(code),
+		ExpiresAt: time.Now().Add(utils.LoginCodeMaxAge * time.Second),
+	})
+	if err != nil {
+		logger.Error.Println(err)
+		h.redirectLoginFailed(c)
+		return
+	}
+
+	redirectURL, err := url.Parse(h.loginSuccessRedirectURL)
+	if err != nil {
+		logger.Error.Printf("invalid login success redirect url: %v", err)
+		h.redirectLoginFailed(c)
+		return
+	}
+	q := redirectURL.Query()
+	q.Set("code", code)
+	redirectURL.RawQuery = q.Encode()
+
+	c.Header("Referrer-Policy", "no-referrer")
+	c.Redirect(http.StatusFound, redirectURL.String())
+}
+
+type LoginCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// exchangeLoginCode issues a new token pair for a login code from redirectLoginSuccess, every code can be used once
+func (h *HttpEndpoints) exchangeLoginCode(c *gin.Context) {
+	var req LoginCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Error.Printf("error: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	loginCode, err := h.researcherDB.ConsumeLoginCode(utils.HashToken(req.Code))
+	if err != nil {
+		if err != mongo.ErrNoDocuments {
+			logger.Error.Printf("error: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid login code"})
+		return
+	}
+
+	token, err := jwt.GenerateNewToken(
+		loginCode.UserID,
+		utils.TokenMaxAge*time.Second,
+		loginCode.Roles,
+	)
+	if err != nil {
+		logger.Error.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	renewToken, err := h.createRenewToken(loginCode.UserID, "")
+	if err != nil {
+		logger.Error.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"accessToken": token, "renewToken": renewToken, "expiresIn": utils.TokenMaxAge})
+}
+
+func (h *HttpEndpoints) getRolesForUser(email string) []string {
 	roles := []string{}
 	// check if user is a research admin
-	researchAdmins := os.Getenv(config.ENV_RESEARCHADMIN_EMAILS)
-	if strings.Contains(researchAdmins, req.Email) {
+	isAdmin, err := h.researcherDB.IsAdminUser(email)
+	if err != nil {
+		logger.Error.Printf("error: %v", err)
+	}
+	if isAdmin {
 		roles = []string{
 			jwt.ROLE_ADMIN,
 		}
 	}
+	return roles
+}
+
+// createRenewToken stores a new renew token for the user and returns the opaque token value.
+// If familyID is empty, a new token family is started (i.e., on login).
+func (h *HttpEndpoints) createRenewToken(userID string, familyID string) (string, error) {
+	token, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	if len(familyID) < 1 {
+		familyID = primitive.NewObjectID().Hex()
+	}
+
+	_, err = h.researcherDB.AddRenewToken(types.RenewToken{
+		UserID:    userID,
+		TokenHash: utils.HashToken(token),
+		FamilyID:  familyID,
+		CreatedAt: time.Now().Unix(),
+		ExpiresAt: time.Now().Add(utils.RenewTokenMaxAge * time.Second),
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+type RenewTokenRequest struct {
+	RenewToken string `json:"renewToken" binding:"required"`
+}
+
+func (h *HttpEndpoints) renewToken(c *gin.Context) {
+	var req RenewTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Error.Printf("error: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tokenHash := utils.HashToken(req.RenewToken)
+	rt, err := h.researcherDB.MarkRenewTokenUsed(tokenHash)
+	if err != nil {
+		if err != mongo.ErrNoDocuments {
+			logger.Error.Printf("error: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		// token is unknown, expired, or was already used/revoked
+		rt, err = h.researcherDB.FindRenewToken(tokenHash)
+		if err == nil && (rt.UsedAt > 0 || rt.RevokedAt > 0) {
+			logger.Warning.Printf("reuse of renew token detected for '%s', revoking token family %s", rt.UserID, rt.FamilyID)
+			if _, err := h.researcherDB.RevokeRenewTokenFamily(rt.FamilyID); err != nil {
+				logger.Error.Printf("error: %v", err)
+			}
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid renew token"})
+		return
+	}
 
-	// prepare token
 	token, err := jwt.GenerateNewToken(
-		req.Email,
+		rt.UserID,
 		utils.TokenMaxAge*time.Second,
-		roles,
+		h.getRolesForUser(rt.UserID),
 	)
-
 	if err != nil {
 		logger.Error.Println(err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	// prepare renew token
-	renewToken := "todo"
+	renewToken, err := h.createRenewToken(rt.UserID, rt.FamilyID)
+	if err != nil {
+		logger.Error.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 
+	logger.Info.Printf("token renewed for '%s'", rt.UserID)
 	c.JSON(http.StatusOK, gin.H{"accessToken": token, "renewToken": renewToken, "expiresIn": utils.TokenMaxAge})
 }
 
-func (h *HttpEndpoints) renewToken(c *gin.Context) {
-	c.JSON(http.StatusNotImplemented, gin.H{"error": "not implemented"})
+type LogoutRequest struct {
+	RenewToken string `json:"renewToken"`
 }
 
 func (h *HttpEndpoints) logout(c *gin.Context) {
-	// TODO: invalidate renew token
+	var req LogoutRequest
+	if err := c.ShouldBindJSON(&req); err != nil && c.Request.ContentLength > 0 {
+		logger.Error.Printf("error: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if len(req.RenewToken) > 0 {
+		rt, err := h.researcherDB.FindRenewToken(utils.HashToken(req.RenewToken))
+		if err == nil {
+			if _, err := h.researcherDB.RevokeRenewTokenFamily(rt.FamilyID); err != nil {
+				logger.Error.Printf("error: %v", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			logger.Info.Printf("renew tokens of '%s' revoked on logout", rt.UserID)
+		}
+	}
 	c.JSON(http.StatusOK, gin.H{"msg": "logout successful"})
 }
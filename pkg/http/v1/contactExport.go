@@ -0,0 +1,111 @@
+package v1
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/coneno/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/tekenradar/researcher-backend/pkg/http/utils"
+	"github.com/tekenradar/researcher-backend/pkg/jwt"
+	"github.com/tekenradar/researcher-backend/pkg/types"
+)
+
+// exportParticipantContacts returns all contacts matching the listing filters as file: ?format=csv|xlsx&columns=id,email,...
+func (h *HttpEndpoints) exportParticipantContacts(c *gin.Context) {
+	token := c.MustGet("validatedToken").(*jwt.UserClaims)
+	studyInfo := c.MustGet("studyInfo").(types.StudyInfo)
+	substudyKey := c.Param("substudyKey")
+
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "xlsx" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be 'csv' or 'xlsx'"})
+		return
+	}
+	columns := []string{}
+	if v := c.DefaultQuery("columns", ""); len(v) > 0 {
+		for _, col := range strings.Split(v, ",") {
+			columns = append(columns, strings.TrimSpace(col))
+		}
+	}
+
+	if _, err := utils.ParticipantContactExportRows(nil, columns); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	query, err := parseParticipantContactQuery(c)
+	if err != nil {
+		logger.Error.Printf("%v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	query.Limit = 0
+	query.Cursor = ""
+	if len(query.Status) > 0 {
+		query.StatusMissing = query.Status == studyInfo.GetInitialContactStatus()
+	}
+
+	auditParams := participantContactQueryAuditParams(c)
+	delete(auditParams, "limit")
+	delete(auditParams, "cursor")
+	auditParams["format"] = format
+	auditParams["columns"] = strings.Join(columns, ",")
+
+	pcs, _, err := h.researcherDB.FindParticipantContacts(substudyKey, query)
+	auditParams["count"] = strconv.Itoa(len(pcs))
+	h.writeAuditLog(c, types.AUDIT_ACTION_EXPORT_PARTICIPANT_CONTACTS, substudyKey, "", auditParams, err)
+	if err != nil {
+		logger.Error.Printf("%v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	for i := range pcs {
+		pcs[i].ExpiresAt = studyInfo.ContactDataExpiresAt(pcs[i])
+	}
+	rows, err := utils.ParticipantContactExportRows(pcs, columns)
+	if err != nil {
+		logger.Error.Printf("%v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	logger.Info.Printf("%d partcipant contacts of %s exported as %s by '%s'", len(pcs), substudyKey, format, token.ID)
+
+	filename := fmt.Sprintf("participant-contacts_%s_%d.%s", substudyKey, time.Now().Unix(), format)
+	c.Header("Content-Disposition", "attachment; filename="+filename)
+
+	if format == "xlsx" {
+		c.Header("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		c.Status(http.StatusOK)
+		if err := utils.WriteXLSX(c.Writer, "participant contacts", rows); err != nil {
+			logger.Error.Printf("error: %v", err)
+		}
+		return
+	}
+
+	c.Header("Content-Type", "text/csv")
+	c.Status(http.StatusOK)
+	w := csv.NewWriter(c.Writer)
+	for _, row := range rows {
+		for i := range row {
+			row[i] = escapeCSVFormula(row[i])
+		}
+		_ = w.Write(row)
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		logger.Error.Printf("error: %v", err)
+	}
+}
+
+// escapeCSVFormula prevents spreadsheet programs from interpreting participant input as formula
+func escapeCSVFormula(value string) string {
+	if len(value) > 0 && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
@@ -0,0 +1,26 @@
+package v1
+
+import "testing"
+
+func TestEscapeCSVFormula(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"", ""},
+		{"Jane", "Jane"},
+		{"jane@example.org", "jane@example.org"},
+		{"=HYPERLINK(\"http://evil\")", "'=HYPERLINK(\"http://evil\")"},
+		{"+31 6 12345678", "'+31 6 12345678"},
+		{"-1+1", "'-1+1"},
+		{"@SUM(A1)", "'@SUM(A1)"},
+		{"\t=1", "'\t=1"},
+		{"\r=1", "'\r=1"},
+		{"a=1", "a=1"},
+	}
+	for _, tt := range tests {
+		if got := escapeCSVFormula(tt.value); got != tt.want {
+			t.Errorf("escapeCSVFormula(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
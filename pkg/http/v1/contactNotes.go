@@ -0,0 +1,296 @@
+package v1
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/coneno/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/tekenradar/researcher-backend/pkg/http/utils"
+	"github.com/tekenradar/researcher-backend/pkg/jwt"
+	"github.com/tekenradar/researcher-backend/pkg/types"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type UpdateContactNoteRequest struct {
+	Content string `json:"content" binding:"required"`
+}
+
+// findContactNote loads the contact and the note from the path, and writes the error response if one of them does not exist
+func (h *HttpEndpoints) findContactNote(c *gin.Context) (types.ParticipantContact, types.ContactNote, bool) {
+	substudyKey := c.Param("substudyKey")
+	contactID := c.Param("contactID")
+	noteID := c.Param("noteID")
+
+	pc, err := h.researcherDB.FindParticipantContactByID(substudyKey, contactID)
+	if err != nil {
+		logger.Error.Printf("%v", err)
+		c.JSON(http.StatusNotFound, gin.H{"error": "participant contact not found"})
+		return pc, types.ContactNote{}, false
+	}
+	note, ok := pc.FindNote(noteID)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "note not found"})
+		return pc, note, false
+	}
+	return pc, note, true
+}
+
+// canModifyContactNote: only the author of a note or a study owner can change it, and writes the error response otherwise
+func canModifyContactNote(c *gin.Context, note types.ContactNote) bool {
+	token := c.MustGet("validatedToken").(*jwt.UserClaims)
+	role := c.MustGet("studyRole").(string)
+
+	if note.Author == token.ID || role == types.STUDY_ROLE_OWNER {
+		return true
+	}
+	logger.Error.Printf("user %s tried to change note %s of %s in study %s", token.ID, note.ID, note.Author, c.Param("substudyKey"))
+	c.JSON(http.StatusForbidden, gin.H{"error": "only the author of the note or a study owner can change it"})
+	return false
+}
+
+func (h *HttpEndpoints) updateParticipantContactNote(c *gin.Context) {
+	token := c.MustGet("validatedToken").(*jwt.UserClaims)
+	substudyKey := c.Param("substudyKey")
+	contactID := c.Param("contactID")
+
+	var req UpdateContactNoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Error.Printf("error: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	_, note, ok := h.findContactNote(c)
+	if !ok || !canModifyContactNote(c, note) {
+		return
+	}
+
+	err := h.researcherDB.UpdateParticipantContactNote(substudyKey, contactID, note.ID, req.Content, types.ContactNoteEdit{
+		Time:    time.Now().Unix(),
+		Author:  token.ID,
+		Content: note.Content,
+	})
+	h.writeAuditLog(c, types.AUDIT_ACTION_UPDATE_CONTACT_NOTE, substudyKey, contactID, map[string]string{"noteID": note.ID}, err)
+	if err != nil {
+		logger.Error.Printf("%v", err)
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusNotFound, gin.H{"error": "note not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err})
+		return
+	}
+
+	pc, err := h.researcherDB.FindParticipantContactByID(substudyKey, contactID)
+	if err != nil {
+		logger.Error.Printf("%v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err})
+		return
+	}
+	logger.Info.Printf("note %s of partcipant contact %s in study %s edited by '%s'", note.ID, contactID, substudyKey, token.ID)
+
+	pc.ExpiresAt = contactExpiresAt(c, pc)
+	c.JSON(http.StatusOK, gin.H{"participantContact": pc})
+}
+
+func (h *HttpEndpoints) deleteParticipantContactNote(c *gin.Context) {
+	token := c.MustGet("validatedToken").(*jwt.UserClaims)
+	substudyKey := c.Param("substudyKey")
+	contactID := c.Param("contactID")
+
+	_, note, ok := h.findContactNote(c)
+	if !ok || !canModifyContactNote(c, note) {
+		return
+	}
+
+	err := h.researcherDB.DeleteParticipantContactNote(substudyKey, contactID, note)
+	h.writeAuditLog(c, types.AUDIT_ACTION_DELETE_CONTACT_NOTE, substudyKey, contactID, map[string]string{"noteID": note.ID}, err)
+	if err != nil {
+		logger.Error.Printf("%v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err})
+		return
+	}
+
+	pc, err := h.researcherDB.FindParticipantContactByID(substudyKey, contactID)
+	if err != nil {
+		logger.Error.Printf("%v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err})
+		return
+	}
+	logger.Info.Printf("note %s of partcipant contact %s in study %s deleted by '%s'", note.ID, contactID, substudyKey, token.ID)
+
+	pc.ExpiresAt = contactExpiresAt(c, pc)
+	c.JSON(http.StatusOK, gin.H{"participantContact": pc})
+}
+
+func (h *HttpEndpoints) addNoteAttachment(c *gin.Context) {
+	token := c.MustGet("validatedToken").(*jwt.UserClaims)
+	substudyKey := c.Param("substudyKey")
+	contactID := c.Param("contactID")
+
+	_, note, ok := h.findContactNote(c)
+	if !ok || !canModifyContactNote(c, note) {
+		return
+	}
+	if len(note.Attachments) >= utils.MaxNoteAttachmentsPerNote {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("a note can have at most %d attachments", utils.MaxNoteAttachmentsPerNote)})
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, utils.MaxNoteAttachmentSize+1<<20)
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		logger.Error.Printf("error: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "missing or too large file: " + err.Error()})
+		return
+	}
+	if fileHeader.Size > utils.MaxNoteAttachmentSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("file must not be larger than %d bytes", utils.MaxNoteAttachmentSize)})
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		logger.Error.Printf("error: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer file.Close()
+	content, err := io.ReadAll(io.LimitReader(file, utils.MaxNoteAttachmentSize+1))
+	if err != nil {
+		logger.Error.Printf("error: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(content) > utils.MaxNoteAttachmentSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("file must not be larger than %d bytes", utils.MaxNoteAttachmentSize)})
+		return
+	}
+
+	contentType := noteAttachmentContentType(content)
+	if len(contentType) == 0 {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "file type not allowed", "allowedTypes": utils.AllowedNoteAttachmentTypes})
+		return
+	}
+
+	attachment, err := h.researcherDB.AddAttachmentToParticipantContactNote(substudyKey, contactID, note.ID, types.ContactNoteAttachment{
+		FileName:    filepath.Base(fileHeader.Filename),
+		ContentType: contentType,
+		Size:        int64(len(content)),
+		UploadedAt:  time.Now().Unix(),
+		UploadedBy:  token.ID,
+	}, content)
+	h.writeAuditLog(c, types.AUDIT_ACTION_ADD_NOTE_ATTACHMENT, substudyKey, contactID, map[string]string{
+		"noteID":       note.ID,
+		"attachmentID": attachment.ID,
+		"contentType":  contentType,
+		"size":         strconv.Itoa(len(content)),
+	}, err)
+	if err != nil {
+		logger.Error.Printf("%v", err)
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusNotFound, gin.H{"error": "note not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err})
+		return
+	}
+
+	pc, err := h.researcherDB.FindParticipantContactByID(substudyKey, contactID)
+	if err != nil {
+		logger.Error.Printf("%v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err})
+		return
+	}
+	logger.Info.Printf("attachment added to note %s of partcipant contact %s in study %s by '%s'", note.ID, contactID, substudyKey, token.ID)
+
+	pc.ExpiresAt = contactExpiresAt(c, pc)
+	c.JSON(http.StatusOK, gin.H{"participantContact": pc})
+}
+
+// noteAttachmentContentType returns the detected type if allowed, or an empty string
+func noteAttachmentContentType(content []byte) string {
+	detected := http.DetectContentType(content)
+	for _, t := range utils.AllowedNoteAttachmentTypes {
+		if detected == t {
+			return t
+		}
+	}
+	return ""
+}
+
+func (h *HttpEndpoints) downloadNoteAttachment(c *gin.Context) {
+	token := c.MustGet("validatedToken").(*jwt.UserClaims)
+	substudyKey := c.Param("substudyKey")
+	contactID := c.Param("contactID")
+	attachmentID := c.Param("attachmentID")
+
+	_, note, ok := h.findContactNote(c)
+	if !ok {
+		return
+	}
+	attachment, ok := note.FindAttachment(attachmentID)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "attachment not found"})
+		return
+	}
+
+	content, err := h.researcherDB.FindNoteAttachmentContent(substudyKey, attachment.ID)
+	h.writeAuditLog(c, types.AUDIT_ACTION_READ_NOTE_ATTACHMENT, substudyKey, contactID, map[string]string{
+		"noteID":       note.ID,
+		"attachmentID": attachment.ID,
+	}, err)
+	if err != nil {
+		logger.Error.Printf("%v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err})
+		return
+	}
+	logger.Info.Printf("attachment %s of partcipant contact %s in study %s downloaded by '%s'", attachment.ID, contactID, substudyKey, token.ID)
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", attachment.FileName))
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Data(http.StatusOK, attachment.ContentType, content)
+}
+
+func (h *HttpEndpoints) deleteNoteAttachment(c *gin.Context) {
+	token := c.MustGet("validatedToken").(*jwt.UserClaims)
+	substudyKey := c.Param("substudyKey")
+	contactID := c.Param("contactID")
+	attachmentID := c.Param("attachmentID")
+
+	_, note, ok := h.findContactNote(c)
+	if !ok || !canModifyContactNote(c, note) {
+		return
+	}
+	attachment, ok := note.FindAttachment(attachmentID)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "attachment not found"})
+		return
+	}
+
+	err := h.researcherDB.RemoveAttachmentFromParticipantContactNote(substudyKey, contactID, note.ID, attachment.ID)
+	h.writeAuditLog(c, types.AUDIT_ACTION_DELETE_NOTE_ATTACHMENT, substudyKey, contactID, map[string]string{
+		"noteID":       note.ID,
+		"attachmentID": attachment.ID,
+	}, err)
+	if err != nil {
+		logger.Error.Printf("%v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err})
+		return
+	}
+
+	pc, err := h.researcherDB.FindParticipantContactByID(substudyKey, contactID)
+	if err != nil {
+		logger.Error.Printf("%v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err})
+		return
+	}
+	logger.Info.Printf("attachment %s of partcipant contact %s in study %s deleted by '%s'", attachment.ID, contactID, substudyKey, token.ID)
+
+	pc.ExpiresAt = contactExpiresAt(c, pc)
+	c.JSON(http.StatusOK, gin.H{"participantContact": pc})
+}
@@ -0,0 +1,215 @@
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/coneno/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/tekenradar/researcher-backend/pkg/datasets"
+	"github.com/tekenradar/researcher-backend/pkg/http/utils"
+	"github.com/tekenradar/researcher-backend/pkg/jwt"
+	"github.com/tekenradar/researcher-backend/pkg/types"
+	"google.golang.org/grpc/status"
+
+	studyAPI "github.com/influenzanet/study-service/pkg/api"
+)
+
+// parseDatasetExportQuery reads ?format=&from=&until=&withPositions=&withInitTimes=&withDisplayTimes=&withResponseTimes=&sep=&shortKeys=
+func parseDatasetExportQuery(c *gin.Context) types.DatasetExportQuery {
+	query := types.DatasetExportQuery{
+		Format:            c.DefaultQuery("format", ""),
+		WithPositions:     c.DefaultQuery("withPositions", "false") == "true",
+		WithInitTimes:     c.DefaultQuery("withInitTimes", "false") == "true",
+		WithDisplayTimes:  c.DefaultQuery("withDisplayTimes", "false") == "true",
+		WithResponseTimes: c.DefaultQuery("withResponseTimes", "false") == "true",
+		Separator:         c.DefaultQuery("sep", "-"),
+		ShortKeys:         c.DefaultQuery("shortKeys", "true") == "true",
+	}
+	if n, err := strconv.ParseInt(c.DefaultQuery("from", ""), 10, 64); err == nil {
+		query.From = n
+	}
+	if n, err := strconv.ParseInt(c.DefaultQuery("until", ""), 10, 64); err == nil {
+		query.Until = n
+	}
+	return query
+}
+
+// parseDatasetRequest finds the dataset of the path and checks that the query stays within the dataset's time
+// range. Writes the error response (and failed attempts to the audit log) and returns false otherwise.
+func (h *HttpEndpoints) parseDatasetRequest(c *gin.Context, auditAction string) (types.DatasetInfo, types.DatasetExportQuery, map[string]string, bool) {
+	token := c.MustGet("validatedToken").(*jwt.UserClaims)
+	studyInfo := c.MustGet("studyInfo").(types.StudyInfo)
+	substudyKey := c.Param("substudyKey")
+	datasetKey := c.Param("datasetKey")
+
+	query := parseDatasetExportQuery(c)
+	dataset, ok := studyInfo.FindDataset(datasetKey)
+	if !ok {
+		msg := fmt.Sprintf("no dataset info found in study %s for dataset id %s", substudyKey, datasetKey)
+		logger.Error.Println(msg)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return dataset, query, nil, false
+	}
+	if len(query.Format) == 0 {
+		query.Format = dataset.DefaultFormat
+	}
+	if len(query.Format) == 0 {
+		query.Format = types.DATASET_FORMAT_WIDE_CSV
+	}
+	if !types.IsValidDatasetFormat(query.Format) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown format: " + query.Format})
+		return dataset, query, nil, false
+	}
+
+	auditParams := map[string]string{
+		"surveyKey": dataset.SurveyKey,
+		"format":    query.Format,
+		"from":      strconv.FormatInt(query.From, 10),
+		"until":     strconv.FormatInt(query.Until, 10),
+	}
+	if err := datasets.CheckAllowedRange(dataset, query); err != nil {
+		logger.Debug.Printf("trying to access data outside of the dataset: %v", err)
+		logger.Error.Printf("user %s tried to access dataset %s", token.ID, datasetKey)
+		h.writeAuditLog(c, auditAction, substudyKey, datasetKey, auditParams, err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "no permission to access this dataset"})
+		return dataset, query, auditParams, false
+	}
+	return dataset, query, auditParams, true
+}
+
+// streamDatasetChunks writes the chunks to the response as soon as they are received (chunked transfer encoding),
+// starting with the already received first chunk and its error. Headers must be set before. As the status code is
+// already sent, the outcome is reported in the trailers. Returns the error which ended the stream early.
+func streamDatasetChunks(c *gin.Context, first *studyAPI.Chunk, firstErr error, stream datasets.ChunkStream) error {
+	c.Header("Trailer", utils.DatasetExportStatusTrailer+", "+utils.DatasetExportErrorTrailer)
+	c.Status(http.StatusOK)
+
+	err := firstErr
+	chunk := first
+	for err == nil {
+		if _, err = c.Writer.Write(chunk.Chunk); err != nil {
+			break
+		}
+		c.Writer.Flush()
+		chunk, err = stream.Recv()
+	}
+
+	if err == io.EOF {
+		c.Writer.Header().Set(utils.DatasetExportStatusTrailer, "complete")
+		return nil
+	}
+	if ctxErr := c.Request.Context().Err(); ctxErr != nil {
+		// client is gone, the gRPC call is cancelled with the request context
+		return errors.New("client disconnected: " + ctxErr.Error())
+	}
+	c.Writer.Header().Set(utils.DatasetExportStatusTrailer, "failed")
+	c.Writer.Header().Set(utils.DatasetExportErrorTrailer, strings.Join(strings.Fields(status.Convert(err).Message()), " "))
+	return err
+}
+
+func (h *HttpEndpoints) createDatasetExportJob(c *gin.Context) {
+	token := c.MustGet("validatedToken").(*jwt.UserClaims)
+	substudyKey := c.Param("substudyKey")
+	datasetKey := c.Param("datasetKey")
+
+	_, query, auditParams, ok := h.parseDatasetRequest(c, types.AUDIT_ACTION_CREATE_DATASET_EXPORT_JOB)
+	if !ok {
+		return
+	}
+
+	job, err := h.researcherDB.AddDatasetExportJob(types.DatasetExportJob{
+		StudyKey:    substudyKey,
+		DatasetKey:  datasetKey,
+		Query:       query,
+		RequestedBy: token.ID,
+		Status:      types.DATASET_EXPORT_JOB_STATUS_PENDING,
+		CreatedAt:   time.Now().Unix(),
+	})
+	auditParams["jobID"] = job.ID.Hex()
+	h.writeAuditLog(c, types.AUDIT_ACTION_CREATE_DATASET_EXPORT_JOB, substudyKey, datasetKey, auditParams, err)
+	if err != nil {
+		logger.Error.Printf("%v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err})
+		return
+	}
+
+	logger.Info.Printf("export job %s for dataset %s in study %s created by '%s'", job.ID.Hex(), datasetKey, substudyKey, token.ID)
+	c.JSON(http.StatusAccepted, gin.H{"job": job})
+}
+
+func (h *HttpEndpoints) getDatasetExportJobs(c *gin.Context) {
+	substudyKey := c.Param("substudyKey")
+	datasetKey := c.Param("datasetKey")
+
+	jobs, err := h.researcherDB.FindDatasetExportJobs(substudyKey, datasetKey)
+	if err != nil {
+		logger.Error.Printf("%v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"jobs": jobs})
+}
+
+// findDatasetExportJob loads the job of the path, and writes the error response if it does not exist
+func (h *HttpEndpoints) findDatasetExportJob(c *gin.Context) (types.DatasetExportJob, bool) {
+	job, err := h.researcherDB.FindDatasetExportJob(c.Param("substudyKey"), c.Param("jobID"))
+	if err != nil || job.DatasetKey != c.Param("datasetKey") {
+		logger.Error.Printf("dataset export job %s not found: %v", c.Param("jobID"), err)
+		c.JSON(http.StatusNotFound, gin.H{"error": "export job not found"})
+		return job, false
+	}
+	return job, true
+}
+
+func (h *HttpEndpoints) getDatasetExportJob(c *gin.Context) {
+	job, ok := h.findDatasetExportJob(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"job": job})
+}
+
+func (h *HttpEndpoints) downloadDatasetExportJobResult(c *gin.Context) {
+	token := c.MustGet("validatedToken").(*jwt.UserClaims)
+	substudyKey := c.Param("substudyKey")
+	datasetKey := c.Param("datasetKey")
+
+	job, ok := h.findDatasetExportJob(c)
+	if !ok {
+		return
+	}
+	if job.Status != types.DATASET_EXPORT_JOB_STATUS_DONE {
+		c.JSON(http.StatusConflict, gin.H{"error": "export job is " + job.Status})
+		return
+	}
+	if job.ExpiresAt < time.Now().Unix() {
+		c.JSON(http.StatusGone, gin.H{"error": "export result expired"})
+		return
+	}
+
+	auditParams := map[string]string{
+		"jobID":  job.ID.Hex(),
+		"format": job.Query.Format,
+		"from":   strconv.FormatInt(job.Query.From, 10),
+		"until":  strconv.FormatInt(job.Query.Until, 10),
+	}
+	content, err := h.datasetStorage.Open(job.ID.Hex())
+	h.writeAuditLog(c, types.AUDIT_ACTION_DOWNLOAD_DATASET, substudyKey, datasetKey, auditParams, err)
+	if err != nil {
+		logger.Error.Printf("%v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err})
+		return
+	}
+	defer content.Close()
+
+	logger.Info.Printf("result of export job %s for dataset %s in study %s downloaded by '%s'", job.ID.Hex(), datasetKey, substudyKey, token.ID)
+	c.DataFromReader(http.StatusOK, job.FileSize, job.ContentType, content, map[string]string{
+		"Content-Disposition": `attachment; filename=` + job.FileName,
+	})
+}
@@ -0,0 +1,107 @@
+package v1
+
+import (
+	"html/template"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/coneno/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/tekenradar/researcher-backend/pkg/http/utils"
+	"github.com/tekenradar/researcher-backend/pkg/jwt"
+)
+
+// Dummy login is only meant for local development, config.InitConfig refuses to start with it outside of debug mode.
+
+var availableDummyLoginRoles = []string{
+	jwt.ROLE_ADMIN,
+}
+
+var dummyLoginPage = template.Must(template.New("dummyLogin").Parse(`<!DOCTYPE html>
+<html>
+<head>
+	<meta charset="utf-8">
+	<title>Tekenradar researcher backend - dummy login</title>
+</head>
+<body>
+	<h1>Dummy login</h1>
+	<p>For local development only. No password is checked.</p>
+	<form method="POST">
+		<p>
+			<label for="email">Email</label>
+			<input type="email" id="email" name="email" required>
+		</p>
+		{{range .}}
+		<p>
+			<input type="checkbox" id="role-{{.}}" name="roles" value="{{.}}">
+			<label for="role-{{.}}">{{.}}</label>
+		</p>
+		{{end}}
+		<button type="submit">Login</button>
+	</form>
+</body>
+</html>
+`))
+
+type DummyLoginRequest struct {
+	Email string   `json:"email" form:"email" binding:"required"`
+	Roles []string `json:"roles" form:"roles"`
+}
+
+func (h *HttpEndpoints) dummyLoginPage(c *gin.Context) {
+	c.Status(http.StatusOK)
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	if err := dummyLoginPage.Execute(c.Writer, availableDummyLoginRoles); err != nil {
+		logger.Error.Printf("error: %v", err)
+	}
+}
+
+// dummyLogin redirects to the web-application for form posts (login page), and returns the tokens as JSON otherwise
+func (h *HttpEndpoints) dummyLogin(c *gin.Context) {
+	var req DummyLoginRequest
+	if err := c.ShouldBind(&req); err != nil {
+		logger.Error.Printf("error: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	email := strings.TrimSpace(req.Email)
+	for _, r := range req.Roles {
+		if !contains(availableDummyLoginRoles, r) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unknown role: " + r})
+			return
+		}
+	}
+	roles := req.Roles
+	if roles == nil {
+		roles = []string{}
+	}
+
+	logger.Warning.Printf("DUMMY LOGIN used for '%s' with roles %v", email, roles)
+
+	if c.ContentType() != gin.MIMEJSON {
+		h.redirectLoginSuccess(c, email, roles)
+		return
+	}
+
+	token, err := jwt.GenerateNewToken(
+		email,
+		utils.TokenMaxAge*time.Second,
+		roles,
+	)
+	if err != nil {
+		logger.Error.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	renewToken, err := h.createRenewToken(email, "")
+	if err != nil {
+		logger.Error.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"accessToken": token, "renewToken": renewToken, "expiresIn": utils.TokenMaxAge})
+}
@@ -1,6 +1,9 @@
 package v1
 
 import (
+	"github.com/coneno/logger"
+	"github.com/crewjam/saml"
+	"github.com/tekenradar/researcher-backend/pkg/datasets"
 	"github.com/tekenradar/researcher-backend/pkg/db"
 	"github.com/tekenradar/researcher-backend/pkg/grpc/clients"
 	"github.com/tekenradar/researcher-backend/pkg/types"
@@ -9,26 +12,36 @@ import (
 type HttpEndpoints struct {
 	clients                 *clients.APIClients
 	researcherDB            *db.ResearcherDBService
+	datasetStorage          datasets.Storage
 	samlConfig              *types.SAMLConfig
+	samlSP                  *saml.ServiceProvider
 	useDummyLogin           bool
 	loginSuccessRedirectURL string
-	apiKeys                 []string
 }
 
 func NewHTTPHandler(
 	clients *clients.APIClients,
 	researcherDB *db.ResearcherDBService,
+	datasetStorage datasets.Storage,
 	samlConfig *types.SAMLConfig,
 	useDummyLogin bool,
 	loginSuccessRedirectURL string,
-	apiKeys []string,
 ) *HttpEndpoints {
-	return &HttpEndpoints{
+	h := &HttpEndpoints{
 		clients:                 clients,
 		researcherDB:            researcherDB,
+		datasetStorage:          datasetStorage,
 		samlConfig:              samlConfig,
 		useDummyLogin:           useDummyLogin,
 		loginSuccessRedirectURL: loginSuccessRedirectURL,
-		apiKeys:                 apiKeys,
 	}
+
+	if samlConfig != nil && len(samlConfig.MetaDataURL) > 0 {
+		sp, err := initSAMLServiceProvider(samlConfig)
+		if err != nil {
+			logger.Error.Fatal(err)
+		}
+		h.samlSP = sp
+	}
+	return h
 }
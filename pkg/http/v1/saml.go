@@ -0,0 +1,263 @@
+package v1
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/coneno/logger"
+	"github.com/crewjam/saml"
+	"github.com/crewjam/saml/samlsp"
+	"github.com/gin-gonic/gin"
+	"github.com/tekenradar/researcher-backend/pkg/http/utils"
+	"github.com/tekenradar/researcher-backend/pkg/types"
+)
+
+const (
+	samlMetadataPath = "/v1/auth/saml/metadata"
+	samlACSPath      = "/v1/auth/saml/acs"
+)
+
+var samlEmailAttributes = []string{
+	"email",
+	"mail",
+	"urn:oid:0.9.2342.19200300.100.1.3",
+	"http://schemas.xmlsoap.org/ws/2005/05/identity/claims/emailaddress",
+}
+
+// initSAMLServiceProvider loads the SP key pair and the IdP metadata as configured and creates the service provider
+func initSAMLServiceProvider(conf *types.SAMLConfig) (*saml.ServiceProvider, error) {
+	keyPair, err := tls.LoadX509KeyPair(conf.SessionCertPath, conf.SessionKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load SAML session key pair: %v", err)
+	}
+
+	idpMetadata, err := loadIDPMetadata(conf.MetaDataURL)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load IdP metadata: %v", err)
+	}
+	return newSAMLServiceProvider(conf, idpMetadata, keyPair)
+}
+
+// loadIDPMetadata fetches the metadata from the IdP, or reads it from a local file if no http(s) url is used
+func loadIDPMetadata(metadataURL string) (*saml.EntityDescriptor, error) {
+	if !strings.HasPrefix(metadataURL, "http://") && !strings.HasPrefix(metadataURL, "https://") {
+		content, err := os.ReadFile(strings.TrimPrefix(metadataURL, "file://"))
+		if err != nil {
+			return nil, err
+		}
+		return samlsp.ParseMetadata(content)
+	}
+
+	u, err := url.Parse(metadataURL)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	return samlsp.FetchMetadata(ctx, http.DefaultClient, *u)
+}
+
+// newSAMLServiceProvider creates the service provider from an already loaded IdP metadata and SP key pair
+func newSAMLServiceProvider(conf *types.SAMLConfig, idpMetadata *saml.EntityDescriptor, keyPair tls.Certificate) (*saml.ServiceProvider, error) {
+	if len(keyPair.Certificate) < 1 {
+		return nil, errors.New("SAML key pair contains no certificate")
+	}
+	cert, err := x509.ParseCertificate(keyPair.Certificate[0])
+	if err != nil {
+		return nil, err
+	}
+	key, ok := keyPair.PrivateKey.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("SAML session key must be an RSA private key")
+	}
+
+	rootURL, err := url.Parse(strings.TrimSuffix(conf.SPRootUrl, "/"))
+	if err != nil {
+		return nil, err
+	}
+	metadataURL := *rootURL
+	metadataURL.Path = rootURL.Path + samlMetadataPath
+	acsURL := *rootURL
+	acsURL.Path = rootURL.Path + samlACSPath
+
+	return &saml.ServiceProvider{
+		EntityID:          conf.EntityID,
+		Key:               key,
+		Certificate:       cert,
+		MetadataURL:       metadataURL,
+		AcsURL:            acsURL,
+		IDPMetadata:       idpMetadata,
+		AllowIDPInitiated: false,
+	}, nil
+}
+
+func (h *HttpEndpoints) samlMetadata(c *gin.Context) {
+	buf, err := xml.MarshalIndent(h.samlSP.Metadata(), "", "  ")
+	if err != nil {
+		logger.Error.Printf("error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Data(http.StatusOK, "application/samlmetadata+xml", buf)
+}
+
+func (h *HttpEndpoints) samlLogin(c *gin.Context) {
+	authReq, err := h.samlSP.MakeAuthenticationRequest(
+		h.samlSP.GetSSOBindingLocation(saml.HTTPRedirectBinding),
+		saml.HTTPRedirectBinding,
+		saml.HTTPPostBinding,
+	)
+	if err != nil {
+		logger.Error.Printf("cannot create SAML authentication request: %v", err)
+		h.redirectLoginFailed(c)
+		return
+	}
+
+	redirectURL, err := authReq.Redirect("", h.samlSP)
+	if err != nil {
+		logger.Error.Printf("cannot create SAML redirect: %v", err)
+		h.redirectLoginFailed(c)
+		return
+	}
+
+	// The IdP posts the response cross-site, so the cookie has to be allowed on those requests
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     utils.SAMLRequestIDCookieName,
+		Value:    authReq.ID,
+		Path:     h.samlSP.AcsURL.Path,
+		MaxAge:   utils.InitSessionTokenAge,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteNoneMode,
+	})
+	c.Redirect(http.StatusFound, redirectURL.String())
+}
+
+var errSAMLNoAccess = errors.New("no access to tekenradar")
+
+func (h *HttpEndpoints) samlACS(c *gin.Context) {
+	email, err := h.authenticateSAMLResponse(c)
+	if err != nil {
+		if err == errSAMLNoAccess {
+			logger.Warning.Printf("user '%s' logged in via SAML without access to tekenradar", email)
+		} else {
+			logger.Error.Printf("SAML login failed: %v", err)
+		}
+		h.redirectLoginFailed(c)
+		return
+	}
+
+	logger.Info.Printf("user '%s' logged in via SAML", email)
+	h.redirectLoginSuccess(c, email, h.getRolesForUser(email))
+}
+
+// authenticateSAMLResponse validates the response posted by the IdP and returns the email address of the user.
+// If the user has no access to tekenradar, the email is returned together with errSAMLNoAccess.
+func (h *HttpEndpoints) authenticateSAMLResponse(c *gin.Context) (string, error) {
+	if err := c.Request.ParseForm(); err != nil {
+		return "", fmt.Errorf("cannot parse SAML response form: %v", err)
+	}
+
+	possibleRequestIDs := []string{}
+	if requestID, err := c.Cookie(utils.SAMLRequestIDCookieName); err == nil && len(requestID) > 0 {
+		possibleRequestIDs = append(possibleRequestIDs, requestID)
+	}
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     utils.SAMLRequestIDCookieName,
+		Value:    "",
+		Path:     h.samlSP.AcsURL.Path,
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteNoneMode,
+	})
+
+	assertion, err := h.samlSP.ParseResponse(c.Request, possibleRequestIDs)
+	if err != nil {
+		var ire *saml.InvalidResponseError
+		if errors.As(err, &ire) {
+			return "", fmt.Errorf("invalid SAML response from %s: %v", h.samlConfig.IDPUrl, ire.PrivateErr)
+		}
+		return "", fmt.Errorf("invalid SAML response from %s: %v", h.samlConfig.IDPUrl, err)
+	}
+
+	email := getEmailFromAssertion(assertion)
+	if len(email) < 1 {
+		return "", errors.New("SAML assertion does not contain an email address")
+	}
+
+	if !hasTekenradarAccess(assertion, h.samlConfig.AttributeForTekenradarAccess) {
+		return email, errSAMLNoAccess
+	}
+	return email, nil
+}
+
+func (h *HttpEndpoints) redirectLoginFailed(c *gin.Context) {
+	if len(h.samlConfig.LoginFailedRedirectURL) < 1 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "login failed"})
+		return
+	}
+	c.Redirect(http.StatusFound, h.samlConfig.LoginFailedRedirectURL)
+}
+
+func getAssertionAttributeValues(assertion *saml.Assertion, name string) []string {
+	values := []string{}
+	for _, statement := range assertion.AttributeStatements {
+		for _, attr := range statement.Attributes {
+			if attr.Name != name && attr.FriendlyName != name {
+				continue
+			}
+			for _, v := range attr.Values {
+				values = append(values, v.Value)
+			}
+		}
+	}
+	return values
+}
+
+func getEmailFromAssertion(assertion *saml.Assertion) string {
+	for _, attrName := range samlEmailAttributes {
+		for _, v := range getAssertionAttributeValues(assertion, attrName) {
+			if len(v) > 0 {
+				return v
+			}
+		}
+	}
+	if assertion.Subject != nil && assertion.Subject.NameID != nil && strings.Contains(assertion.Subject.NameID.Value, "@") {
+		return assertion.Subject.NameID.Value
+	}
+	return ""
+}
+
+// hasTekenradarAccess checks the access attribute, configured either as "attributeName" (any non-empty value
+// other than "false" grants access) or as "attributeName=requiredValue"
+func hasTekenradarAccess(assertion *saml.Assertion, accessAttribute string) bool {
+	if len(accessAttribute) < 1 {
+		return true
+	}
+
+	parts := strings.SplitN(accessAttribute, "=", 2)
+	values := getAssertionAttributeValues(assertion, parts[0])
+	for _, v := range values {
+		if len(parts) > 1 {
+			if v == parts[1] {
+				return true
+			}
+			continue
+		}
+		if len(v) > 0 && v != "false" {
+			return true
+		}
+	}
+	return false
+}
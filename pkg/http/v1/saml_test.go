@@ -0,0 +1,255 @@
+package v1
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/beevik/etree"
+	"github.com/crewjam/saml"
+	"github.com/gin-gonic/gin"
+	"github.com/tekenradar/researcher-backend/pkg/http/utils"
+	"github.com/tekenradar/researcher-backend/pkg/types"
+)
+
+const (
+	testSAMLRequestID      = "id-test-request"
+	testSAMLFailedRedirect = "https://researcher.example.org/login-failed"
+)
+
+// generateTestKeyPair creates a self-signed RSA key pair, as used by the IdP and the service provider
+func generateTestKeyPair(t *testing.T, commonName string) tls.Certificate {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func newTestIDP(t *testing.T) *saml.IdentityProvider {
+	t.Helper()
+	keyPair := generateTestKeyPair(t, "idp.example.org")
+	cert, err := x509.ParseCertificate(keyPair.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	metadataURL, _ := url.Parse("https://idp.example.org/metadata")
+	ssoURL, _ := url.Parse("https://idp.example.org/sso")
+	return &saml.IdentityProvider{
+		Key:         keyPair.PrivateKey,
+		Certificate: cert,
+		MetadataURL: *metadataURL,
+		SSOURL:      *ssoURL,
+	}
+}
+
+func newTestSAMLEndpoints(t *testing.T, idp *saml.IdentityProvider, accessAttribute string) *HttpEndpoints {
+	t.Helper()
+	conf := &types.SAMLConfig{
+		IDPUrl:                       "https://idp.example.org",
+		SPRootUrl:                    "https://researcher-api.example.org/",
+		EntityID:                     "https://researcher-api.example.org/sp",
+		LoginFailedRedirectURL:       testSAMLFailedRedirect,
+		AttributeForTekenradarAccess: accessAttribute,
+	}
+	sp, err := newSAMLServiceProvider(conf, idp.Metadata(), generateTestKeyPair(t, "sp.example.org"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &HttpEndpoints{samlConfig: conf, samlSP: sp}
+}
+
+// makeSAMLResponse returns the base64 encoded response to the test request, signed by the IdP
+func makeSAMLResponse(t *testing.T, idp *saml.IdentityProvider, sp *saml.ServiceProvider, attributes []saml.Attribute) string {
+	t.Helper()
+	spMetadata := sp.Metadata()
+	req := &saml.IdpAuthnRequest{
+		IDP:         idp,
+		HTTPRequest: httptest.NewRequest(http.MethodGet, idp.SSOURL.String(), nil),
+		Request: saml.AuthnRequest{
+			ID:     testSAMLRequestID,
+			Issuer: &saml.Issuer{Value: sp.EntityID},
+		},
+		ServiceProviderMetadata: spMetadata,
+		SPSSODescriptor:         &spMetadata.SPSSODescriptors[0],
+		ACSEndpoint:             &saml.IndexedEndpoint{Binding: saml.HTTPPostBinding, Location: sp.AcsURL.String()},
+		Now:                     saml.TimeNow(),
+	}
+	session := &saml.Session{
+		ID:               "session-1",
+		CreateTime:       saml.TimeNow(),
+		ExpireTime:       saml.TimeNow().Add(time.Hour),
+		NameID:           "user-1",
+		CustomAttributes: attributes,
+	}
+	if err := (saml.DefaultAssertionMaker{}).MakeAssertion(req, session); err != nil {
+		t.Fatal(err)
+	}
+	if err := req.MakeResponse(); err != nil {
+		t.Fatal(err)
+	}
+
+	doc := etree.NewDocument()
+	doc.SetRoot(req.ResponseEl)
+	buf, err := doc.WriteToBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(buf)
+}
+
+func newSAMLACSContext(sp *saml.ServiceProvider, samlResponse string) (*gin.Context, *httptest.ResponseRecorder) {
+	form := url.Values{"SAMLResponse": {samlResponse}}
+	req := httptest.NewRequest(http.MethodPost, sp.AcsURL.String(), strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(&http.Cookie{Name: utils.SAMLRequestIDCookieName, Value: testSAMLRequestID})
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+	return c, w
+}
+
+func samlAttribute(name string, value string) saml.Attribute {
+	return saml.Attribute{
+		Name:       name,
+		NameFormat: "urn:oasis:names:tc:SAML:2.0:attrname-format:basic",
+		Values:     []saml.AttributeValue{{Type: "xs:string", Value: value}},
+	}
+}
+
+func TestNewSAMLServiceProvider(t *testing.T) {
+	idp := newTestIDP(t)
+
+	t.Run("urls from root url", func(t *testing.T) {
+		h := newTestSAMLEndpoints(t, idp, "")
+		if got := h.samlSP.AcsURL.String(); got != "https://researcher-api.example.org/v1/auth/saml/acs" {
+			t.Errorf("unexpected ACS url: %s", got)
+		}
+		if got := h.samlSP.MetadataURL.String(); got != "https://researcher-api.example.org/v1/auth/saml/metadata" {
+			t.Errorf("unexpected metadata url: %s", got)
+		}
+		if h.samlSP.AllowIDPInitiated {
+			t.Error("IdP initiated login must not be allowed")
+		}
+	})
+
+	t.Run("key pair without certificate", func(t *testing.T) {
+		keyPair := generateTestKeyPair(t, "sp.example.org")
+		keyPair.Certificate = nil
+		if _, err := newSAMLServiceProvider(&types.SAMLConfig{}, idp.Metadata(), keyPair); err == nil {
+			t.Error("expected an error")
+		}
+	})
+}
+
+func TestSAMLACS(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	idp := newTestIDP(t)
+	otherIDP := newTestIDP(t)
+	otherIDP.MetadataURL = idp.MetadataURL // same issuer, but not the configured signing key
+
+	emailAttribute := samlAttribute("email", "researcher@example.org")
+	accessAttribute := samlAttribute("tekenradar", "researcher")
+
+	tests := []struct {
+		name            string
+		signedBy        *saml.IdentityProvider
+		attributes      []saml.Attribute
+		accessAttribute string
+		wantErr         bool
+		wantNoAccess    bool
+	}{
+		{
+			name:            "valid assertion",
+			signedBy:        idp,
+			attributes:      []saml.Attribute{emailAttribute, accessAttribute},
+			accessAttribute: "tekenradar=researcher",
+		},
+		{
+			name:            "valid assertion without access check",
+			signedBy:        idp,
+			attributes:      []saml.Attribute{emailAttribute},
+			accessAttribute: "",
+		},
+		{
+			name:            "bad signature",
+			signedBy:        otherIDP,
+			attributes:      []saml.Attribute{emailAttribute, accessAttribute},
+			accessAttribute: "tekenradar=researcher",
+			wantErr:         true,
+		},
+		{
+			name:            "missing access attribute",
+			signedBy:        idp,
+			attributes:      []saml.Attribute{emailAttribute},
+			accessAttribute: "tekenradar",
+			wantErr:         true,
+			wantNoAccess:    true,
+		},
+		{
+			name:            "wrong access attribute value",
+			signedBy:        idp,
+			attributes:      []saml.Attribute{emailAttribute, samlAttribute("tekenradar", "none")},
+			accessAttribute: "tekenradar=researcher",
+			wantErr:         true,
+			wantNoAccess:    true,
+		},
+		{
+			name:            "missing email",
+			signedBy:        idp,
+			attributes:      []saml.Attribute{accessAttribute},
+			accessAttribute: "tekenradar",
+			wantErr:         true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestSAMLEndpoints(t, idp, tt.accessAttribute)
+			samlResponse := makeSAMLResponse(t, tt.signedBy, h.samlSP, tt.attributes)
+
+			c, _ := newSAMLACSContext(h.samlSP, samlResponse)
+			email, err := h.authenticateSAMLResponse(c)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if (err == errSAMLNoAccess) != tt.wantNoAccess {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if !tt.wantErr && email != "researcher@example.org" {
+				t.Errorf("unexpected email: %s", email)
+			}
+
+			// failed logins are redirected before tokens are issued
+			if tt.wantErr {
+				c, w := newSAMLACSContext(h.samlSP, samlResponse)
+				h.samlACS(c)
+				if c.Writer.Status() != http.StatusFound || w.Header().Get("Location") != testSAMLFailedRedirect {
+					t.Errorf("expected redirect to the login failed url, got %d %s", c.Writer.Status(), w.Header().Get("Location"))
+				}
+			}
+		})
+	}
+}
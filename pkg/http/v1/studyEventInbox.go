@@ -0,0 +1,73 @@
+package v1
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/coneno/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/tekenradar/researcher-backend/pkg/jwt"
+	"github.com/tekenradar/researcher-backend/pkg/studyevents"
+	"github.com/tekenradar/researcher-backend/pkg/types"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	defaultStudyEventInboxLimit = 100
+)
+
+func (h *HttpEndpoints) SM_getStudyEvents(c *gin.Context) {
+	token := c.MustGet("validatedToken").(*jwt.UserClaims)
+
+	query := types.StudyEventInboxQuery{
+		Status:    c.DefaultQuery("status", types.STUDY_EVENT_INBOX_STATUS_FAILED),
+		EventType: c.DefaultQuery("eventType", ""),
+		Limit:     defaultStudyEventInboxLimit,
+	}
+	if n, err := strconv.ParseInt(c.DefaultQuery("limit", ""), 10, 64); err == nil {
+		query.Limit = n
+	}
+
+	events, err := h.researcherDB.FindStudyEventsInInbox(query)
+	h.writeAuditLog(c, types.AUDIT_ACTION_READ_STUDY_EVENTS, "", "", map[string]string{
+		"status":    query.Status,
+		"eventType": query.EventType,
+		"limit":     strconv.FormatInt(query.Limit, 10),
+		"count":     strconv.Itoa(len(events)),
+	}, err)
+	if err != nil {
+		logger.Error.Printf("error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	logger.Info.Printf("study events (%s) fetched by '%s'", query.Status, token.ID)
+	c.JSON(http.StatusOK, gin.H{"studyEvents": events})
+}
+
+func (h *HttpEndpoints) SM_replayStudyEvent(c *gin.Context) {
+	token := c.MustGet("validatedToken").(*jwt.UserClaims)
+	eventID := c.Param("eventID")
+
+	event, err := h.researcherDB.ReplayStudyEvent(eventID)
+	h.writeAuditLog(c, types.AUDIT_ACTION_REPLAY_STUDY_EVENT, "", eventID, nil, err)
+	if err != nil {
+		logger.Error.Printf("error: %v", err)
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusNotFound, gin.H{"error": "no failed study event with this id"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	logger.Info.Printf("study event '%s' replayed by '%s'", eventID, token.ID)
+	c.JSON(http.StatusOK, event)
+}
+
+func (h *HttpEndpoints) SM_getStudyEventMetrics(c *gin.Context) {
+	token := c.MustGet("validatedToken").(*jwt.UserClaims)
+
+	logger.Info.Printf("study event metrics fetched by '%s'", token.ID)
+	c.JSON(http.StatusOK, studyevents.GetMetrics())
+}
@@ -1,32 +1,30 @@
 package v1
 
 import (
-	"bytes"
-	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/coneno/logger"
 	"github.com/gin-gonic/gin"
+	"github.com/tekenradar/researcher-backend/pkg/datasets"
+	"github.com/tekenradar/researcher-backend/pkg/db"
 	mw "github.com/tekenradar/researcher-backend/pkg/http/middlewares"
 	"github.com/tekenradar/researcher-backend/pkg/jwt"
 	"github.com/tekenradar/researcher-backend/pkg/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 	"google.golang.org/grpc/status"
-
-	"github.com/influenzanet/go-utils/pkg/api_types"
-	studyAPI "github.com/influenzanet/study-service/pkg/api"
-)
-
-const (
-	instanceID = "tekenradar"
 )
 
 func (h *HttpEndpoints) AddStudyAccessAPI(rg *gin.RouterGroup) {
 	studiesGroup := rg.Group("/substudy")
 
-	studiesGroup.Use(mw.HasValidAPIKey(h.apiKeys))
+	studiesGroup.Use(mw.HasValidAPIKey(h.researcherDB, types.API_KEY_SCOPE_RESEARCHER_APP))
 	studiesGroup.Use(mw.ValidateToken())
 	{
 		studiesGroup.GET("/infos", h.getStudyInfos)
@@ -34,18 +32,42 @@ func (h *HttpEndpoints) AddStudyAccessAPI(rg *gin.RouterGroup) {
 		studyGroup := studiesGroup.Group(":substudyKey")
 		studyGroup.Use(mw.HasAccessToStudy(h.researcherDB))
 		{
-			studyGroup.GET("/", h.getStudyInfo)
-			studyGroup.GET("/data/:datasetKey", h.downloadDataset) // ? from=1213123&until=12313212
-			studyGroup.GET("/participant-contacts", h.getParticipantContacts)
-			studyGroup.GET("/participant-contacts/:contactID", h.getParticipantContact)
-			studyGroup.GET("/participant-contacts/:contactID/keep", h.changeParticipantContactKeepStatus) // ?value=true
-			studyGroup.POST("/participant-contacts/:contactID/note", h.addNoteToParticipantContact)
-			studyGroup.DELETE("/participant-contacts/:contactID", h.deleteParticipantContact)
-
-			// TODO: fetch notification subscriptions
-			studyGroup.GET("/notifications", h.fetchNotificationSubscriptions) // ?topic=value
-			studyGroup.POST("/notifications", h.addNotificationSubscription)
-			studyGroup.DELETE("/notifications/:notificationID", h.deleteNotificationSubscription)
+			studyGroup.GET("/", mw.RequireStudyPermission(types.STUDY_PERMISSION_READ_STUDY_INFO), h.getStudyInfo)
+			studyGroup.GET("/data/:datasetKey", mw.RequireStudyPermission(types.STUDY_PERMISSION_EXPORT_DATA), h.downloadDataset) // ? from=1213123&until=12313212&format=wide|long|jsonl|parquet
+
+			exportJobsGroup := studyGroup.Group("/data/:datasetKey/jobs")
+			exportJobsGroup.Use(mw.RequireStudyPermission(types.STUDY_PERMISSION_EXPORT_DATA))
+			{
+				exportJobsGroup.POST("", h.createDatasetExportJob) // same query parameters as the download
+				exportJobsGroup.GET("", h.getDatasetExportJobs)
+				exportJobsGroup.GET("/:jobID", h.getDatasetExportJob)
+				exportJobsGroup.GET("/:jobID/download", h.downloadDatasetExportJobResult)
+			}
+
+			contactsGroup := studyGroup.Group("/participant-contacts")
+			{
+				contactsGroup.GET("", mw.RequireStudyPermission(types.STUDY_PERMISSION_READ_CONTACTS), h.getParticipantContacts)             // ?limit=&cursor=&sort=&keep=&minAge=&maxAge=&gender=&otherStudies=&hasNotes=&updated=&status=&search=
+				contactsGroup.GET("/export", mw.RequireStudyPermission(types.STUDY_PERMISSION_EXPORT_CONTACTS), h.exportParticipantContacts) // ?format=csv|xlsx&columns=&<listing filters>
+				contactsGroup.GET("/:contactID", mw.RequireStudyPermission(types.STUDY_PERMISSION_READ_CONTACTS), h.getParticipantContact)
+				contactsGroup.GET("/:contactID/keep", mw.RequireStudyPermission(types.STUDY_PERMISSION_MANAGE_CONTACTS), h.changeParticipantContactKeepStatus) // ?value=true
+				contactsGroup.POST("/:contactID/status", mw.RequireStudyPermission(types.STUDY_PERMISSION_MANAGE_CONTACTS), h.changeParticipantContactStatus)
+				contactsGroup.POST("/:contactID/acknowledge-update", mw.RequireStudyPermission(types.STUDY_PERMISSION_MANAGE_CONTACTS), h.acknowledgeParticipantContactUpdate)
+				contactsGroup.POST("/:contactID/note", mw.RequireStudyPermission(types.STUDY_PERMISSION_MANAGE_CONTACTS), h.addNoteToParticipantContact)
+				contactsGroup.PUT("/:contactID/notes/:noteID", mw.RequireStudyPermission(types.STUDY_PERMISSION_MANAGE_CONTACTS), h.updateParticipantContactNote)
+				contactsGroup.DELETE("/:contactID/notes/:noteID", mw.RequireStudyPermission(types.STUDY_PERMISSION_MANAGE_CONTACTS), h.deleteParticipantContactNote)
+				contactsGroup.POST("/:contactID/notes/:noteID/attachments", mw.RequireStudyPermission(types.STUDY_PERMISSION_MANAGE_CONTACTS), h.addNoteAttachment) // multipart form with "file"
+				contactsGroup.GET("/:contactID/notes/:noteID/attachments/:attachmentID", mw.RequireStudyPermission(types.STUDY_PERMISSION_READ_CONTACTS), h.downloadNoteAttachment)
+				contactsGroup.DELETE("/:contactID/notes/:noteID/attachments/:attachmentID", mw.RequireStudyPermission(types.STUDY_PERMISSION_MANAGE_CONTACTS), h.deleteNoteAttachment)
+				contactsGroup.DELETE("/:contactID", mw.RequireStudyPermission(types.STUDY_PERMISSION_MANAGE_CONTACTS), h.deleteParticipantContact)
+			}
+
+			notificationsGroup := studyGroup.Group("/notifications")
+			notificationsGroup.Use(mw.RequireStudyPermission(types.STUDY_PERMISSION_MANAGE_NOTIFICATIONS))
+			{
+				notificationsGroup.GET("", h.fetchNotificationSubscriptions) // ?topic=value
+				notificationsGroup.POST("", h.addNotificationSubscription)
+				notificationsGroup.DELETE("/:notificationID", h.deleteNotificationSubscription)
+			}
 		}
 	}
 }
@@ -65,10 +87,13 @@ func (h *HttpEndpoints) getStudyInfos(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"studyInfos": studyInfos})
 }
 
+// filterStudyInfos keeps the studies the user has a role in, with the user's permissions attached
 func filterStudyInfos(studyInfos []types.StudyInfo, email string) []types.StudyInfo {
 	var filteredInfos []types.StudyInfo
 	for _, info := range studyInfos {
-		if contains(info.AccessControl.Emails, email) {
+		role := info.GetRoleOfUser(email)
+		if len(role) > 0 {
+			info.Permissions = types.GetPermissionsForStudyRole(role)
 			filteredInfos = append(filteredInfos, info)
 		}
 	}
@@ -97,6 +122,8 @@ func (h *HttpEndpoints) getStudyInfo(c *gin.Context) {
 	}
 	logger.Info.Printf("study info for %s fetched by '%s'", substudyKey, token.ID)
 
+	studyInfo.Permissions = types.GetPermissionsForStudyRole(c.MustGet("studyRole").(string))
+	studyInfo.ContactFeatureConfig.StatusPipeline = studyInfo.GetContactStatusPipeline()
 	c.JSON(http.StatusOK, studyInfo)
 }
 
@@ -104,7 +131,36 @@ func (h *HttpEndpoints) getParticipantContacts(c *gin.Context) {
 	token := c.MustGet("validatedToken").(*jwt.UserClaims)
 	substudyKey := c.Param("substudyKey")
 
-	pcs, err := h.researcherDB.FindParticipantContacts(substudyKey)
+	query, err := parseParticipantContactQuery(c)
+	if err != nil {
+		logger.Error.Printf("%v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(query.Status) > 0 {
+		studyInfo := c.MustGet("studyInfo").(types.StudyInfo)
+		query.StatusMissing = query.Status == studyInfo.GetInitialContactStatus()
+	}
+
+	pcs, nextCursor, err := h.researcherDB.FindParticipantContacts(substudyKey, query)
+	h.writeAuditLog(c, types.AUDIT_ACTION_READ_PARTICIPANT_CONTACTS, substudyKey, "", participantContactQueryAuditParams(c), err)
+	if err != nil {
+		logger.Error.Printf("%v", err)
+		if err == db.ErrInvalidCursor {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err})
+		return
+	}
+
+	filteredCount, err := h.researcherDB.CountParticipantContacts(substudyKey, query)
+	if err != nil {
+		logger.Error.Printf("%v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err})
+		return
+	}
+	totalCount, err := h.researcherDB.CountParticipantContacts(substudyKey, types.ParticipantContactQuery{})
 	if err != nil {
 		logger.Error.Printf("%v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err})
@@ -112,7 +168,98 @@ func (h *HttpEndpoints) getParticipantContacts(c *gin.Context) {
 	}
 	logger.Info.Printf("partcipant contacts for %s fetched by '%s'", substudyKey, token.ID)
 
-	c.JSON(http.StatusOK, gin.H{"participantContacts": pcs})
+	for i := range pcs {
+		pcs[i].ExpiresAt = contactExpiresAt(c, pcs[i])
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"participantContacts": pcs,
+		"nextCursor":          nextCursor,
+		"filteredCount":       filteredCount,
+		"totalCount":          totalCount,
+	})
+}
+
+// contactExpiresAt computes when the contact data will be removed, using the retention policy of the study in the context
+func contactExpiresAt(c *gin.Context, pc types.ParticipantContact) int64 {
+	return c.MustGet("studyInfo").(types.StudyInfo).ContactDataExpiresAt(pc)
+}
+
+const (
+	defaultParticipantContactsPageSize = 50
+	maxParticipantContactsPageSize     = 500
+)
+
+// parseParticipantContactQuery reads ?limit=&cursor=&sort=asc|desc&keep=&minAge=&maxAge=&gender=&otherStudies=&hasNotes=&updated=&status=&search=
+func parseParticipantContactQuery(c *gin.Context) (query types.ParticipantContactQuery, err error) {
+	query = types.ParticipantContactQuery{
+		Gender: c.DefaultQuery("gender", ""),
+		Status: c.DefaultQuery("status", ""),
+		Search: strings.TrimSpace(c.DefaultQuery("search", "")),
+		Cursor: c.DefaultQuery("cursor", ""),
+		Limit:  defaultParticipantContactsPageSize,
+	}
+
+	switch c.DefaultQuery("sort", "desc") {
+	case "asc":
+		query.SortAscending = true
+	case "desc":
+	default:
+		return query, errors.New("sort must be 'asc' or 'desc'")
+	}
+
+	if v := c.DefaultQuery("limit", ""); len(v) > 0 {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 1 || n > maxParticipantContactsPageSize {
+			return query, fmt.Errorf("limit must be between 1 and %d", maxParticipantContactsPageSize)
+		}
+		query.Limit = n
+	}
+
+	boolParams := map[string]**bool{
+		"keep":         &query.KeepContactData,
+		"otherStudies": &query.OtherStudies,
+		"hasNotes":     &query.HasNotes,
+		"updated":      &query.Updated,
+	}
+	for name, target := range boolParams {
+		v := c.DefaultQuery(name, "")
+		if len(v) == 0 {
+			continue
+		}
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return query, fmt.Errorf("%s must be true or false", name)
+		}
+		*target = &b
+	}
+
+	intParams := map[string]**int{
+		"minAge": &query.MinAge,
+		"maxAge": &query.MaxAge,
+	}
+	for name, target := range intParams {
+		v := c.DefaultQuery(name, "")
+		if len(v) == 0 {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return query, fmt.Errorf("%s must be a number", name)
+		}
+		*target = &n
+	}
+	return query, nil
+}
+
+// participantContactQueryAuditParams keeps the filters of a listing for the audit log
+func participantContactQueryAuditParams(c *gin.Context) map[string]string {
+	params := map[string]string{}
+	for _, name := range []string{"keep", "minAge", "maxAge", "gender", "otherStudies", "hasNotes", "updated", "status", "search", "sort", "limit", "cursor"} {
+		if v := c.DefaultQuery(name, ""); len(v) > 0 {
+			params[name] = v
+		}
+	}
+	return params
 }
 
 func (h *HttpEndpoints) getParticipantContact(c *gin.Context) {
@@ -121,6 +268,7 @@ func (h *HttpEndpoints) getParticipantContact(c *gin.Context) {
 	contactID := c.Param("contactID")
 
 	pc, err := h.researcherDB.FindParticipantContactByID(substudyKey, contactID)
+	h.writeAuditLog(c, types.AUDIT_ACTION_READ_PARTICIPANT_CONTACT, substudyKey, contactID, nil, err)
 	if err != nil {
 		logger.Error.Printf("%v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err})
@@ -128,6 +276,7 @@ func (h *HttpEndpoints) getParticipantContact(c *gin.Context) {
 	}
 	logger.Info.Printf("partcipant contact for %s fetched by '%s'", substudyKey, token.ID)
 
+	pc.ExpiresAt = contactExpiresAt(c, pc)
 	c.JSON(http.StatusOK, gin.H{"participantContact": pc})
 }
 
@@ -139,190 +288,218 @@ func (h *HttpEndpoints) changeParticipantContactKeepStatus(c *gin.Context) {
 	keep := c.DefaultQuery("value", "") == "true"
 
 	err := h.researcherDB.UpdateKeepParticipantContactStatus(substudyKey, contactID, keep)
+	h.writeAuditLog(c, types.AUDIT_ACTION_UPDATE_CONTACT_KEEP_STATUS, substudyKey, contactID, map[string]string{"value": strconv.FormatBool(keep)}, err)
 	if err != nil {
 		logger.Error.Printf("%v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err})
 		return
 	}
 
-	pcs, err := h.researcherDB.FindParticipantContacts(substudyKey)
+	pc, err := h.researcherDB.FindParticipantContactByID(substudyKey, contactID)
 	if err != nil {
 		logger.Error.Printf("%v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err})
 		return
 	}
-	logger.Info.Printf("partcipant contacts for %s fetched by '%s'", substudyKey, token.ID)
+	logger.Info.Printf("keep status of partcipant contact %s in %s changed by '%s'", contactID, substudyKey, token.ID)
 
-	c.JSON(http.StatusOK, gin.H{"participantContacts": pcs})
+	pc.ExpiresAt = contactExpiresAt(c, pc)
+	c.JSON(http.StatusOK, gin.H{"participantContact": pc})
 }
 
-func (h *HttpEndpoints) addNoteToParticipantContact(c *gin.Context) {
+type ChangeContactStatusRequest struct {
+	Status  string `json:"status" binding:"required"`
+	Comment string `json:"comment"`
+}
+
+func (h *HttpEndpoints) changeParticipantContactStatus(c *gin.Context) {
 	token := c.MustGet("validatedToken").(*jwt.UserClaims)
+	studyInfo := c.MustGet("studyInfo").(types.StudyInfo)
 	substudyKey := c.Param("substudyKey")
 	contactID := c.Param("contactID")
 
-	var req types.ContactNote
+	var req ChangeContactStatusRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		logger.Error.Printf("error: %v", err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	req.Author = token.ID
+	pc, err := h.researcherDB.FindParticipantContactByID(substudyKey, contactID)
+	if err != nil {
+		logger.Error.Printf("%v", err)
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusNotFound, gin.H{"error": "participant contact not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err})
+		return
+	}
+	currentStatus := pc.Status
+	if len(currentStatus) == 0 {
+		currentStatus = studyInfo.GetInitialContactStatus()
+	}
 
-	err := h.researcherDB.AddNoteToParticipantContact(substudyKey, contactID, req)
+	if _, ok := studyInfo.FindContactStatus(req.Status); !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown status: " + req.Status})
+		return
+	}
+	if !studyInfo.CanChangeContactStatus(currentStatus, req.Status) {
+		msg := fmt.Sprintf("status cannot change from '%s' to '%s'", currentStatus, req.Status)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	err = h.researcherDB.UpdateParticipantContactStatus(substudyKey, contactID, pc.Status, types.ContactStatusChange{
+		Time:    time.Now().Unix(),
+		Author:  token.ID,
+		From:    currentStatus,
+		To:      req.Status,
+		Comment: req.Comment,
+	})
+	h.writeAuditLog(c, types.AUDIT_ACTION_UPDATE_CONTACT_STATUS, substudyKey, contactID, map[string]string{"from": currentStatus, "to": req.Status}, err)
 	if err != nil {
 		logger.Error.Printf("%v", err)
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusConflict, gin.H{"error": "status was changed in the meantime"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err})
 		return
 	}
 
-	pcs, err := h.researcherDB.FindParticipantContacts(substudyKey)
+	pc, err = h.researcherDB.FindParticipantContactByID(substudyKey, contactID)
 	if err != nil {
 		logger.Error.Printf("%v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err})
 		return
 	}
-	logger.Info.Printf("partcipant contacts note added in study %s fetched by '%s'", substudyKey, token.ID)
+	logger.Info.Printf("status of partcipant contact %s in %s changed to '%s' by '%s'", contactID, substudyKey, req.Status, token.ID)
 
-	c.JSON(http.StatusOK, gin.H{"participantContacts": pcs})
+	pc.ExpiresAt = contactExpiresAt(c, pc)
+	c.JSON(http.StatusOK, gin.H{"participantContact": pc})
 }
 
-func (h *HttpEndpoints) deleteParticipantContact(c *gin.Context) {
+func (h *HttpEndpoints) acknowledgeParticipantContactUpdate(c *gin.Context) {
 	token := c.MustGet("validatedToken").(*jwt.UserClaims)
 	substudyKey := c.Param("substudyKey")
 	contactID := c.Param("contactID")
 
-	err := h.researcherDB.DeleteParticipantContact(substudyKey, contactID)
+	err := h.researcherDB.AcknowledgeParticipantContactUpdate(substudyKey, contactID)
+	h.writeAuditLog(c, types.AUDIT_ACTION_ACKNOWLEDGE_CONTACT_UPDATE, substudyKey, contactID, nil, err)
 	if err != nil {
 		logger.Error.Printf("%v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err})
 		return
 	}
 
-	pcs, err := h.researcherDB.FindParticipantContacts(substudyKey)
+	pc, err := h.researcherDB.FindParticipantContactByID(substudyKey, contactID)
 	if err != nil {
 		logger.Error.Printf("%v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err})
 		return
 	}
-	logger.Info.Printf("partcipant contacts note added in study %s fetched by '%s'", substudyKey, token.ID)
+	logger.Info.Printf("partcipant contact update acknowledged in study %s by '%s'", substudyKey, token.ID)
 
-	c.JSON(http.StatusOK, gin.H{"participantContacts": pcs})
+	pc.ExpiresAt = contactExpiresAt(c, pc)
+	c.JSON(http.StatusOK, gin.H{"participantContact": pc})
 }
 
-func (h *HttpEndpoints) downloadDataset(c *gin.Context) {
+func (h *HttpEndpoints) addNoteToParticipantContact(c *gin.Context) {
 	token := c.MustGet("validatedToken").(*jwt.UserClaims)
 	substudyKey := c.Param("substudyKey")
-	datasetKey := c.Param("datasetKey")
+	contactID := c.Param("contactID")
 
-	studyInfo, err := h.researcherDB.FindStudyInfo(substudyKey)
+	var req types.ContactNote
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Error.Printf("error: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	req.ID = primitive.NewObjectID().Hex()
+	req.Time = time.Now().Unix()
+	req.Author = token.ID
+	req.EditedAt = 0
+	req.EditHistory = nil
+	req.Attachments = nil
+
+	err := h.researcherDB.AddNoteToParticipantContact(substudyKey, contactID, req)
+	h.writeAuditLog(c, types.AUDIT_ACTION_ADD_CONTACT_NOTE, substudyKey, contactID, nil, err)
 	if err != nil {
 		logger.Error.Printf("%v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err})
 		return
 	}
 
-	var dataset *types.DatasetInfo
-	for _, datasetInfo := range studyInfo.AvailableDatasets {
-		if datasetKey == datasetInfo.ID {
-			dataset = &datasetInfo
-			break
-		}
-	}
-	if dataset == nil {
-		msg := fmt.Sprintf("no dataset info found in study %s for dataset id %s", substudyKey, datasetKey)
-		logger.Error.Println(msg)
-		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+	pc, err := h.researcherDB.FindParticipantContactByID(substudyKey, contactID)
+	if err != nil {
+		logger.Error.Printf("%v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err})
 		return
 	}
+	logger.Info.Printf("partcipant contacts note added in study %s fetched by '%s'", substudyKey, token.ID)
+
+	pc.ExpiresAt = contactExpiresAt(c, pc)
+	c.JSON(http.StatusOK, gin.H{"participantContact": pc})
+}
 
-	var req studyAPI.ResponseExportQuery
-	req.StudyKey = "tekenradar"
-	req.SurveyKey = dataset.SurveyKey
+func (h *HttpEndpoints) deleteParticipantContact(c *gin.Context) {
+	token := c.MustGet("validatedToken").(*jwt.UserClaims)
+	substudyKey := c.Param("substudyKey")
+	contactID := c.Param("contactID")
 
-	from := c.DefaultQuery("from", "")
-	if len(from) > 0 {
-		n, err := strconv.ParseInt(from, 10, 64)
-		if err == nil {
-			req.From = n
-		}
-	}
-	until := c.DefaultQuery("until", "")
-	if len(until) > 0 {
-		n, err := strconv.ParseInt(until, 10, 64)
-		if err == nil {
-			req.Until = n
-		}
-	}
-	req.IncludeMeta = &studyAPI.ResponseExportQuery_IncludeMeta{
-		Position:       c.DefaultQuery("withPositions", "false") == "true",
-		InitTimes:      c.DefaultQuery("withInitTimes", "false") == "true",
-		DisplayedTimes: c.DefaultQuery("withDisplayTimes", "false") == "true",
-		ResponsedTimes: c.DefaultQuery("withResponseTimes", "false") == "true",
+	err := h.researcherDB.DeleteParticipantContact(substudyKey, contactID)
+	h.writeAuditLog(c, types.AUDIT_ACTION_DELETE_PARTICIPANT_CONTACT, substudyKey, contactID, nil, err)
+	if err != nil {
+		logger.Error.Printf("%v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err})
+		return
 	}
-	req.Separator = c.DefaultQuery("sep", "-")
-	req.ShortQuestionKeys = c.DefaultQuery("shortKeys", "true") == "true"
 
-	req.Token = &api_types.TokenInfos{
-		Id:         token.ID,
-		InstanceId: instanceID,
-		Payload: map[string]string{
-			"roles": "SERVICE",
-		},
-	}
+	logger.Info.Printf("partcipant contact %s in study %s deleted by '%s'", contactID, substudyKey, token.ID)
 
-	// Check if query valid:
+	c.JSON(http.StatusOK, gin.H{"message": "participant contact deleted", "id": contactID})
+}
 
-	if dataset.EndDate > 0 && req.Until > dataset.EndDate {
-		logger.Debug.Println("trying to access data that is later than allowed")
-		logger.Error.Printf("user %s tried to access dataset %s", token.ID, datasetKey)
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "no permission to access this dataset"})
-		return
-	}
-	if dataset.StartDate > 0 && req.From < dataset.StartDate {
-		logger.Debug.Println("trying to access data that is earlier than allowed")
-		logger.Error.Printf("user %s tried to access dataset %s", token.ID, datasetKey)
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "no permission to access this dataset"})
-		return
-	}
+func (h *HttpEndpoints) downloadDataset(c *gin.Context) {
+	token := c.MustGet("validatedToken").(*jwt.UserClaims)
+	substudyKey := c.Param("substudyKey")
+	datasetKey := c.Param("datasetKey")
 
-	req.ItemFilter = &studyAPI.ResponseExportQuery_ItemFilter{
-		Mode: studyAPI.ResponseExportQuery_ItemFilter_EXCLUDE,
-		Keys: dataset.ExcludeColumns,
+	dataset, query, auditParams, ok := h.parseDatasetRequest(c, types.AUDIT_ACTION_DOWNLOAD_DATASET)
+	if !ok {
+		return
 	}
 
-	stream, err := h.clients.StudyService.GetResponsesWideFormatCSV(context.Background(), &req)
+	// cancelled if the client disconnects
+	ctx := c.Request.Context()
+	stream, err := datasets.Open(ctx, h.clients.StudyService, datasets.NewResponseExportQuery(dataset, query, token.ID), query.Format)
 	if err != nil {
 		st := status.Convert(err)
 		logger.Error.Printf("user %s tried to access dataset %s resulted in error %s", token.ID, datasetKey, st.Message())
+		h.writeAuditLog(c, types.AUDIT_ACTION_DOWNLOAD_DATASET, substudyKey, datasetKey, auditParams, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": st.Message()})
 		return
 	}
 
-	content := []byte{}
-	for {
-		chnk, err := stream.Recv()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			st := status.Convert(err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": st.Message()})
-			return
-		}
-		content = append(content, chnk.Chunk...)
+	// wait for the first chunk, so that errors of the study service can still be returned as error response
+	first, err := stream.Recv()
+	if err != nil && err != io.EOF {
+		st := status.Convert(err)
+		logger.Error.Printf("user %s tried to access dataset %s resulted in error %s", token.ID, datasetKey, st.Message())
+		h.writeAuditLog(c, types.AUDIT_ACTION_DOWNLOAD_DATASET, substudyKey, datasetKey, auditParams, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": st.Message()})
+		return
 	}
 
-	reader := bytes.NewReader(content)
-	contentLength := int64(len(content))
-	contentType := "text/csv"
-
-	extraHeaders := map[string]string{
-		"Content-Disposition": `attachment; filename=` + fmt.Sprintf("%s_%s.csv", substudyKey, dataset.SurveyKey),
+	c.Header("Content-Type", datasets.ContentType(query.Format))
+	c.Header("Content-Disposition", `attachment; filename=`+datasets.FileName(substudyKey, dataset, query.Format))
+	err = streamDatasetChunks(c, first, err, stream)
+	if err != nil {
+		logger.Error.Printf("download of dataset %s by user %s failed: %v", datasetKey, token.ID, err)
 	}
-
-	c.DataFromReader(http.StatusOK, contentLength, contentType, reader, extraHeaders)
+	h.writeAuditLog(c, types.AUDIT_ACTION_DOWNLOAD_DATASET, substudyKey, datasetKey, auditParams, err)
 }
 
 func (h *HttpEndpoints) fetchNotificationSubscriptions(c *gin.Context) {
@@ -1,25 +1,50 @@
 package v1
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/coneno/logger"
 	"github.com/gin-gonic/gin"
+	"github.com/influenzanet/study-service/pkg/studyengine"
+	"github.com/tekenradar/researcher-backend/pkg/db"
 	mw "github.com/tekenradar/researcher-backend/pkg/http/middlewares"
+	"github.com/tekenradar/researcher-backend/pkg/http/utils"
 	"github.com/tekenradar/researcher-backend/pkg/jwt"
+	"github.com/tekenradar/researcher-backend/pkg/studyevents"
 	"github.com/tekenradar/researcher-backend/pkg/types"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 func (h *HttpEndpoints) AddStudyManagementAPI(rg *gin.RouterGroup) {
 	studyManagementGroup := rg.Group("/substudy-management")
 
-	studyManagementGroup.Use(mw.HasValidAPIKey(h.apiKeys))
+	studyManagementGroup.Use(mw.HasValidAPIKey(h.researcherDB, types.API_KEY_SCOPE_RESEARCHER_APP))
 	studyManagementGroup.Use(mw.ValidateToken())
-	studyManagementGroup.Use(mw.IsAdmin())
+	studyManagementGroup.Use(mw.IsAdmin(h.researcherDB))
 	{
 		studyManagementGroup.GET("", h.SM_getAllSubstudyInfos) // fetch all substudy infos (even if not explicitly member of it, since admin role)
 		studyManagementGroup.POST("", h.SM_saveSubstudyInfo)   // save study info (create or overwrite)
 		studyManagementGroup.DELETE("/:substudyKey", h.SM_deleteSubstudyInfo)
+
+		studyManagementGroup.GET("/admin-users", h.SM_getAdminUsers)
+		studyManagementGroup.POST("/admin-users", h.SM_addAdminUser)
+		studyManagementGroup.DELETE("/admin-users/:email", h.SM_deleteAdminUser)
+
+		studyManagementGroup.GET("/audit-log", h.SM_getAuditLog) // ?actor=&action=&studyKey=&targetID=&from=&until=&limit=&cursor=&format=csv
+
+		studyManagementGroup.POST("/contact-data-mapping/dry-run", h.SM_contactDataMappingDryRun)
+
+		studyManagementGroup.GET("/study-events", h.SM_getStudyEvents) // ?status=failed&eventType=&limit=
+		studyManagementGroup.GET("/study-events/metrics", h.SM_getStudyEventMetrics)
+		studyManagementGroup.POST("/study-events/:eventID/replay", h.SM_replayStudyEvent)
+
+		studyManagementGroup.GET("/api-keys", h.SM_getAPIKeys)
+		studyManagementGroup.POST("/api-keys", h.SM_createAPIKey)
+		studyManagementGroup.POST("/api-keys/:keyID/rotate", h.SM_rotateAPIKey)
+		studyManagementGroup.DELETE("/api-keys/:keyID", h.SM_revokeAPIKey)
 	}
 }
 
@@ -47,7 +72,47 @@ func (h *HttpEndpoints) SM_saveSubstudyInfo(c *gin.Context) {
 		return
 	}
 
+	for _, m := range req.AccessControl.Members {
+		if !types.IsValidStudyRole(m.Role) {
+			msg := fmt.Sprintf("unknown role '%s' for member '%s'", m.Role, m.Email)
+			logger.Error.Println(msg)
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+	}
+
+	if err := utils.ValidateContactDataMapping(req.ContactFeatureConfig.ContactDataMapping); err != nil {
+		logger.Error.Printf("error: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := utils.ValidateContactStatusPipeline(req.ContactFeatureConfig.StatusPipeline); err != nil {
+		logger.Error.Printf("error: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := utils.ValidateContactRetentionPolicy(req.ContactFeatureConfig.RetentionPolicy); err != nil {
+		logger.Error.Printf("error: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := utils.ValidateInclusionRule(req.ContactFeatureConfig.InclusionRule); err != nil {
+		logger.Error.Printf("error: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := utils.ValidateDatasetInfos(req.AvailableDatasets); err != nil {
+		logger.Error.Printf("error: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	si, err := h.researcherDB.SaveStudyInfo(req)
+	h.writeAuditLog(c, types.AUDIT_ACTION_SAVE_STUDY_INFO, req.Key, "", nil, err)
 	if err != nil {
 		logger.Error.Printf("error: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -63,16 +128,15 @@ func (h *HttpEndpoints) SM_deleteSubstudyInfo(c *gin.Context) {
 	substudyKey := c.Param("substudyKey")
 
 	count, err := h.researcherDB.DeleteStudyInfo(substudyKey)
+	if err == nil && count < 1 {
+		err = errors.New("study could not be deleted")
+	}
+	h.writeAuditLog(c, types.AUDIT_ACTION_DELETE_STUDY_INFO, substudyKey, "", nil, err)
 	if err != nil {
 		logger.Error.Printf("error: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	if count < 1 {
-		logger.Error.Printf("study not deleted: %s", substudyKey)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "study could not be deleted"})
-		return
-	}
 
 	err = h.researcherDB.DeleteEmailAllNotificationsForStudy(substudyKey)
 	if err != nil {
@@ -82,3 +146,129 @@ func (h *HttpEndpoints) SM_deleteSubstudyInfo(c *gin.Context) {
 	logger.Info.Printf("study info for '%s' deleted by '%s'", substudyKey, token.ID)
 	c.JSON(http.StatusOK, gin.H{"message": "study deleted"})
 }
+
+func (h *HttpEndpoints) SM_getAdminUsers(c *gin.Context) {
+	token := c.MustGet("validatedToken").(*jwt.UserClaims)
+
+	adminUsers, err := h.researcherDB.FindAllAdminUsers()
+	if err != nil {
+		logger.Error.Printf("error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	logger.Info.Printf("admin users fetched by '%s'", token.ID)
+	c.JSON(http.StatusOK, gin.H{"adminUsers": adminUsers})
+}
+
+type AddAdminUserRequest struct {
+	Email string `json:"email" binding:"required"`
+}
+
+func (h *HttpEndpoints) SM_addAdminUser(c *gin.Context) {
+	token := c.MustGet("validatedToken").(*jwt.UserClaims)
+
+	var req AddAdminUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Error.Printf("error: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	_, err := h.researcherDB.AddAdminUser(types.AdminUser{
+		Email:   req.Email,
+		AddedAt: time.Now().Unix(),
+		AddedBy: token.ID,
+	})
+	h.writeAuditLog(c, types.AUDIT_ACTION_ADD_ADMIN_USER, "", req.Email, nil, err)
+	if err != nil {
+		logger.Error.Printf("error: %v", err)
+		if mongo.IsDuplicateKeyError(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "user is already an admin"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	logger.Info.Printf("admin user '%s' added by '%s'", req.Email, token.ID)
+	h.SM_getAdminUsers(c)
+}
+
+func (h *HttpEndpoints) SM_deleteAdminUser(c *gin.Context) {
+	token := c.MustGet("validatedToken").(*jwt.UserClaims)
+	email := c.Param("email")
+
+	count, err := h.researcherDB.DeleteAdminUser(email)
+	if err == nil && count < 1 {
+		h.writeAuditLog(c, types.AUDIT_ACTION_DELETE_ADMIN_USER, "", email, nil, errors.New("admin user not found"))
+	} else {
+		h.writeAuditLog(c, types.AUDIT_ACTION_DELETE_ADMIN_USER, "", email, nil, err)
+	}
+	if err != nil {
+		logger.Error.Printf("error: %v", err)
+		if err == db.ErrLastAdminUser {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if count < 1 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "admin user not found"})
+		return
+	}
+
+	logger.Info.Printf("admin user '%s' removed by '%s'", email, token.ID)
+	h.SM_getAdminUsers(c)
+}
+
+type ContactDataMappingDryRunRequest struct {
+	SubstudyKey string                           `json:"substudyKey"` // use the mapping of this study if no mapping is given, and check the inclusion
+	Mapping     []types.ContactDataMapping       `json:"mapping"`
+	Event       studyengine.ExternalEventPayload `json:"event" binding:"required"`
+}
+
+// SM_contactDataMappingDryRun shows which participant contact would be created from the event, without storing anything
+func (h *HttpEndpoints) SM_contactDataMappingDryRun(c *gin.Context) {
+	token := c.MustGet("validatedToken").(*jwt.UserClaims)
+
+	var req ContactDataMappingDryRunRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Error.Printf("error: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	mapping := req.Mapping
+	response := gin.H{}
+	if len(req.SubstudyKey) > 0 {
+		studyInfo, err := h.researcherDB.FindStudyInfo(req.SubstudyKey)
+		if err != nil {
+			logger.Error.Printf("error: %v", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if len(mapping) == 0 {
+			mapping = studyInfo.ContactFeatureConfig.ContactDataMapping
+		}
+		response["included"] = studyInfo.Features.Contacts && studyevents.ShouldIncludeParticipantContact(studyInfo, req.Event)
+	}
+	if len(mapping) == 0 {
+		mapping = utils.DefaultContactDataMapping
+	}
+
+	if err := utils.ValidateContactDataMapping(mapping); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	pc, warnings := studyevents.ExtractParticipantContactInfosFromEvent(req.Event, mapping)
+
+	response["participantContact"] = pc
+	response["warnings"] = warnings
+	response["mapping"] = mapping
+
+	logger.Info.Printf("contact data mapping dry-run by '%s'", token.ID)
+	c.JSON(http.StatusOK, response)
+}
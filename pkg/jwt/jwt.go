@@ -3,18 +3,13 @@ package jwt
 import (
 	"errors"
 	"fmt"
-	"os"
 	"time"
 
-	b64 "encoding/base64"
-
 	jwt "github.com/golang-jwt/jwt/v4"
-	"github.com/tekenradar/researcher-backend/internal/config"
 )
 
 var (
-	secretKey    []byte
-	secretKeyEnc string
+	keys *keySet
 )
 
 const (
@@ -28,25 +23,12 @@ type UserClaims struct {
 	jwt.StandardClaims
 }
 
-func getSecretKey() (newSecretKey []byte, err error) {
-	newSecretKeyEnc := os.Getenv(config.ENV_JWT_TOKEN_KEY)
-	if secretKeyEnc == newSecretKeyEnc {
-		return newSecretKey, nil
-	}
-	secretKeyEnc = newSecretKeyEnc
-	newSecretKey, err = b64.StdEncoding.DecodeString(newSecretKeyEnc)
-	if err != nil {
-		return newSecretKey, err
-	}
-	if len(newSecretKey) < 32 {
-		return newSecretKey, errors.New("couldn't find proper secret key")
-	}
-	secretKey = newSecretKey
-	return
-}
-
 // GenerateNewToken create and signes a new token
 func GenerateNewToken(userID string, experiresIn time.Duration, roles []string) (string, error) {
+	if keys == nil {
+		return "", errors.New("signing keys not initialized")
+	}
+
 	// Create the Claims
 	claims := UserClaims{
 		userID,
@@ -57,36 +39,61 @@ func GenerateNewToken(userID string, experiresIn time.Duration, roles []string)
 		},
 	}
 
-	// Create the token
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-
-	_, err := getSecretKey()
-	if err != nil {
-		return "", err
+	// Legacy mode: no asymmetric key configured
+	if len(keys.activeKID) < 1 {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		return token.SignedString(keys.hmacSecret)
 	}
 
-	// Sign and get the complete encoded token as a string using the secret
-	tokenString, err := token.SignedString(secretKey)
+	// Create the token
+	k := keys.keys[keys.activeKID]
+	token := jwt.NewWithClaims(k.method, claims)
+	token.Header["kid"] = k.kid
+
+	// Sign and get the complete encoded token as a string using the active key
+	tokenString, err := token.SignedString(k.privateKey)
 	return tokenString, err
 }
 
 // ValidateToken parses and validates the token string
 func ValidateToken(tokenString string) (claims *UserClaims, valid bool, err error) {
-	_, err = getSecretKey()
-	if err != nil {
-		return nil, false, err
+	if keys == nil {
+		return nil, false, errors.New("signing keys not initialized")
 	}
 
+	isActiveKey := false
+	signingKID := ""
 	token, err := jwt.ParseWithClaims(tokenString, &UserClaims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		kid, _ := token.Header["kid"].(string)
+		if len(kid) < 1 {
+			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok || keys.hmacSecret == nil {
+				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+			}
+			isActiveKey = len(keys.activeKID) < 1
+			signingKID = LegacyHMACKeyID
+			return keys.hmacSecret, nil
+		}
+
+		k, ok := keys.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown key id: %s", kid)
+		}
+		if token.Method.Alg() != k.method.Alg() {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
-		return secretKey, nil
+		isActiveKey = kid == keys.activeKID
+		signingKID = kid
+		return k.publicKey, nil
 	})
 	if token == nil {
 		return
 	}
 	claims, valid = token.Claims.(*UserClaims)
 	valid = valid && token.Valid
+
+	// tokens signed with a previous key are only accepted during the grace period after its retirement
+	if valid && !isActiveKey && !keys.acceptsRetiredKey(signingKID, time.Now()) {
+		return claims, false, errors.New("token signed with a retired key")
+	}
 	return
 }
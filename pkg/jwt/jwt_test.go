@@ -0,0 +1,163 @@
+package jwt
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tekenradar/researcher-backend/pkg/types"
+)
+
+func writeTestSigningKey(t *testing.T, dir string, kid string) string {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, kid+".pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func mustInitKeys(t *testing.T, conf types.JWTConfig) {
+	t.Helper()
+	if err := InitKeys(conf); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func mustGenerateToken(t *testing.T) string {
+	t.Helper()
+	token, err := GenerateNewToken("user@example.org", time.Hour, []string{ROLE_ADMIN})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestValidateTokenKeyRotation(t *testing.T) {
+	dir := t.TempDir()
+	keyFiles := map[string]string{
+		"key-1": writeTestSigningKey(t, dir, "key-1"),
+		"key-2": writeTestSigningKey(t, dir, "key-2"),
+	}
+	hmacSecret := base64.StdEncoding.EncodeToString(make([]byte, 32))
+
+	// tokens issued before the rotation
+	mustInitKeys(t, types.JWTConfig{SigningKeyFiles: keyFiles, ActiveKeyID: "key-1"})
+	oldKeyToken := mustGenerateToken(t)
+	mustInitKeys(t, types.JWTConfig{HMACSecret: hmacSecret})
+	hmacToken := mustGenerateToken(t)
+
+	now := time.Now().Unix()
+	gracePeriod := 3600
+
+	tests := []struct {
+		name      string
+		token     string
+		retiredAt map[string]int64
+		wantValid bool
+	}{
+		{
+			name:      "retired key within grace period",
+			token:     oldKeyToken,
+			retiredAt: map[string]int64{"key-1": now - 60},
+			wantValid: true,
+		},
+		{
+			name:      "retired key after grace period",
+			token:     oldKeyToken,
+			retiredAt: map[string]int64{"key-1": now - int64(gracePeriod) - 60},
+			wantValid: false,
+		},
+		{
+			name:      "non-active key without retirement time",
+			token:     oldKeyToken,
+			retiredAt: map[string]int64{},
+			wantValid: false,
+		},
+		{
+			name:      "legacy key within grace period",
+			token:     hmacToken,
+			retiredAt: map[string]int64{LegacyHMACKeyID: now - 60},
+			wantValid: true,
+		},
+		{
+			name:      "legacy key after grace period",
+			token:     hmacToken,
+			retiredAt: map[string]int64{LegacyHMACKeyID: now - int64(gracePeriod) - 60},
+			wantValid: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mustInitKeys(t, types.JWTConfig{
+				SigningKeyFiles: keyFiles,
+				ActiveKeyID:     "key-2",
+				KeysRetiredAt:   tt.retiredAt,
+				GracePeriod:     gracePeriod,
+				HMACSecret:      hmacSecret,
+			})
+			claims, valid, err := ValidateToken(tt.token)
+			if valid != tt.wantValid {
+				t.Fatalf("valid = %v, want %v (error: %v)", valid, tt.wantValid, err)
+			}
+			if valid && claims.ID != "user@example.org" {
+				t.Errorf("unexpected user: %s", claims.ID)
+			}
+		})
+	}
+
+	t.Run("active key", func(t *testing.T) {
+		mustInitKeys(t, types.JWTConfig{SigningKeyFiles: keyFiles, ActiveKeyID: "key-2", GracePeriod: gracePeriod})
+		if _, valid, err := ValidateToken(mustGenerateToken(t)); !valid {
+			t.Errorf("token of the active key rejected: %v", err)
+		}
+	})
+
+	t.Run("unknown key", func(t *testing.T) {
+		mustInitKeys(t, types.JWTConfig{
+			SigningKeyFiles: map[string]string{"key-2": keyFiles["key-2"]},
+			ActiveKeyID:     "key-2",
+			KeysRetiredAt:   map[string]int64{"key-1": now},
+			GracePeriod:     gracePeriod,
+		})
+		if _, valid, _ := ValidateToken(oldKeyToken); valid {
+			t.Error("token of a removed key accepted")
+		}
+	})
+}
+
+func TestGetJWKS(t *testing.T) {
+	dir := t.TempDir()
+	mustInitKeys(t, types.JWTConfig{
+		SigningKeyFiles: map[string]string{
+			"b": writeTestSigningKey(t, dir, "b"),
+			"a": writeTestSigningKey(t, dir, "a"),
+		},
+		ActiveKeyID: "a",
+	})
+
+	jwks := GetJWKS()
+	if len(jwks.Keys) != 2 || jwks.Keys[0].Kid != "a" || jwks.Keys[1].Kid != "b" {
+		t.Fatalf("unexpected keys: %+v", jwks.Keys)
+	}
+	for _, k := range jwks.Keys {
+		if k.Kty != "OKP" || k.Alg != "EdDSA" || len(k.X) < 1 {
+			t.Errorf("unexpected key: %+v", k)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/coneno/logger"
+	jwt "github.com/golang-jwt/jwt/v4"
+	"github.com/tekenradar/researcher-backend/pkg/types"
+)
+
+// signingKey is one asymmetric key pair, identified by its kid
+type signingKey struct {
+	kid        string
+	method     jwt.SigningMethod
+	privateKey crypto.PrivateKey
+	publicKey  crypto.PublicKey
+}
+
+// LegacyHMACKeyID refers to the legacy HS256 key in the retirement times, as its tokens have no kid
+const LegacyHMACKeyID = "hmac"
+
+// keySet holds all configured keys. Tokens are signed with the active key only, tokens signed
+// with any other key are accepted until gracePeriod after the key was retired.
+type keySet struct {
+	keys        map[string]*signingKey
+	activeKID   string
+	retiredAt   map[string]time.Time // by kid, or LegacyHMACKeyID
+	gracePeriod time.Duration
+	hmacSecret  []byte // legacy HS256 key, tokens signed with it have no kid
+}
+
+// JWK is the public part of a signing key as published in the JWKS
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// InitKeys loads the signing keys, has to be called before tokens are generated or validated
+func InitKeys(conf types.JWTConfig) error {
+	ks := &keySet{
+		keys:        map[string]*signingKey{},
+		activeKID:   conf.ActiveKeyID,
+		retiredAt:   map[string]time.Time{},
+		gracePeriod: time.Duration(conf.GracePeriod) * time.Second,
+	}
+	for kid, retiredAt := range conf.KeysRetiredAt {
+		ks.retiredAt[kid] = time.Unix(retiredAt, 0)
+	}
+
+	for kid, path := range conf.SigningKeyFiles {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("cannot read signing key %s: %v", kid, err)
+		}
+		k, err := parseSigningKey(kid, content)
+		if err != nil {
+			return err
+		}
+		ks.keys[kid] = k
+	}
+
+	if len(conf.HMACSecret) > 0 {
+		secret, err := base64.StdEncoding.DecodeString(conf.HMACSecret)
+		if err != nil {
+			return err
+		}
+		if len(secret) < 32 {
+			return errors.New("couldn't find proper secret key")
+		}
+		ks.hmacSecret = secret
+	}
+
+	if len(ks.activeKID) > 0 {
+		if _, ok := ks.keys[ks.activeKID]; !ok {
+			return fmt.Errorf("active signing key %s is not configured", ks.activeKID)
+		}
+	} else if len(ks.keys) > 0 {
+		return errors.New("signing keys configured, but no active key selected")
+	} else if ks.hmacSecret == nil {
+		return errors.New("no key configured to sign tokens")
+	}
+
+	for _, kid := range ks.retiredKeyIDs() {
+		if _, ok := ks.retiredAt[kid]; !ok {
+			logger.Warning.Printf("no retirement time configured for signing key '%s', tokens signed with it are rejected", kid)
+		}
+	}
+
+	keys = ks
+	return nil
+}
+
+// retiredKeyIDs returns the configured keys which are not active
+func (ks *keySet) retiredKeyIDs() []string {
+	kids := []string{}
+	for kid := range ks.keys {
+		if kid != ks.activeKID {
+			kids = append(kids, kid)
+		}
+	}
+	if ks.hmacSecret != nil && len(ks.activeKID) > 0 {
+		kids = append(kids, LegacyHMACKeyID)
+	}
+	sort.Strings(kids)
+	return kids
+}
+
+// acceptsRetiredKey checks if tokens signed with the retired key are still within the grace period
+func (ks *keySet) acceptsRetiredKey(kid string, now time.Time) bool {
+	retiredAt, ok := ks.retiredAt[kid]
+	if !ok {
+		return false
+	}
+	return !now.After(retiredAt.Add(ks.gracePeriod))
+}
+
+func parseSigningKey(kid string, pemContent []byte) (*signingKey, error) {
+	if rsaKey, err := jwt.ParseRSAPrivateKeyFromPEM(pemContent); err == nil {
+		return &signingKey{
+			kid:        kid,
+			method:     jwt.SigningMethodRS256,
+			privateKey: rsaKey,
+			publicKey:  &rsaKey.PublicKey,
+		}, nil
+	}
+	if edKey, err := jwt.ParseEdPrivateKeyFromPEM(pemContent); err == nil {
+		privateKey, ok := edKey.(ed25519.PrivateKey)
+		if ok {
+			return &signingKey{
+				kid:        kid,
+				method:     jwt.SigningMethodEdDSA,
+				privateKey: privateKey,
+				publicKey:  privateKey.Public(),
+			}, nil
+		}
+	}
+	return nil, fmt.Errorf("signing key %s is neither an RSA nor an Ed25519 private key", kid)
+}
+
+// GetJWKS returns the public keys of all asymmetric signing keys
+func GetJWKS() JWKS {
+	jwks := JWKS{Keys: []JWK{}}
+	if keys == nil {
+		return jwks
+	}
+
+	for _, k := range keys.keys {
+		switch pk := k.publicKey.(type) {
+		case *rsa.PublicKey:
+			jwks.Keys = append(jwks.Keys, JWK{
+				Kty: "RSA",
+				Kid: k.kid,
+				Use: "sig",
+				Alg: k.method.Alg(),
+				N:   base64.RawURLEncoding.EncodeToString(pk.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pk.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			jwks.Keys = append(jwks.Keys, JWK{
+				Kty: "OKP",
+				Kid: k.kid,
+				Use: "sig",
+				Alg: k.method.Alg(),
+				Crv: "Ed25519",
+				X:   base64.RawURLEncoding.EncodeToString(pk),
+			})
+		}
+	}
+	sort.Slice(jwks.Keys, func(i, j int) bool { return jwks.Keys[i].Kid < jwks.Keys[j].Kid })
+	return jwks
+}
@@ -0,0 +1,128 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/coneno/logger"
+	"github.com/influenzanet/messaging-service/pkg/api/email_client_service"
+	"github.com/tekenradar/researcher-backend/pkg/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	sendEmailTimeout = 30 * time.Second
+)
+
+// SendContactExpiryWarnings emails a digest of the contacts whose contact data will be removed within the
+// configured number of days to the subscribers of the contact-expiry topic. Each contact is listed only once
+// (again after it was updated).
+func (s *Runner) SendContactExpiryWarnings() {
+	if s.contactExpiryWarning.WarningDays <= 0 {
+		return
+	}
+
+	studyInfos, err := s.researcherDB.FindAllStudyInfos()
+	if err != nil {
+		logger.Error.Println(err)
+		return
+	}
+	for _, info := range studyInfos {
+		if !info.Features.Contacts {
+			continue
+		}
+		if err := s.sendContactExpiryWarningsForStudy(info); err != nil {
+			logger.Error.Printf("contact expiry warnings for %s: %v", info.Key, err)
+		}
+	}
+}
+
+func (s *Runner) sendContactExpiryWarningsForStudy(info types.StudyInfo) error {
+	subs, err := s.researcherDB.FindNotificationSubscriptions(info.Key, types.NOTIFICATION_TOPIC_CONTACT_EXPIRY)
+	if err != nil {
+		return err
+	}
+	if len(subs) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	warnUntil := now.AddDate(0, 0, s.contactExpiryWarning.WarningDays).Unix()
+	// no contact can expire earlier than the shortest retention period after it was last changed
+	ref := now.AddDate(0, 0, s.contactExpiryWarning.WarningDays-info.GetContactRetentionPolicy().RemoveContactDataAfterDays).Unix()
+	candidates, err := s.researcherDB.FindParticipantContactsWithContactDataChangedBefore(info.Key, ref)
+	if err != nil {
+		return err
+	}
+
+	expiring := []types.ParticipantContact{}
+	for _, pc := range candidates {
+		if pc.ExpiryWarningSentAt >= pc.LastChangedAt() {
+			continue
+		}
+		pc.ExpiresAt = info.ContactDataExpiresAt(pc)
+		if pc.ExpiresAt <= now.Unix() || pc.ExpiresAt > warnUntil {
+			continue
+		}
+		expiring = append(expiring, pc)
+	}
+	if len(expiring) == 0 {
+		return nil
+	}
+
+	content := s.contactExpiryDigest(info, expiring)
+	sent := false
+	for _, sub := range subs {
+		ctx, cancel := context.WithTimeout(context.Background(), sendEmailTimeout)
+		_, err := s.clients.EmailClientService.SendEmail(ctx, &email_client_service.SendEmailReq{
+			To:      []string{sub.Email},
+			Subject: fmt.Sprintf("Tekenradar - %d contact entries in study %s will be removed soon", len(expiring), info.Name),
+			Content: content,
+		})
+		cancel()
+		if err != nil {
+			logger.Error.Printf("failed to send contact expiry warning to %s: %v", sub.Email, err)
+			continue
+		}
+		sent = true
+	}
+	if !sent {
+		return fmt.Errorf("digest could not be sent to any subscriber")
+	}
+
+	ids := make([]primitive.ObjectID, len(expiring))
+	for i, pc := range expiring {
+		ids[i] = pc.ID
+	}
+	logger.Info.Printf("contact expiry warning for %d contacts of %s sent", len(expiring), info.Key)
+	return s.researcherDB.MarkExpiryWarningSent(info.Key, ids)
+}
+
+func (s *Runner) contactExpiryDigest(info types.StudyInfo, expiring []types.ParticipantContact) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "The contact data of the following participant contacts of the %s (%s) study will automatically be removed from the system within the next %d days. To keep the data, mark the entries to be kept in the tekenradar researcher app.\n\n",
+		info.Name, info.Key, s.contactExpiryWarning.WarningDays)
+	for _, pc := range expiring {
+		fmt.Fprintf(&sb, "- added %s, removed on %s",
+			time.Unix(pc.AddedAt, 0).UTC().Format("2006-01-02"),
+			time.Unix(pc.ExpiresAt, 0).UTC().Format("2006-01-02"),
+		)
+		if link := s.contactURL(info.Key, pc.ID.Hex()); len(link) > 0 {
+			sb.WriteString(": " + link)
+		} else {
+			sb.WriteString(" (id " + pc.ID.Hex() + ")")
+		}
+		sb.WriteString("\n")
+	}
+	sb.WriteString("\n You are receiving this message because your email address is registered in the tekenradar researcher app for this study. Contact: tekenradar@rivm.nl")
+	return sb.String()
+}
+
+func (s *Runner) contactURL(studyKey string, contactID string) string {
+	if len(s.contactExpiryWarning.ContactURLTemplate) == 0 {
+		return ""
+	}
+	return strings.NewReplacer("{{studyKey}}", studyKey, "{{contactID}}", contactID).Replace(s.contactExpiryWarning.ContactURLTemplate)
+}
@@ -0,0 +1,170 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/coneno/logger"
+	"github.com/influenzanet/messaging-service/pkg/api/email_client_service"
+	"github.com/tekenradar/researcher-backend/pkg/datasets"
+	"github.com/tekenradar/researcher-backend/pkg/types"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	datasetExportPollInterval    = 10 * time.Second
+	datasetExportTimeout         = 2 * time.Hour
+	datasetExportLockDuration    = datasetExportTimeout + 10*time.Minute
+	datasetExportMaxAttempts     = 3 // a job is only retried if the service stopped while it was running
+	datasetExportCleanupInterval = time.Hour
+)
+
+func (s *Runner) startDatasetExportWorker() {
+	lastCleanup := time.Time{}
+	for {
+		<-time.After(datasetExportPollInterval)
+		s.ProcessPendingDatasetExports()
+		if time.Since(lastCleanup) > datasetExportCleanupInterval {
+			s.CleanUpExpiredDatasetExports()
+			lastCleanup = time.Now()
+		}
+	}
+}
+
+// ProcessPendingDatasetExports runs the waiting export jobs one after the other
+func (s *Runner) ProcessPendingDatasetExports() {
+	for {
+		job, err := s.researcherDB.ClaimNextDatasetExportJob(datasetExportLockDuration)
+		if err != nil {
+			if err != mongo.ErrNoDocuments {
+				logger.Error.Printf("failed to fetch dataset export job: %v", err)
+			}
+			return
+		}
+		s.processDatasetExportJob(job)
+	}
+}
+
+func (s *Runner) processDatasetExportJob(job types.DatasetExportJob) {
+	expiresAt := time.Now().Add(time.Duration(s.datasetExport.RetentionHours) * time.Hour).Unix()
+
+	studyInfo, dataset, err := s.runDatasetExportJob(&job)
+	if err != nil {
+		logger.Error.Printf("dataset export job %s for %s/%s failed: %v", job.ID.Hex(), job.StudyKey, job.DatasetKey, err)
+		if err := s.datasetStorage.Delete(job.ID.Hex()); err != nil {
+			logger.Error.Printf("failed to remove partial result of dataset export job %s: %v", job.ID.Hex(), err)
+		}
+		if err := s.researcherDB.MarkDatasetExportJobFailed(job.ID, err.Error(), expiresAt); err != nil {
+			logger.Error.Printf("failed to update dataset export job %s: %v", job.ID.Hex(), err)
+		}
+		return
+	}
+
+	job.ExpiresAt = expiresAt
+	if err := s.researcherDB.MarkDatasetExportJobDone(job); err != nil {
+		logger.Error.Printf("failed to update dataset export job %s: %v", job.ID.Hex(), err)
+		return
+	}
+	logger.Info.Printf("dataset export job %s for %s/%s done (%d bytes)", job.ID.Hex(), job.StudyKey, job.DatasetKey, job.FileSize)
+
+	if err := s.notifyDatasetExportReady(studyInfo, dataset, job); err != nil {
+		logger.Error.Printf("dataset export job %s: %v", job.ID.Hex(), err)
+	}
+}
+
+// runDatasetExportJob exports the dataset into the storage, and sets the file infos of the job
+func (s *Runner) runDatasetExportJob(job *types.DatasetExportJob) (types.StudyInfo, types.DatasetInfo, error) {
+	if job.Attempts > datasetExportMaxAttempts {
+		return types.StudyInfo{}, types.DatasetInfo{}, errors.New("export was interrupted too many times")
+	}
+	studyInfo, err := s.researcherDB.FindStudyInfo(job.StudyKey)
+	if err != nil {
+		return studyInfo, types.DatasetInfo{}, err
+	}
+	dataset, ok := studyInfo.FindDataset(job.DatasetKey)
+	if !ok {
+		return studyInfo, dataset, fmt.Errorf("dataset %s does not exist anymore", job.DatasetKey)
+	}
+	// the dataset may have been changed since the job was created
+	if err := datasets.CheckAllowedRange(dataset, job.Query); err != nil {
+		return studyInfo, dataset, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), datasetExportTimeout)
+	defer cancel()
+
+	stream, err := datasets.Open(ctx, s.clients.StudyService, datasets.NewResponseExportQuery(dataset, job.Query, job.RequestedBy), job.Query.Format)
+	if err != nil {
+		return studyInfo, dataset, err
+	}
+	job.FileName = datasets.FileName(job.StudyKey, dataset, job.Query.Format)
+	job.ContentType = datasets.ContentType(job.Query.Format)
+	job.FileSize, err = s.datasetStorage.Save(job.ID.Hex(), job.FileName, datasets.NewChunkReader(stream))
+	return studyInfo, dataset, err
+}
+
+func (s *Runner) notifyDatasetExportReady(studyInfo types.StudyInfo, dataset types.DatasetInfo, job types.DatasetExportJob) error {
+	subs, err := s.researcherDB.FindNotificationSubscriptions(studyInfo.Key, types.NOTIFICATION_TOPIC_DATASET_EXPORT)
+	if err != nil {
+		return err
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "The export of the dataset %s of the %s (%s) study, requested by %s, is ready. It can be downloaded in the tekenradar researcher app until %s.\n",
+		dataset.Name, studyInfo.Name, studyInfo.Key, job.RequestedBy,
+		time.Unix(job.ExpiresAt, 0).UTC().Format("2006-01-02 15:04 MST"),
+	)
+	if link := s.datasetExportJobURL(job); len(link) > 0 {
+		sb.WriteString("\n" + link + "\n")
+	}
+	sb.WriteString("\n You are receiving this message because your email address is registered in the tekenradar researcher app for this study. Contact: tekenradar@rivm.nl")
+
+	for _, sub := range subs {
+		ctx, cancel := context.WithTimeout(context.Background(), sendEmailTimeout)
+		_, err := s.clients.EmailClientService.SendEmail(ctx, &email_client_service.SendEmailReq{
+			To:      []string{sub.Email},
+			Subject: fmt.Sprintf("Tekenradar - export of dataset %s is ready", dataset.Name),
+			Content: sb.String(),
+		})
+		cancel()
+		if err != nil {
+			logger.Error.Printf("failed to send dataset export notification to %s: %v", sub.Email, err)
+		}
+	}
+	return nil
+}
+
+func (s *Runner) datasetExportJobURL(job types.DatasetExportJob) string {
+	if len(s.datasetExport.JobURLTemplate) == 0 {
+		return ""
+	}
+	return strings.NewReplacer(
+		"{{studyKey}}", job.StudyKey,
+		"{{datasetKey}}", job.DatasetKey,
+		"{{jobID}}", job.ID.Hex(),
+	).Replace(s.datasetExport.JobURLTemplate)
+}
+
+// CleanUpExpiredDatasetExports deletes finished jobs and their results after the retention period
+func (s *Runner) CleanUpExpiredDatasetExports() {
+	jobs, err := s.researcherDB.FindExpiredDatasetExportJobs(time.Now().Unix())
+	if err != nil {
+		logger.Error.Printf("failed to fetch expired dataset export jobs: %v", err)
+		return
+	}
+	for _, job := range jobs {
+		if err := s.datasetStorage.Delete(job.ID.Hex()); err != nil {
+			logger.Error.Printf("failed to delete result of dataset export job %s: %v", job.ID.Hex(), err)
+			continue
+		}
+		if err := s.researcherDB.DeleteDatasetExportJob(job.ID); err != nil {
+			logger.Error.Printf("failed to delete dataset export job %s: %v", job.ID.Hex(), err)
+		}
+	}
+	if len(jobs) > 0 {
+		logger.Info.Printf("%d expired dataset export jobs removed", len(jobs))
+	}
+}
@@ -4,27 +4,46 @@ import (
 	"time"
 
 	"github.com/coneno/logger"
+	"github.com/tekenradar/researcher-backend/pkg/datasets"
 	"github.com/tekenradar/researcher-backend/pkg/db"
-)
-
-const (
-	deleteAfterInDays = 7 * 12
+	"github.com/tekenradar/researcher-backend/pkg/grpc/clients"
+	"github.com/tekenradar/researcher-backend/pkg/studyevents"
+	"github.com/tekenradar/researcher-backend/pkg/types"
 )
 
 type Runner struct {
-	researcherDB       *db.ResearcherDBService
-	timerEventCooldown int64 // how often the timer event should be performed
+	researcherDB         *db.ResearcherDBService
+	clients              *clients.APIClients
+	eventProcessor       *studyevents.Processor
+	timerEventCooldown   int64 // how often the timer event should be performed
+	contactExpiryWarning types.ContactExpiryWarningConfig
+	datasetExport        types.DatasetExportConfig
+	datasetStorage       datasets.Storage
 }
 
-func NewRunner(researcherDB *db.ResearcherDBService, timerEventCooldown int64) *Runner {
+func NewRunner(
+	researcherDB *db.ResearcherDBService,
+	clients *clients.APIClients,
+	timerEventCooldown int64,
+	contactExpiryWarning types.ContactExpiryWarningConfig,
+	datasetExport types.DatasetExportConfig,
+	datasetStorage datasets.Storage,
+) *Runner {
 	return &Runner{
-		researcherDB:       researcherDB,
-		timerEventCooldown: timerEventCooldown,
+		researcherDB:         researcherDB,
+		clients:              clients,
+		eventProcessor:       studyevents.NewProcessor(researcherDB, clients),
+		timerEventCooldown:   timerEventCooldown,
+		contactExpiryWarning: contactExpiryWarning,
+		datasetExport:        datasetExport,
+		datasetStorage:       datasetStorage,
 	}
 }
 
 func (s *Runner) Run() {
 	go s.startTimerThread()
+	go s.startStudyEventWorker()
+	go s.startDatasetExportWorker()
 }
 
 func (s *Runner) startTimerThread() {
@@ -32,7 +51,10 @@ func (s *Runner) startTimerThread() {
 	for {
 		delay := s.timerEventCooldown
 		<-time.After(time.Duration(delay) * time.Second)
-		go s.CleanUpExpiredParticipantContacts()
+		go func() {
+			s.SendContactExpiryWarnings()
+			s.CleanUpExpiredParticipantContacts()
+		}()
 	}
 }
 
@@ -44,7 +66,11 @@ func (s Runner) CleanUpExpiredParticipantContacts() {
 	}
 	for _, info := range studyInfos {
 		logger.Info.Printf("running cleanup of expired participant contacts for %s", info.Key)
-		err = s.researcherDB.CleanUpExpiredParticipantContacts(info.Key, deleteAfterInDays)
+		err = s.researcherDB.CleanUpExpiredParticipantContacts(info.Key, info.GetContactRetentionPolicy(), info.GetContactStatusesWithRetention(types.CONTACT_STATUS_RETENTION_KEEP))
+		if err != nil {
+			logger.Error.Println(err)
+		}
+		err = s.researcherDB.RemoveContactDataForStatuses(info.Key, info.GetContactStatusesWithRetention(types.CONTACT_STATUS_RETENTION_REMOVE))
 		if err != nil {
 			logger.Error.Println(err)
 		}
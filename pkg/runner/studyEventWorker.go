@@ -0,0 +1,74 @@
+package runner
+
+import (
+	"time"
+
+	"github.com/coneno/logger"
+	"github.com/tekenradar/researcher-backend/pkg/studyevents"
+	"github.com/tekenradar/researcher-backend/pkg/types"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	studyEventPollInterval  = 5 * time.Second
+	studyEventLockDuration  = 10 * time.Minute
+	studyEventRetryBase     = 30 * time.Second // delay after the first failed attempt, doubled for each further one
+	studyEventRetryMaxDelay = 6 * time.Hour
+	studyEventMaxAttempts   = 10 // afterwards the event is moved to the dead-letter state
+	studyEventKeepDoneFor   = 30 * 24 * time.Hour
+	studyEventKeepFailedFor = 30 * 24 * time.Hour // unless replayed
+)
+
+func (s *Runner) startStudyEventWorker() {
+	for {
+		<-time.After(studyEventPollInterval)
+		s.ProcessPendingStudyEvents()
+	}
+}
+
+// ProcessPendingStudyEvents works through the inbox until no event is due anymore
+func (s *Runner) ProcessPendingStudyEvents() {
+	for {
+		entry, err := s.researcherDB.ClaimNextStudyEvent(studyEventLockDuration)
+		if err != nil {
+			if err != mongo.ErrNoDocuments {
+				logger.Error.Printf("failed to fetch study event from inbox: %v", err)
+			}
+			return
+		}
+		s.processStudyEvent(entry)
+	}
+}
+
+func (s *Runner) processStudyEvent(entry types.StudyEventInboxEntry) {
+	err := s.eventProcessor.ProcessEvent(entry)
+	if err == nil {
+		if err := s.researcherDB.MarkStudyEventDone(entry.ID, studyEventKeepDoneFor); err != nil {
+			logger.Error.Printf("failed to mark study event %s as done: %v", entry.ID.Hex(), err)
+		}
+		return
+	}
+
+	var nextAttemptAt int64
+	if entry.Attempts < studyEventMaxAttempts {
+		nextAttemptAt = time.Now().Add(studyEventRetryDelay(entry.Attempts)).Unix()
+		logger.Warning.Printf("study event %s (%s) failed in attempt %d, retrying: %v", entry.ID.Hex(), entry.EventType, entry.Attempts, err)
+	} else {
+		logger.Error.Printf("study event %s (%s) failed in attempt %d, giving up: %v", entry.ID.Hex(), entry.EventType, entry.Attempts, err)
+		studyevents.RecordDeadLettered(entry.EventType)
+	}
+	if err := s.researcherDB.MarkStudyEventFailed(entry.ID, err.Error(), nextAttemptAt, studyEventKeepFailedFor); err != nil {
+		logger.Error.Printf("failed to update study event %s: %v", entry.ID.Hex(), err)
+	}
+}
+
+func studyEventRetryDelay(attempts int) time.Duration {
+	delay := studyEventRetryBase
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= studyEventRetryMaxDelay {
+			return studyEventRetryMaxDelay
+		}
+	}
+	return delay
+}
@@ -0,0 +1,27 @@
+package runner
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStudyEventRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{0, 30 * time.Second},
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{9, 128 * time.Minute},
+		{10, 256 * time.Minute},
+		{11, 6 * time.Hour},
+		{100, 6 * time.Hour},
+	}
+	for _, tt := range tests {
+		if got := studyEventRetryDelay(tt.attempts); got != tt.want {
+			t.Errorf("studyEventRetryDelay(%d) = %s, want %s", tt.attempts, got, tt.want)
+		}
+	}
+}
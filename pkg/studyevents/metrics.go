@@ -0,0 +1,101 @@
+package studyevents
+
+import (
+	"sync"
+	"time"
+)
+
+// HandlerMetrics counts the events of one event key since the service was started
+type HandlerMetrics struct {
+	EventKey          string  `json:"eventKey"`
+	Received          int64   `json:"received"`
+	Succeeded         int64   `json:"succeeded"`
+	FailedAttempts    int64   `json:"failedAttempts"`
+	DeadLettered      int64   `json:"deadLettered"`
+	LastReceivedAt    int64   `json:"lastReceivedAt"`
+	LastSucceededAt   int64   `json:"lastSucceededAt"`
+	LastFailedAt      int64   `json:"lastFailedAt"`
+	AvgProcessingTime float64 `json:"avgProcessingTimeMs"`
+
+	totalProcessingTime time.Duration
+}
+
+type Metrics struct {
+	Handlers         []HandlerMetrics `json:"handlers"`
+	RejectedUnknown  int64            `json:"rejectedUnknownEventKeys"`
+	LastRejectedKeys []string         `json:"lastRejectedEventKeys"`
+}
+
+const maxRejectedKeysKept = 20
+
+var metrics = struct {
+	sync.Mutex
+	handlers        map[string]*HandlerMetrics
+	rejectedUnknown int64
+	rejectedKeys    []string
+}{
+	handlers: map[string]*HandlerMetrics{},
+}
+
+func RecordReceived(eventKey string) {
+	metrics.Lock()
+	defer metrics.Unlock()
+	if m, ok := metrics.handlers[eventKey]; ok {
+		m.Received++
+		m.LastReceivedAt = time.Now().Unix()
+	}
+}
+
+// RecordRejected counts a call with an event key that has no handler
+func RecordRejected(eventKey string) {
+	metrics.Lock()
+	defer metrics.Unlock()
+	metrics.rejectedUnknown++
+	metrics.rejectedKeys = append(metrics.rejectedKeys, eventKey)
+	if len(metrics.rejectedKeys) > maxRejectedKeysKept {
+		metrics.rejectedKeys = metrics.rejectedKeys[len(metrics.rejectedKeys)-maxRejectedKeysKept:]
+	}
+}
+
+func RecordDeadLettered(eventKey string) {
+	metrics.Lock()
+	defer metrics.Unlock()
+	if m, ok := metrics.handlers[eventKey]; ok {
+		m.DeadLettered++
+	}
+}
+
+func recordAttempt(eventKey string, duration time.Duration, err error) {
+	metrics.Lock()
+	defer metrics.Unlock()
+	m, ok := metrics.handlers[eventKey]
+	if !ok {
+		return
+	}
+	if err != nil {
+		m.FailedAttempts++
+		m.LastFailedAt = time.Now().Unix()
+	} else {
+		m.Succeeded++
+		m.LastSucceededAt = time.Now().Unix()
+	}
+	m.totalProcessingTime += duration
+	m.AvgProcessingTime = float64(m.totalProcessingTime.Milliseconds()) / float64(m.Succeeded+m.FailedAttempts)
+}
+
+func GetMetrics() Metrics {
+	metrics.Lock()
+	defer metrics.Unlock()
+
+	res := Metrics{
+		Handlers:         []HandlerMetrics{},
+		RejectedUnknown:  metrics.rejectedUnknown,
+		LastRejectedKeys: append([]string{}, metrics.rejectedKeys...),
+	}
+	for _, k := range EventKeys() {
+		if m, ok := metrics.handlers[k]; ok {
+			res.Handlers = append(res.Handlers, *m)
+		}
+	}
+	return res
+}
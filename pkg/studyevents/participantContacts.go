@@ -0,0 +1,100 @@
+package studyevents
+
+import (
+	"time"
+
+	"github.com/influenzanet/study-service/pkg/studyengine"
+	"github.com/tekenradar/researcher-backend/pkg/http/utils"
+	"github.com/tekenradar/researcher-backend/pkg/types"
+)
+
+// ShouldIncludeParticipantContact evaluates the study's inclusion rule, or if there is none, requires all IncludeWithParticipantFlags to match
+func ShouldIncludeParticipantContact(studyInfo types.StudyInfo, event studyengine.ExternalEventPayload) bool {
+	if studyInfo.ContactFeatureConfig.InclusionRule != nil {
+		return utils.EvaluateInclusionRule(*studyInfo.ContactFeatureConfig.InclusionRule, event)
+	}
+	if len(studyInfo.ContactFeatureConfig.IncludeWithParticipantFlags) == 0 {
+		return false
+	}
+	for k, v := range studyInfo.ContactFeatureConfig.IncludeWithParticipantFlags {
+		fv, ok := event.ParticipantState.Flags[k]
+		if ok && fv == v {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// ExtractParticipantContactInfosFromEvent creates the participant contact using the study's contact data mapping (or the default one)
+func ExtractParticipantContactInfosFromEvent(event studyengine.ExternalEventPayload, mappings []types.ContactDataMapping) (pc types.ParticipantContact, warnings []string) {
+	pc = types.ParticipantContact{
+		AddedAt:         time.Now().Unix(),
+		General:         &types.ContactDetailsGeneralData{},
+		ContactData:     &types.ContactDetailsContactData{},
+		ParticipantID:   event.ParticipantState.ParticipantID,
+		SessionID:       event.ParticipantState.CurrentStudySession,
+		KeepContactData: false,
+		Notes:           []types.ContactNote{},
+	}
+
+	if len(mappings) == 0 {
+		mappings = utils.DefaultContactDataMapping
+	}
+	warnings = utils.ApplyContactDataMapping(&pc, event, mappings)
+	return pc, warnings
+}
+
+// mergeParticipantContact applies a new submission to an existing contact: general data (from participant flags)
+// is taken over as it is, contact data only where the new submission contains a value
+func mergeParticipantContact(existing types.ParticipantContact, submitted types.ParticipantContact) types.ParticipantContact {
+	merged := existing
+	merged.SessionID = submitted.SessionID
+	merged.UpdatedAt = time.Now().Unix()
+	if submitted.General != nil {
+		merged.General = submitted.General
+	}
+
+	if submitted.ContactData == nil {
+		return merged
+	}
+	if existing.ContactData == nil {
+		merged.ContactData = submitted.ContactData
+		return merged
+	}
+
+	cd := *existing.ContactData
+	nd := submitted.ContactData
+	cd.FirstName = mergeText(cd.FirstName, nd.FirstName)
+	cd.LastName = mergeText(cd.LastName, nd.LastName)
+	cd.Email = mergeText(cd.Email, nd.Email)
+	cd.Phone = mergeText(cd.Phone, nd.Phone)
+	cd.Gender = mergeText(cd.Gender, nd.Gender)
+	if nd.Birthday != 0 {
+		cd.Birthday = nd.Birthday
+	}
+	if nd.GP != nil {
+		if cd.GP == nil {
+			cd.GP = nd.GP
+		} else {
+			gp := *cd.GP
+			gp.Office = mergeText(gp.Office, nd.GP.Office)
+			gp.Name = mergeText(gp.Name, nd.GP.Name)
+			gp.Phone = mergeText(gp.Phone, nd.GP.Phone)
+			gp.Address.Street = mergeText(gp.Address.Street, nd.GP.Address.Street)
+			gp.Address.Nr = mergeText(gp.Address.Nr, nd.GP.Address.Nr)
+			gp.Address.Postcode = mergeText(gp.Address.Postcode, nd.GP.Address.Postcode)
+			gp.Address.City = mergeText(gp.Address.City, nd.GP.Address.City)
+			cd.GP = &gp
+		}
+	}
+	merged.ContactData = &cd
+	return merged
+}
+
+func mergeText(current string, submitted string) string {
+	if len(submitted) > 0 {
+		return submitted
+	}
+	return current
+}
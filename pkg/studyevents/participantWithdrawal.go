@@ -0,0 +1,162 @@
+package studyevents
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/coneno/logger"
+	"github.com/influenzanet/messaging-service/pkg/api/email_client_service"
+	"github.com/tekenradar/researcher-backend/pkg/types"
+)
+
+func init() {
+	RegisterHandler(types.STUDY_EVENT_TYPE_PARTICIPANT_WITHDRAWAL, []string{types.STUDY_ENGINE_EVENT_LEAVE, types.STUDY_ENGINE_EVENT_SUBMIT}, (*Processor).processParticipantWithdrawalEvent)
+}
+
+// processParticipantWithdrawalEvent marks the participant as withdrawn, removes the participant's other study events
+// (pending ones are cancelled), deletes the participant's contacts from every substudy (regardless of KeepContactData),
+// records the erasure in the audit log and notifies the subscribers of affected studies
+func (p *Processor) processParticipantWithdrawalEvent(entry types.StudyEventInboxEntry) error {
+	participantID := entry.Payload.ParticipantState.ParticipantID
+	if len(participantID) < 1 {
+		return errors.New("participant ID missing")
+	}
+
+	// first, so that a pending t0-invite cannot create a contact again after the erasure
+	purgeTask := "purge-events"
+	if !hasCompletedTask(entry, purgeTask) {
+		if err := p.purgeStudyEventsOfParticipant(entry, participantID); err != nil {
+			return fmt.Errorf("failed to purge study events: %v", err)
+		}
+		p.completeTask(entry, purgeTask)
+	}
+
+	studyInfos, err := p.researcherDB.FindAllStudyInfos()
+	if err != nil {
+		return err
+	}
+
+	errs := []string{}
+	for _, studyInfo := range studyInfos {
+		// contacts found are recorded before they are deleted, so that a retry still notifies the subscribers
+		// when the contacts are already gone
+		foundTask := "contacts-found:" + studyInfo.Key
+		eraseTask := "erase:" + studyInfo.Key
+		found := hasCompletedTask(entry, foundTask)
+		if !hasCompletedTask(entry, eraseTask) {
+			if !found {
+				count, err := p.researcherDB.CountParticipantContactsByParticipantID(studyInfo.Key, participantID)
+				if err == nil && count > 0 {
+					err = p.researcherDB.AddCompletedStudyEventTask(entry.ID, foundTask)
+				}
+				if err != nil {
+					errs = append(errs, fmt.Sprintf("failed to find participant contacts for %s: %v", studyInfo.Key, err))
+					continue
+				}
+				found = count > 0
+			}
+
+			count, err := p.researcherDB.DeleteParticipantContactsByParticipantID(studyInfo.Key, participantID)
+			if err != nil || count > 0 {
+				p.writeErasureAuditLog(entry, studyInfo.Key, map[string]string{
+					"deletedContacts": strconv.FormatInt(count, 10),
+				}, err)
+			}
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("failed to delete participant contacts for %s: %v", studyInfo.Key, err))
+				continue
+			}
+			if count > 0 {
+				logger.Info.Printf("deleted %d participant contact(s) from %s after withdrawal", count, studyInfo.Key)
+			}
+			p.completeTask(entry, eraseTask)
+		}
+		if !found {
+			continue
+		}
+
+		subs, err := p.researcherDB.FindNotificationSubscriptions(studyInfo.Key, types.NOTIFICATION_TOPIC_CONTACT)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("failed to fetch notification subscriptions for %s: %v", studyInfo.Key, err))
+			continue
+		}
+		for _, sub := range subs {
+			notificationTask := "withdrawal-notification:" + studyInfo.Key + ":" + sub.Email
+			if hasCompletedTask(entry, notificationTask) {
+				continue
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), sendEmailTimeout)
+			_, err := p.clients.EmailClientService.SendEmail(ctx, &email_client_service.SendEmailReq{
+				To:      []string{sub.Email},
+				Subject: fmt.Sprintf("Tekenradar - contact entry removed in study %s", studyInfo.Name),
+				Content: fmt.Sprintf(
+					"A participant of the %s (%s) study withdrew their consent. Their contact entry has been removed from the system. \n\n You are receiving this message because your email address is registered in the tekenradar researcher app for this study. Contact: tekenradar@rivm.nl",
+					studyInfo.Name, studyInfo.Key,
+				),
+			})
+			cancel()
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("failed to send notification for %s: %v", sub.Email, err))
+				continue
+			}
+			p.completeTask(entry, notificationTask)
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// purgeStudyEventsOfParticipant marks the participant as withdrawn and removes the other inbox entries and the
+// processed event records of the participant. Retries of events submitted before the withdrawal are no longer
+// recognised as processed, but are ignored because of the mark. The erasure is recorded in the audit log without
+// study key.
+func (p *Processor) purgeStudyEventsOfParticipant(entry types.StudyEventInboxEntry, participantID string) error {
+	var deleted, redacted int64
+	params := map[string]string{
+		"withdrawnAt": strconv.FormatInt(entry.ReceivedAt, 10),
+	}
+	err := p.researcherDB.AddWithdrawnParticipant(participantID, entry.ReceivedAt)
+	if err == nil {
+		deleted, redacted, err = p.researcherDB.PurgeStudyEventsOfParticipant(participantID, entry.ID)
+		params["deletedStudyEvents"] = strconv.FormatInt(deleted, 10)
+		params["redactedStudyEvents"] = strconv.FormatInt(redacted, 10)
+	}
+	if err == nil {
+		var count int64
+		count, err = p.researcherDB.DeleteProcessedStudyEventsOfParticipant(participantID)
+		params["deletedProcessedEvents"] = strconv.FormatInt(count, 10)
+	}
+	p.writeErasureAuditLog(entry, "", params, err)
+	if err == nil {
+		logger.Info.Printf("purged study events of withdrawn participant: %d pending deleted, %d redacted", deleted, redacted)
+	}
+	return err
+}
+
+func (p *Processor) writeErasureAuditLog(entry types.StudyEventInboxEntry, studyKey string, params map[string]string, err error) {
+	params["eventID"] = entry.ID.Hex()
+	logEntry := types.AuditLogEntry{
+		Time:       time.Now().Unix(),
+		Actor:      types.AUDIT_ACTOR_STUDY_SERVICE,
+		Action:     types.AUDIT_ACTION_ERASE_WITHDRAWN_CONTACTS,
+		StudyKey:   studyKey,
+		TargetID:   entry.Payload.ParticipantState.ParticipantID,
+		Parameters: params,
+		Outcome:    types.AUDIT_OUTCOME_SUCCESS,
+	}
+	if err != nil {
+		logEntry.Outcome = types.AUDIT_OUTCOME_FAILURE
+		logEntry.Error = err.Error()
+	}
+	if _, err := p.researcherDB.AddAuditLogEntry(logEntry); err != nil {
+		logger.Error.Printf("failed to write audit log entry %v: %v", logEntry, err)
+	}
+}
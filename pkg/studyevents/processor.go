@@ -0,0 +1,197 @@
+package studyevents
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/coneno/logger"
+	"github.com/influenzanet/messaging-service/pkg/api/email_client_service"
+	"github.com/influenzanet/study-service/pkg/studyengine"
+	"github.com/tekenradar/researcher-backend/pkg/db"
+	"github.com/tekenradar/researcher-backend/pkg/grpc/clients"
+	"github.com/tekenradar/researcher-backend/pkg/types"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	sendEmailTimeout = 30 * time.Second
+)
+
+// Processor handles the study events from the inbox. Every step is recorded as a completed task of the
+// entry, so that a retry only repeats the steps that failed.
+type Processor struct {
+	researcherDB *db.ResearcherDBService
+	clients      *clients.APIClients
+}
+
+func init() {
+	RegisterHandler(types.STUDY_EVENT_TYPE_T0_INVITE, []string{types.STUDY_ENGINE_EVENT_SUBMIT}, (*Processor).processT0InviteEvent)
+}
+
+func NewProcessor(researcherDB *db.ResearcherDBService, clients *clients.APIClients) *Processor {
+	return &Processor{
+		researcherDB: researcherDB,
+		clients:      clients,
+	}
+}
+
+// ProcessEvent runs the handler registered for the event type of the entry
+func (p *Processor) ProcessEvent(entry types.StudyEventInboxEntry) error {
+	handler, ok := handlers[entry.EventType]
+	if !ok {
+		return fmt.Errorf("no handler for event type '%s'", entry.EventType)
+	}
+
+	start := time.Now()
+	err := handler(p, entry)
+	recordAttempt(entry.EventType, time.Since(start), err)
+	return err
+}
+
+func (p *Processor) processT0InviteEvent(entry types.StudyEventInboxEntry) error {
+	event := entry.Payload
+	if withdrawn, err := p.submittedBeforeWithdrawal(event); err != nil {
+		return err
+	} else if withdrawn {
+		logger.Info.Printf("study event %s of withdrawn participant ignored", entry.ID.Hex())
+		return nil
+	}
+
+	studyInfos, err := p.researcherDB.FindAllStudyInfos()
+	if err != nil {
+		return err
+	}
+
+	errs := []string{}
+	for _, studyInfo := range studyInfos {
+		if !studyInfo.Features.Contacts {
+			continue
+		}
+
+		if !ShouldIncludeParticipantContact(studyInfo, event) {
+			continue
+		}
+
+		contactTask := "contact:" + studyInfo.Key
+		if !hasCompletedTask(entry, contactTask) {
+			pc, warnings := ExtractParticipantContactInfosFromEvent(event, studyInfo.ContactFeatureConfig.ContactDataMapping)
+			for _, w := range warnings {
+				logger.Debug.Printf("contact data for %s: %s", studyInfo.Key, w)
+			}
+			pc.Status = studyInfo.GetInitialContactStatus()
+
+			if err := p.saveParticipantContact(studyInfo.Key, pc); err != nil {
+				errs = append(errs, fmt.Sprintf("failed to save participant contact for %s: %v", studyInfo.Key, err))
+				continue
+			}
+			p.completeTask(entry, contactTask)
+		}
+
+		subs, err := p.researcherDB.FindNotificationSubscriptions(studyInfo.Key, types.NOTIFICATION_TOPIC_CONTACT)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("failed to fetch notification subscriptions for %s: %v", studyInfo.Key, err))
+			continue
+		}
+		for _, sub := range subs {
+			notificationTask := "notification:" + studyInfo.Key + ":" + sub.Email
+			if hasCompletedTask(entry, notificationTask) {
+				continue
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), sendEmailTimeout)
+			_, err := p.clients.EmailClientService.SendEmail(ctx, &email_client_service.SendEmailReq{
+				To:      []string{sub.Email},
+				Subject: fmt.Sprintf("Tekenradar - new contact entry added in study %s", studyInfo.Name),
+				Content: fmt.Sprintf(
+					"A participant matching the flags for %s (%s) study just entered or updated contact information. \n\nIf no action is taken, the entry will automatically be removed from the system in %s. \n\n You are receiving this message because your email address is registered in the tekenradar researcher app for this study. Contact: tekenradar@rivm.nl",
+					studyInfo.Name, studyInfo.Key, formatRetentionPeriod(studyInfo.GetContactRetentionPolicy().RemoveContactDataAfterDays),
+				),
+			})
+			cancel()
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("failed to send notification for %s: %v", sub.Email, err))
+				continue
+			}
+			p.completeTask(entry, notificationTask)
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// submittedBeforeWithdrawal checks if the participant withdrew after the response of the event was submitted.
+// Responses without submission time count as submitted before.
+func (p *Processor) submittedBeforeWithdrawal(event studyengine.ExternalEventPayload) (bool, error) {
+	participantID := event.ParticipantState.ParticipantID
+	if len(participantID) < 1 {
+		return false, nil
+	}
+	withdrawnAt, err := p.researcherDB.FindParticipantWithdrawnAt(participantID)
+	if err != nil {
+		return false, err
+	}
+	return withdrawnAt > 0 && event.Response.SubmittedAt <= withdrawnAt, nil
+}
+
+// saveParticipantContact updates the participant's existing contact in the substudy, or adds a new one
+func (p *Processor) saveParticipantContact(substudyKey string, pc types.ParticipantContact) error {
+	if len(pc.ParticipantID) < 1 {
+		_, err := p.researcherDB.AddParticipantContact(substudyKey, pc)
+		return err
+	}
+
+	existing, err := p.researcherDB.FindParticipantContactByParticipantID(substudyKey, pc.ParticipantID)
+	if err != nil {
+		if err != mongo.ErrNoDocuments {
+			return err
+		}
+		_, err := p.researcherDB.AddParticipantContact(substudyKey, pc)
+		return err
+	}
+
+	merged := mergeParticipantContact(existing, pc)
+	err = p.researcherDB.UpdateParticipantContactData(substudyKey, merged, types.ContactDataChange{
+		Time:        merged.UpdatedAt,
+		SessionID:   existing.SessionID,
+		General:     existing.General,
+		ContactData: existing.ContactData,
+	})
+	if err == nil {
+		logger.Info.Printf("participant contact %s in %s updated by new submission", existing.ID.Hex(), substudyKey)
+	}
+	return err
+}
+
+func (p *Processor) completeTask(entry types.StudyEventInboxEntry, task string) {
+	if err := p.researcherDB.AddCompletedStudyEventTask(entry.ID, task); err != nil {
+		logger.Error.Printf("failed to record task '%s' of study event %s: %v", task, entry.ID.Hex(), err)
+	}
+}
+
+// formatRetentionPeriod writes the number of days as weeks where possible, e.g. "12 weeks" or "10 days"
+func formatRetentionPeriod(days int) string {
+	switch {
+	case days == 1:
+		return "1 day"
+	case days == 7:
+		return "1 week"
+	case days%7 == 0:
+		return fmt.Sprintf("%d weeks", days/7)
+	}
+	return fmt.Sprintf("%d days", days)
+}
+
+func hasCompletedTask(entry types.StudyEventInboxEntry, task string) bool {
+	for _, t := range entry.CompletedTasks {
+		if t == task {
+			return true
+		}
+	}
+	return false
+}
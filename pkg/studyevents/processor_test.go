@@ -0,0 +1,23 @@
+package studyevents
+
+import "testing"
+
+func TestFormatRetentionPeriod(t *testing.T) {
+	tests := []struct {
+		days int
+		want string
+	}{
+		{1, "1 day"},
+		{2, "2 days"},
+		{7, "1 week"},
+		{10, "10 days"},
+		{14, "2 weeks"},
+		{84, "12 weeks"},
+		{365, "365 days"},
+	}
+	for _, tt := range tests {
+		if got := formatRetentionPeriod(tt.days); got != tt.want {
+			t.Errorf("formatRetentionPeriod(%d) = %s, want %s", tt.days, got, tt.want)
+		}
+	}
+}
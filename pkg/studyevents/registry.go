@@ -0,0 +1,55 @@
+package studyevents
+
+import (
+	"github.com/tekenradar/researcher-backend/pkg/types"
+
+	"fmt"
+	"sort"
+)
+
+// HandlerFunc processes one event from the inbox. Returning an error schedules a retry.
+type HandlerFunc func(p *Processor, entry types.StudyEventInboxEntry) error
+
+var (
+	handlers      = map[string]HandlerFunc{}
+	acceptedTypes = map[string][]string{}
+)
+
+// RegisterHandler makes the event key available at /v1/study-events/:eventKey. eventTypes are the
+// study engine event types (payload eventType) the handler accepts besides the event key itself.
+// Handlers are registered in init functions, registering the same key twice panics.
+func RegisterHandler(eventKey string, eventTypes []string, handler HandlerFunc) {
+	if _, ok := handlers[eventKey]; ok {
+		panic(fmt.Sprintf("study event handler for '%s' registered twice", eventKey))
+	}
+	handlers[eventKey] = handler
+	acceptedTypes[eventKey] = eventTypes
+	metrics.handlers[eventKey] = &HandlerMetrics{EventKey: eventKey}
+}
+
+func IsKnownEventKey(eventKey string) bool {
+	_, ok := handlers[eventKey]
+	return ok
+}
+
+// AcceptsEventType tells if a payload with the given eventType may be posted to the event key
+func AcceptsEventType(eventKey string, eventType string) bool {
+	if eventType == eventKey {
+		return true
+	}
+	for _, t := range acceptedTypes[eventKey] {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+func EventKeys() []string {
+	keys := make([]string, 0, len(handlers))
+	for k := range handlers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
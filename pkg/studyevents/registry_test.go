@@ -0,0 +1,30 @@
+package studyevents
+
+import (
+	"testing"
+
+	"github.com/tekenradar/researcher-backend/pkg/types"
+)
+
+func TestAcceptsEventType(t *testing.T) {
+	tests := []struct {
+		eventKey  string
+		eventType string
+		want      bool
+	}{
+		{types.STUDY_EVENT_TYPE_T0_INVITE, types.STUDY_ENGINE_EVENT_SUBMIT, true},
+		{types.STUDY_EVENT_TYPE_T0_INVITE, types.STUDY_EVENT_TYPE_T0_INVITE, true},
+		{types.STUDY_EVENT_TYPE_T0_INVITE, types.STUDY_ENGINE_EVENT_LEAVE, false},
+		{types.STUDY_EVENT_TYPE_T0_INVITE, types.STUDY_EVENT_TYPE_PARTICIPANT_WITHDRAWAL, false},
+		{types.STUDY_EVENT_TYPE_T0_INVITE, "", false},
+		{types.STUDY_EVENT_TYPE_PARTICIPANT_WITHDRAWAL, types.STUDY_ENGINE_EVENT_LEAVE, true},
+		{types.STUDY_EVENT_TYPE_PARTICIPANT_WITHDRAWAL, types.STUDY_EVENT_TYPE_T0_INVITE, false},
+		{"unknown", "unknown", true},
+		{"unknown", types.STUDY_ENGINE_EVENT_SUBMIT, false},
+	}
+	for _, tt := range tests {
+		if got := AcceptsEventType(tt.eventKey, tt.eventType); got != tt.want {
+			t.Errorf("AcceptsEventType(%s, %s) = %v, want %v", tt.eventKey, tt.eventType, got, tt.want)
+		}
+	}
+}
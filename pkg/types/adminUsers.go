@@ -0,0 +1,12 @@
+package types
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+type AdminUser struct {
+	ID      primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Email   string             `bson:"email" json:"email"`
+	AddedAt int64              `bson:"addedAt" json:"addedAt"`
+	AddedBy string             `bson:"addedBy" json:"addedBy"`
+
+	RemovingAt int64 `bson:"removingAt,omitempty" json:"-"` // set while the user is being removed
+}
@@ -0,0 +1,56 @@
+package types
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+const (
+	API_KEY_SCOPE_AUTH_INIT          = "auth:init"
+	API_KEY_SCOPE_AUTH_RENEW         = "auth:renew"
+	API_KEY_SCOPE_STUDY_EVENTS_WRITE = "study-events:write"
+	API_KEY_SCOPE_RESEARCHER_APP     = "researcher-app" // substudy and substudy-management endpoints
+)
+
+var AllAPIKeyScopes = []string{
+	API_KEY_SCOPE_AUTH_INIT,
+	API_KEY_SCOPE_AUTH_RENEW,
+	API_KEY_SCOPE_STUDY_EVENTS_WRITE,
+	API_KEY_SCOPE_RESEARCHER_APP,
+}
+
+// APIKey is stored with the hash of the key only, the key itself is shown once on creation
+type APIKey struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Name       string             `bson:"name" json:"name"`
+	KeyHash    string             `bson:"keyHash" json:"-"`
+	Scopes     []string           `bson:"scopes" json:"scopes"`
+	CreatedAt  int64              `bson:"createdAt" json:"createdAt"`
+	CreatedBy  string             `bson:"createdBy" json:"createdBy"`
+	ExpiresAt  int64              `bson:"expiresAt" json:"expiresAt"` // 0 if the key does not expire
+	LastUsedAt int64              `bson:"lastUsedAt" json:"lastUsedAt"`
+	RevokedAt  int64              `bson:"revokedAt" json:"revokedAt"`
+}
+
+func IsValidAPIKeyScope(scope string) bool {
+	for _, s := range AllAPIKeyScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func (k APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// IsActive checks if the key is neither revoked nor expired at the given time
+func (k APIKey) IsActive(now int64) bool {
+	if k.RevokedAt > 0 {
+		return false
+	}
+	return k.ExpiresAt == 0 || k.ExpiresAt > now
+}
@@ -0,0 +1,67 @@
+package types
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+const (
+	AUDIT_OUTCOME_SUCCESS = "success"
+	AUDIT_OUTCOME_FAILURE = "failure"
+)
+
+const (
+	AUDIT_ACTION_READ_PARTICIPANT_CONTACTS   = "participant-contacts.read"
+	AUDIT_ACTION_READ_PARTICIPANT_CONTACT    = "participant-contact.read"
+	AUDIT_ACTION_EXPORT_PARTICIPANT_CONTACTS = "participant-contacts.export"
+	AUDIT_ACTION_UPDATE_CONTACT_KEEP_STATUS  = "participant-contact.keep-status.update"
+	AUDIT_ACTION_ADD_CONTACT_NOTE            = "participant-contact.note.add"
+	AUDIT_ACTION_UPDATE_CONTACT_NOTE         = "participant-contact.note.update"
+	AUDIT_ACTION_DELETE_CONTACT_NOTE         = "participant-contact.note.delete"
+	AUDIT_ACTION_ADD_NOTE_ATTACHMENT         = "participant-contact.note.attachment.add"
+	AUDIT_ACTION_READ_NOTE_ATTACHMENT        = "participant-contact.note.attachment.read"
+	AUDIT_ACTION_DELETE_NOTE_ATTACHMENT      = "participant-contact.note.attachment.delete"
+	AUDIT_ACTION_ACKNOWLEDGE_CONTACT_UPDATE  = "participant-contact.update.acknowledge"
+	AUDIT_ACTION_UPDATE_CONTACT_STATUS       = "participant-contact.status.update"
+	AUDIT_ACTION_DELETE_PARTICIPANT_CONTACT  = "participant-contact.delete"
+	AUDIT_ACTION_DOWNLOAD_DATASET            = "dataset.download"
+	AUDIT_ACTION_CREATE_DATASET_EXPORT_JOB   = "dataset.export-job.create"
+	AUDIT_ACTION_SAVE_STUDY_INFO             = "study-info.save"
+	AUDIT_ACTION_DELETE_STUDY_INFO           = "study-info.delete"
+	AUDIT_ACTION_READ_AUDIT_LOG              = "audit-log.read"
+	AUDIT_ACTION_CREATE_API_KEY              = "api-key.create"
+	AUDIT_ACTION_ROTATE_API_KEY              = "api-key.rotate"
+	AUDIT_ACTION_REVOKE_API_KEY              = "api-key.revoke"
+	AUDIT_ACTION_UPDATE_API_KEY_SCOPES       = "api-key.update-scopes"
+	AUDIT_ACTION_ADD_ADMIN_USER              = "admin-user.add"
+	AUDIT_ACTION_DELETE_ADMIN_USER           = "admin-user.delete"
+	AUDIT_ACTION_READ_STUDY_EVENTS           = "study-events.read"
+	AUDIT_ACTION_REPLAY_STUDY_EVENT          = "study-event.replay"
+	AUDIT_ACTION_ERASE_WITHDRAWN_CONTACTS    = "participant-contacts.withdrawal-erasure"
+)
+
+const (
+	AUDIT_ACTOR_STUDY_SERVICE = "study-service" // actions triggered by study events
+)
+
+// AuditLogEntry records one action of a researcher. Entries are never updated or deleted.
+type AuditLogEntry struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Time       int64              `bson:"time" json:"time"`
+	Actor      string             `bson:"actor" json:"actor"`
+	Action     string             `bson:"action" json:"action"`
+	StudyKey   string             `bson:"studyKey,omitempty" json:"studyKey,omitempty"`
+	TargetID   string             `bson:"targetID,omitempty" json:"targetID,omitempty"`
+	Parameters map[string]string  `bson:"parameters,omitempty" json:"parameters,omitempty"`
+	Outcome    string             `bson:"outcome" json:"outcome"`
+	Error      string             `bson:"error,omitempty" json:"error,omitempty"`
+	ClientIP   string             `bson:"clientIP" json:"clientIP"`
+}
+
+type AuditLogQuery struct {
+	Actor    string
+	Action   string
+	StudyKey string
+	TargetID string
+	From     int64
+	Until    int64
+	Limit    int64  // 0 for all
+	Cursor   string // returned by the previous page
+}
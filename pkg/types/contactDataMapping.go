@@ -0,0 +1,27 @@
+package types
+
+// Where the value of a contact data field is read from
+const (
+	CONTACT_MAPPING_SOURCE_FLAG     = "flag"     // participant flag
+	CONTACT_MAPPING_SOURCE_RESPONSE = "response" // slot of a survey item response
+)
+
+// How the raw value is converted
+const (
+	CONTACT_MAPPING_TRANSFORM_TEXT          = "text"         // value as it is (default)
+	CONTACT_MAPPING_TRANSFORM_NUMBER        = "number"       // integer part of a number, e.g. "34.5" -> 34
+	CONTACT_MAPPING_TRANSFORM_DATE          = "date"         // unix timestamp, or date formatted as YYYY-MM-DD
+	CONTACT_MAPPING_TRANSFORM_BOOL          = "bool"         // true if the value is "true"
+	CONTACT_MAPPING_TRANSFORM_SINGLE_CHOICE = "singleChoice" // key of the selected option, translated with Mapping
+)
+
+// ContactDataMapping describes how one field of the participant contact is filled from a study event
+type ContactDataMapping struct {
+	Target    string            `bson:"target" json:"target"` // e.g. "general.age" or "contactData.gp.address.city"
+	Source    string            `bson:"source" json:"source"`
+	FlagKey   string            `bson:"flagKey,omitempty" json:"flagKey,omitempty"`
+	ItemKey   string            `bson:"itemKey,omitempty" json:"itemKey,omitempty"`
+	SlotKey   string            `bson:"slotKey,omitempty" json:"slotKey,omitempty"` // for single choice questions defaults to "rg.scg"
+	Transform string            `bson:"transform,omitempty" json:"transform,omitempty"`
+	Mapping   map[string]string `bson:"mapping,omitempty" json:"mapping,omitempty"`
+}
@@ -0,0 +1,49 @@
+package types
+
+import "time"
+
+const (
+	DEFAULT_CONTACT_DATA_RETENTION_DAYS = 7 * 12
+)
+
+// ContactRetentionPolicy controls how long participant contacts of a study are stored, 0 uses the default
+type ContactRetentionPolicy struct {
+	RemoveContactDataAfterDays int `bson:"removeContactDataAfterDays" json:"removeContactDataAfterDays"` // default 84
+	DeleteAfterDays            int `bson:"deleteAfterDays" json:"deleteAfterDays"`                       // whole document, once the contact data is removed. 0: never
+	KeptContactsMaxDays        int `bson:"keptContactsMaxDays" json:"keptContactsMaxDays"`               // contact data of kept contacts is removed afterwards. 0: never
+}
+
+// GetContactRetentionPolicy returns the study's policy with defaults applied
+func (si StudyInfo) GetContactRetentionPolicy() ContactRetentionPolicy {
+	policy := si.ContactFeatureConfig.RetentionPolicy
+	if policy.RemoveContactDataAfterDays <= 0 {
+		policy.RemoveContactDataAfterDays = DEFAULT_CONTACT_DATA_RETENTION_DAYS
+	}
+	return policy
+}
+
+// ContactDataExpiresAt returns when the cleanup will remove the contact data of the contact, or 0 if never
+// (or already removed). Contacts in a status with removeContactData retention expire right away.
+func (si StudyInfo) ContactDataExpiresAt(pc ParticipantContact) int64 {
+	if pc.ContactData == nil {
+		return 0
+	}
+	policy := si.GetContactRetentionPolicy()
+
+	statusKey := pc.Status
+	if len(statusKey) == 0 {
+		statusKey = si.GetInitialContactStatus()
+	}
+	status, _ := si.FindContactStatus(statusKey)
+
+	days := policy.RemoveContactDataAfterDays
+	if pc.KeepContactData || status.Retention == CONTACT_STATUS_RETENTION_KEEP {
+		if policy.KeptContactsMaxDays <= 0 {
+			return 0
+		}
+		days = policy.KeptContactsMaxDays
+	} else if status.Retention == CONTACT_STATUS_RETENTION_REMOVE {
+		return time.Now().Unix()
+	}
+	return time.Unix(pc.LastChangedAt(), 0).AddDate(0, 0, days).Unix()
+}
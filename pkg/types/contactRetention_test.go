@@ -0,0 +1,107 @@
+package types
+
+import (
+	"testing"
+	"time"
+)
+
+func TestContactDataExpiresAt(t *testing.T) {
+	addedAt := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC).Unix()
+	updatedAt := time.Date(2022, 4, 1, 12, 0, 0, 0, time.UTC).Unix()
+	after := func(from int64, days int) int64 {
+		return time.Unix(from, 0).AddDate(0, 0, days).Unix()
+	}
+
+	customPolicy := StudyInfo{}
+	customPolicy.ContactFeatureConfig.RetentionPolicy = ContactRetentionPolicy{RemoveContactDataAfterDays: 30, KeptContactsMaxDays: 365}
+
+	customPipeline := StudyInfo{}
+	customPipeline.ContactFeatureConfig.StatusPipeline = []ContactStatus{
+		{Key: "open"},
+		{Key: "done", Retention: CONTACT_STATUS_RETENTION_KEEP},
+	}
+
+	tests := []struct {
+		name    string
+		study   StudyInfo
+		contact ParticipantContact
+		want    int64
+	}{
+		{
+			name:    "contact data already removed",
+			study:   StudyInfo{},
+			contact: ParticipantContact{AddedAt: addedAt},
+			want:    0,
+		},
+		{
+			name:    "default retention",
+			study:   StudyInfo{},
+			contact: ParticipantContact{AddedAt: addedAt, ContactData: &ContactDetailsContactData{}, Status: "new"},
+			want:    after(addedAt, DEFAULT_CONTACT_DATA_RETENTION_DAYS),
+		},
+		{
+			name:    "counted from the last update",
+			study:   StudyInfo{},
+			contact: ParticipantContact{AddedAt: addedAt, UpdatedAt: updatedAt, ContactData: &ContactDetailsContactData{}},
+			want:    after(updatedAt, DEFAULT_CONTACT_DATA_RETENTION_DAYS),
+		},
+		{
+			name:    "study policy",
+			study:   customPolicy,
+			contact: ParticipantContact{AddedAt: addedAt, ContactData: &ContactDetailsContactData{}},
+			want:    after(addedAt, 30),
+		},
+		{
+			name:    "kept without maximum",
+			study:   StudyInfo{},
+			contact: ParticipantContact{AddedAt: addedAt, ContactData: &ContactDetailsContactData{}, KeepContactData: true},
+			want:    0,
+		},
+		{
+			name:    "kept with maximum",
+			study:   customPolicy,
+			contact: ParticipantContact{AddedAt: addedAt, ContactData: &ContactDetailsContactData{}, KeepContactData: true},
+			want:    after(addedAt, 365),
+		},
+		{
+			name:    "status with keep retention",
+			study:   customPolicy,
+			contact: ParticipantContact{AddedAt: addedAt, ContactData: &ContactDetailsContactData{}, Status: "enrolled"},
+			want:    after(addedAt, 365),
+		},
+		{
+			name:    "contact without status is in the initial status",
+			study:   customPipeline,
+			contact: ParticipantContact{AddedAt: addedAt, ContactData: &ContactDetailsContactData{}},
+			want:    after(addedAt, DEFAULT_CONTACT_DATA_RETENTION_DAYS),
+		},
+		{
+			name:    "status with keep retention in custom pipeline",
+			study:   customPipeline,
+			contact: ParticipantContact{AddedAt: addedAt, ContactData: &ContactDetailsContactData{}, Status: "done"},
+			want:    0,
+		},
+		{
+			name:    "unknown status",
+			study:   customPipeline,
+			contact: ParticipantContact{AddedAt: addedAt, ContactData: &ContactDetailsContactData{}, Status: "enrolled"},
+			want:    after(addedAt, DEFAULT_CONTACT_DATA_RETENTION_DAYS),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.study.ContactDataExpiresAt(tt.contact); got != tt.want {
+				t.Errorf("ContactDataExpiresAt() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("status with removeContactData retention", func(t *testing.T) {
+		before := time.Now().Unix()
+		got := StudyInfo{}.ContactDataExpiresAt(ParticipantContact{AddedAt: addedAt, ContactData: &ContactDetailsContactData{}, Status: "declined"})
+		if got < before || got > time.Now().Unix() {
+			t.Errorf("expected to expire now, got %d", got)
+		}
+	})
+}
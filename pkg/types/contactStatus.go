@@ -0,0 +1,83 @@
+package types
+
+// What the retention cleanup does with contacts in a status
+const (
+	CONTACT_STATUS_RETENTION_DEFAULT = ""                  // contact data is removed after the usual retention period
+	CONTACT_STATUS_RETENTION_KEEP    = "keep"              // contact data is kept, like with KeepContactData
+	CONTACT_STATUS_RETENTION_REMOVE  = "removeContactData" // contact data is removed at the next cleanup
+)
+
+// ContactStatus is one step of a study's recruitment pipeline
+type ContactStatus struct {
+	Key       string   `bson:"key" json:"key"`
+	Label     string   `bson:"label" json:"label"`
+	Next      []string `bson:"next,omitempty" json:"next,omitempty"` // statuses that can follow, any if empty
+	Retention string   `bson:"retention,omitempty" json:"retention,omitempty"`
+}
+
+// ContactStatusChange records one transition of a participant contact's status
+type ContactStatusChange struct {
+	Time    int64  `bson:"time" json:"time"`
+	Author  string `bson:"author" json:"author"`
+	From    string `bson:"from" json:"from"`
+	To      string `bson:"to" json:"to"`
+	Comment string `bson:"comment,omitempty" json:"comment,omitempty"`
+}
+
+// DefaultContactStatusPipeline is used for studies without their own pipeline
+var DefaultContactStatusPipeline = []ContactStatus{
+	{Key: "new", Label: "New"},
+	{Key: "contacted", Label: "Contacted"},
+	{Key: "enrolled", Label: "Enrolled", Retention: CONTACT_STATUS_RETENTION_KEEP},
+	{Key: "declined", Label: "Declined", Retention: CONTACT_STATUS_RETENTION_REMOVE},
+	{Key: "unreachable", Label: "Unreachable"},
+}
+
+// GetContactStatusPipeline returns the configured statuses, the first one is the status of new contacts
+func (si StudyInfo) GetContactStatusPipeline() []ContactStatus {
+	if len(si.ContactFeatureConfig.StatusPipeline) == 0 {
+		return DefaultContactStatusPipeline
+	}
+	return si.ContactFeatureConfig.StatusPipeline
+}
+
+func (si StudyInfo) GetInitialContactStatus() string {
+	return si.GetContactStatusPipeline()[0].Key
+}
+
+func (si StudyInfo) FindContactStatus(key string) (ContactStatus, bool) {
+	for _, s := range si.GetContactStatusPipeline() {
+		if s.Key == key {
+			return s, true
+		}
+	}
+	return ContactStatus{}, false
+}
+
+// GetContactStatusesWithRetention lists the keys of the statuses with the given retention behaviour
+func (si StudyInfo) GetContactStatusesWithRetention(retention string) []string {
+	keys := []string{}
+	for _, s := range si.GetContactStatusPipeline() {
+		if s.Retention == retention {
+			keys = append(keys, s.Key)
+		}
+	}
+	return keys
+}
+
+// CanChangeContactStatus checks if a contact in status from may be moved to status to
+func (si StudyInfo) CanChangeContactStatus(from string, to string) bool {
+	if _, ok := si.FindContactStatus(to); !ok {
+		return false
+	}
+	current, ok := si.FindContactStatus(from)
+	if !ok || len(current.Next) == 0 {
+		return true
+	}
+	for _, n := range current.Next {
+		if n == to {
+			return true
+		}
+	}
+	return false
+}
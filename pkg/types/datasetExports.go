@@ -0,0 +1,74 @@
+package types
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+const (
+	DATASET_EXPORT_JOB_STATUS_PENDING = "pending"
+	DATASET_EXPORT_JOB_STATUS_RUNNING = "running"
+	DATASET_EXPORT_JOB_STATUS_DONE    = "done"
+	DATASET_EXPORT_JOB_STATUS_FAILED  = "failed"
+)
+
+// formats of dataset exports
+const (
+	DATASET_FORMAT_WIDE_CSV   = "wide"    // one row per response
+	DATASET_FORMAT_LONG_CSV   = "long"    // one row per response slot
+	DATASET_FORMAT_JSON_LINES = "jsonl"   // one JSON object per response and line
+	DATASET_FORMAT_PARQUET    = "parquet" // wide format as Parquet file, all columns as optional strings
+)
+
+func IsValidDatasetFormat(format string) bool {
+	switch format {
+	case DATASET_FORMAT_WIDE_CSV, DATASET_FORMAT_LONG_CSV, DATASET_FORMAT_JSON_LINES, DATASET_FORMAT_PARQUET:
+		return true
+	}
+	return false
+}
+
+// where the results of dataset export jobs are stored
+const (
+	DATASET_EXPORT_STORAGE_GRIDFS = "gridfs"
+	DATASET_EXPORT_STORAGE_DISK   = "disk"
+)
+
+// DatasetExportQuery holds the options of a dataset export, as given in the query parameters of the download
+type DatasetExportQuery struct {
+	Format            string `bson:"format" json:"format"` // empty for the wide format of jobs created before formats were introduced
+	From              int64  `bson:"from" json:"from"`
+	Until             int64  `bson:"until" json:"until"`
+	WithPositions     bool   `bson:"withPositions" json:"withPositions"`
+	WithInitTimes     bool   `bson:"withInitTimes" json:"withInitTimes"`
+	WithDisplayTimes  bool   `bson:"withDisplayTimes" json:"withDisplayTimes"`
+	WithResponseTimes bool   `bson:"withResponseTimes" json:"withResponseTimes"`
+	Separator         string `bson:"separator" json:"separator"`
+	ShortKeys         bool   `bson:"shortKeys" json:"shortKeys"`
+}
+
+// DatasetExportJob is an export running in the background, the result can be downloaded until ExpiresAt
+type DatasetExportJob struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	StudyKey    string             `bson:"studyKey" json:"studyKey"`
+	DatasetKey  string             `bson:"datasetKey" json:"datasetKey"`
+	Query       DatasetExportQuery `bson:"query" json:"query"`
+	RequestedBy string             `bson:"requestedBy" json:"requestedBy"`
+	Status      string             `bson:"status" json:"status"`
+	Attempts    int                `bson:"attempts" json:"attempts"`
+	LockedUntil int64              `bson:"lockedUntil,omitempty" json:"-"`
+	CreatedAt   int64              `bson:"createdAt" json:"createdAt"`
+	StartedAt   int64              `bson:"startedAt,omitempty" json:"startedAt,omitempty"`
+	FinishedAt  int64              `bson:"finishedAt,omitempty" json:"finishedAt,omitempty"`
+	Error       string             `bson:"error,omitempty" json:"error,omitempty"`
+	FileName    string             `bson:"fileName,omitempty" json:"fileName,omitempty"`
+	ContentType string             `bson:"contentType,omitempty" json:"contentType,omitempty"`
+	FileSize    int64              `bson:"fileSize,omitempty" json:"fileSize,omitempty"`
+	ExpiresAt   int64              `bson:"expiresAt,omitempty" json:"expiresAt,omitempty"` // when the job and its result are deleted
+}
+
+func (si StudyInfo) FindDataset(datasetKey string) (DatasetInfo, bool) {
+	for _, dataset := range si.AvailableDatasets {
+		if dataset.ID == datasetKey {
+			return dataset, true
+		}
+	}
+	return DatasetInfo{}, false
+}
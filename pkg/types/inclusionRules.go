@@ -0,0 +1,25 @@
+package types
+
+// Types of the nodes of an inclusion rule tree
+const (
+	INCLUSION_RULE_AND             = "and"            // all Rules must match
+	INCLUSION_RULE_OR              = "or"             // at least one of the Rules must match
+	INCLUSION_RULE_NOT             = "not"            // the single entry of Rules must not match
+	INCLUSION_RULE_FLAG_EQUALS     = "flagEquals"     // flag FlagKey has the value Value
+	INCLUSION_RULE_FLAG_NOT_EQUALS = "flagNotEquals"  // flag FlagKey is missing or has another value than Value
+	INCLUSION_RULE_FLAG_EXISTS     = "flagExists"     // flag FlagKey is set
+	INCLUSION_RULE_FLAG_IN_RANGE   = "flagInRange"    // flag FlagKey is a number between Min and Max (inclusive, each optional)
+	INCLUSION_RULE_RESPONSE_EQUALS = "responseEquals" // slot SlotKey of item ItemKey has the value Value, or Value is the selected option
+)
+
+// InclusionRule decides if a participant contact is created for a study from a study event
+type InclusionRule struct {
+	Type    string          `bson:"type" json:"type"`
+	Rules   []InclusionRule `bson:"rules,omitempty" json:"rules,omitempty"`
+	FlagKey string          `bson:"flagKey,omitempty" json:"flagKey,omitempty"`
+	ItemKey string          `bson:"itemKey,omitempty" json:"itemKey,omitempty"`
+	SlotKey string          `bson:"slotKey,omitempty" json:"slotKey,omitempty"`
+	Value   string          `bson:"value,omitempty" json:"value,omitempty"`
+	Min     *float64        `bson:"min,omitempty" json:"min,omitempty"`
+	Max     *float64        `bson:"max,omitempty" json:"max,omitempty"`
+}
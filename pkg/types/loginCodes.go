@@ -0,0 +1,17 @@
+package types
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// LoginCode is handed to the web-application after a login via redirect, instead of the tokens. It can be
+// exchanged for a token pair once. Only the hash of the code is stored.
+type LoginCode struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	UserID    string             `bson:"userID" json:"userID"`
+	Roles     []string           `bson:"roles" json:"roles"`
+	CodeHash  string             `bson:"codeHash" json:"-"`
+	ExpiresAt time.Time          `bson:"expiresAt" json:"expiresAt"` // date type, used by the TTL index
+}
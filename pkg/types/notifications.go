@@ -2,6 +2,12 @@ package types
 
 import "go.mongodb.org/mongo-driver/bson/primitive"
 
+const (
+	NOTIFICATION_TOPIC_CONTACT        = "contact"        // new or updated participant contacts
+	NOTIFICATION_TOPIC_CONTACT_EXPIRY = "contact-expiry" // digest of contacts whose contact data will be removed soon
+	NOTIFICATION_TOPIC_DATASET_EXPORT = "dataset-export" // results of dataset export jobs are ready
+)
+
 type NotificationSubscription struct {
 	ID    primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
 	Topic string             `bson:"topic" json:"topic"`
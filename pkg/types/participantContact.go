@@ -11,6 +11,30 @@ type ParticipantContact struct {
 	General         *ContactDetailsGeneralData `bson:"general" json:"general"`
 	ContactData     *ContactDetailsContactData `bson:"contactData" json:"contactData"`
 	Notes           []ContactNote              `bson:"notes" json:"notes"`
+	UpdatedAt       int64                      `bson:"updatedAt,omitempty" json:"updatedAt,omitempty"`
+	Updated         bool                       `bson:"updated" json:"updated"` // participant re-submitted data, not yet acknowledged by a researcher
+	History         []ContactDataChange        `bson:"history,omitempty" json:"history,omitempty"`
+	Status          string                     `bson:"status" json:"status"` // key of the study's status pipeline
+	StatusHistory   []ContactStatusChange      `bson:"statusHistory,omitempty" json:"statusHistory,omitempty"`
+
+	ExpiryWarningSentAt int64 `bson:"expiryWarningSentAt,omitempty" json:"expiryWarningSentAt,omitempty"`
+	ExpiresAt           int64 `bson:"-" json:"expiresAt,omitempty"` // when the contact data will be removed (0: never), computed from the study's retention policy
+}
+
+// LastChangedAt is the time the contact was added, or last updated by a new submission
+func (pc ParticipantContact) LastChangedAt() int64 {
+	if pc.UpdatedAt > pc.AddedAt {
+		return pc.UpdatedAt
+	}
+	return pc.AddedAt
+}
+
+// ContactDataChange keeps the values a participant contact had before it was updated by a new submission
+type ContactDataChange struct {
+	Time        int64                      `bson:"time" json:"time"`
+	SessionID   string                     `bson:"sessionID" json:"sessionID"`
+	General     *ContactDetailsGeneralData `bson:"general" json:"general"`
+	ContactData *ContactDetailsContactData `bson:"contactData" json:"contactData"`
 }
 
 type ContactDetailsGeneralData struct {
@@ -44,8 +68,66 @@ type Address struct {
 }
 
 type ContactNote struct {
-	ID      string `bson:"id" json:"id"`
+	ID          string                  `bson:"id" json:"id"` // generated by the server, assigned at startup for notes added before
+	Time        int64                   `bson:"time" json:"time"`
+	Author      string                  `bson:"author" json:"author"`
+	Content     string                  `bson:"content" json:"content"`
+	EditedAt    int64                   `bson:"editedAt,omitempty" json:"editedAt,omitempty"`
+	EditHistory []ContactNoteEdit       `bson:"editHistory,omitempty" json:"editHistory,omitempty"`
+	Attachments []ContactNoteAttachment `bson:"attachments,omitempty" json:"attachments,omitempty"`
+}
+
+// ContactNoteEdit keeps the content a note had before it was edited
+type ContactNoteEdit struct {
 	Time    int64  `bson:"time" json:"time"`
-	Author  string `bson:"author" json:"author"`
+	Author  string `bson:"author" json:"author"` // who edited the note
 	Content string `bson:"content" json:"content"`
 }
+
+// ContactNoteAttachment describes a file stored in GridFS
+type ContactNoteAttachment struct {
+	ID          string `bson:"id" json:"id"`
+	FileName    string `bson:"fileName" json:"fileName"`
+	ContentType string `bson:"contentType" json:"contentType"`
+	Size        int64  `bson:"size" json:"size"`
+	UploadedAt  int64  `bson:"uploadedAt" json:"uploadedAt"`
+	UploadedBy  string `bson:"uploadedBy" json:"uploadedBy"`
+}
+
+func (pc ParticipantContact) FindNote(noteID string) (ContactNote, bool) {
+	if len(noteID) == 0 {
+		return ContactNote{}, false
+	}
+	for _, note := range pc.Notes {
+		if note.ID == noteID {
+			return note, true
+		}
+	}
+	return ContactNote{}, false
+}
+
+func (note ContactNote) FindAttachment(attachmentID string) (ContactNoteAttachment, bool) {
+	for _, attachment := range note.Attachments {
+		if attachment.ID == attachmentID {
+			return attachment, true
+		}
+	}
+	return ContactNoteAttachment{}, false
+}
+
+// ParticipantContactQuery filters the participant contacts of a substudy, nil / empty fields are not used
+type ParticipantContactQuery struct {
+	KeepContactData *bool
+	MinAge          *int
+	MaxAge          *int
+	Gender          string
+	OtherStudies    *bool
+	HasNotes        *bool
+	Updated         *bool
+	Status          string
+	StatusMissing   bool   // also match contacts without status (added before statuses were introduced)
+	Search          string // part of first name, last name or email, case insensitive
+	SortAscending   bool   // by addedAt, newest first by default
+	Limit           int64  // 0 for all
+	Cursor          string // returned by the previous page
+}
@@ -0,0 +1,25 @@
+package types
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	STUDY_EVENT_STATUS_PROCESSING = "processing"
+	STUDY_EVENT_STATUS_DONE       = "done"
+)
+
+// ProcessedStudyEvent remembers the result of a study event, so that a retried call is not processed twice
+type ProcessedStudyEvent struct {
+	ID          primitive.ObjectID     `bson:"_id,omitempty" json:"id,omitempty"`
+	Key         string                 `bson:"key" json:"key"` // Idempotency-Key header or participant ID + response ID
+	EventType   string                 `bson:"eventType" json:"eventType"`
+	Status      string                 `bson:"status" json:"status"`
+	StartedAt   int64                  `bson:"startedAt" json:"startedAt"`
+	CompletedAt int64                  `bson:"completedAt" json:"completedAt"`
+	StatusCode  int                    `bson:"statusCode" json:"statusCode"`
+	Result      map[string]interface{} `bson:"result" json:"result"`
+	ExpiresAt   time.Time              `bson:"expiresAt" json:"expiresAt"` // date type, used by the TTL index
+}
@@ -0,0 +1,20 @@
+package types
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RenewToken is stored with the hash of the opaque token only. All tokens that were created from
+// the same login share a FamilyID, so that the whole chain can be revoked at once.
+type RenewToken struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	UserID    string             `bson:"userID" json:"userID"`
+	TokenHash string             `bson:"tokenHash" json:"-"`
+	FamilyID  string             `bson:"familyID" json:"familyID"`
+	CreatedAt int64              `bson:"createdAt" json:"createdAt"`
+	UsedAt    int64              `bson:"usedAt" json:"usedAt"`
+	RevokedAt int64              `bson:"revokedAt" json:"revokedAt"`
+	ExpiresAt time.Time          `bson:"expiresAt" json:"expiresAt"` // date type, used by the TTL index
+}
@@ -0,0 +1,49 @@
+package types
+
+import (
+	"time"
+
+	"github.com/influenzanet/study-service/pkg/studyengine"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	STUDY_EVENT_TYPE_T0_INVITE              = "t0-invite"
+	STUDY_EVENT_TYPE_PARTICIPANT_WITHDRAWAL = "participant-withdrawal"
+)
+
+// event types of the study engine, sent by the study service as the payload's eventType
+const (
+	STUDY_ENGINE_EVENT_SUBMIT = "SUBMIT"
+	STUDY_ENGINE_EVENT_LEAVE  = "LEAVE"
+)
+
+const (
+	STUDY_EVENT_INBOX_STATUS_PENDING    = "pending"
+	STUDY_EVENT_INBOX_STATUS_PROCESSING = "processing"
+	STUDY_EVENT_INBOX_STATUS_DONE       = "done"
+	STUDY_EVENT_INBOX_STATUS_FAILED     = "failed" // dead-letter: no further retries, until replayed by an admin
+)
+
+// StudyEventInboxEntry is a received study event waiting for (or done with) processing by the runner
+type StudyEventInboxEntry struct {
+	ID             primitive.ObjectID               `bson:"_id,omitempty" json:"id,omitempty"`
+	EventType      string                           `bson:"eventType" json:"eventType"`
+	ParticipantID  string                           `bson:"participantID,omitempty" json:"participantID,omitempty"` // from the payload, to find the events of a participant
+	Payload        studyengine.ExternalEventPayload `bson:"payload" json:"payload"`                                 // removed once processed
+	Status         string                           `bson:"status" json:"status"`
+	ReceivedAt     int64                            `bson:"receivedAt" json:"receivedAt"`
+	Attempts       int                              `bson:"attempts" json:"attempts"`
+	NextAttemptAt  int64                            `bson:"nextAttemptAt" json:"nextAttemptAt"`
+	LockedUntil    int64                            `bson:"lockedUntil" json:"lockedUntil"` // while processing, entry can be claimed again afterwards
+	LastError      string                           `bson:"lastError,omitempty" json:"lastError,omitempty"`
+	CompletedTasks []string                         `bson:"completedTasks" json:"completedTasks"` // e.g. "contact:<studyKey>", skipped on retries
+	ProcessedAt    int64                            `bson:"processedAt" json:"processedAt"`
+	ExpiresAt      *time.Time                       `bson:"expiresAt,omitempty" json:"expiresAt,omitempty"` // set when done, used by the TTL index
+}
+
+type StudyEventInboxQuery struct {
+	Status    string
+	EventType string
+	Limit     int64
+}
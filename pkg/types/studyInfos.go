@@ -11,7 +11,8 @@ type StudyInfo struct {
 	Description   string             `bson:"description" json:"description"`
 	StudyColor    string             `bson:"studyColor" json:"studyColor"`
 	AccessControl struct {
-		Emails []string `bson:"emails" json:"emails"`
+		Emails  []string      `bson:"emails" json:"emails"` // legacy: listed users get the legacy member role
+		Members []StudyMember `bson:"members" json:"members"`
 	} `bson:"accessControl" json:"accessControl"`
 	Features struct {
 		DatasetExporter bool `bson:"datasetExporter" json:"datasetExporter"`
@@ -19,8 +20,14 @@ type StudyInfo struct {
 	} `bson:"features" json:"features"`
 	AvailableDatasets    []DatasetInfo `bson:"availableDatasets" json:"availableDatasets"`
 	ContactFeatureConfig struct {
-		IncludeWithParticipantFlags map[string]string `bson:"includeWithParticipantFlags" json:"includeWithParticipantFlags"`
+		IncludeWithParticipantFlags map[string]string      `bson:"includeWithParticipantFlags" json:"includeWithParticipantFlags"`
+		InclusionRule               *InclusionRule         `bson:"inclusionRule,omitempty" json:"inclusionRule,omitempty"` // if set, used instead of includeWithParticipantFlags
+		ContactDataMapping          []ContactDataMapping   `bson:"contactDataMapping" json:"contactDataMapping"`           // if empty, the default mapping is used
+		StatusPipeline              []ContactStatus        `bson:"statusPipeline" json:"statusPipeline"`                   // if empty, the default pipeline is used
+		RetentionPolicy             ContactRetentionPolicy `bson:"retentionPolicy" json:"retentionPolicy"`
 	} `bson:"contactFeatureConfig" json:"contactFeatureConfig"`
+
+	Permissions []string `bson:"-" json:"permissions,omitempty"` // of the requesting user, not stored
 }
 
 type DatasetInfo struct {
@@ -30,4 +37,5 @@ type DatasetInfo struct {
 	ExcludeColumns []string `bson:"excludeColumns" json:"excludeColumns"`
 	StartDate      int64    `bson:"startDate" json:"startDate"`
 	EndDate        int64    `bson:"endDate" json:"endDate"`
+	DefaultFormat  string   `bson:"defaultFormat,omitempty" json:"defaultFormat,omitempty"` // used if the request has no format, wide format if empty
 }
@@ -0,0 +1,108 @@
+package types
+
+import "strings"
+
+// Roles a researcher can have in a substudy
+const (
+	STUDY_ROLE_VIEWER           = "viewer"
+	STUDY_ROLE_CONTACT_MANAGER  = "contact-manager"
+	STUDY_ROLE_DATA_EXPORTER    = "data-exporter"
+	STUDY_ROLE_CONTACT_EXPORTER = "contact-exporter"
+	STUDY_ROLE_OWNER            = "study-owner"
+
+	// for users in the legacy AccessControl.Emails list, cannot be assigned to members
+	STUDY_ROLE_LEGACY_MEMBER = "legacy-member"
+)
+
+// Permissions required by the substudy endpoints
+const (
+	STUDY_PERMISSION_READ_STUDY_INFO      = "study-info:read"
+	STUDY_PERMISSION_READ_CONTACTS        = "contacts:read"
+	STUDY_PERMISSION_MANAGE_CONTACTS      = "contacts:manage"
+	STUDY_PERMISSION_EXPORT_CONTACTS      = "contacts:export"
+	STUDY_PERMISSION_EXPORT_DATA          = "data:export"
+	STUDY_PERMISSION_MANAGE_NOTIFICATIONS = "notifications:manage"
+)
+
+var studyRolePermissions = map[string][]string{
+	STUDY_ROLE_VIEWER: {
+		STUDY_PERMISSION_READ_STUDY_INFO,
+	},
+	STUDY_ROLE_CONTACT_MANAGER: {
+		STUDY_PERMISSION_READ_STUDY_INFO,
+		STUDY_PERMISSION_READ_CONTACTS,
+		STUDY_PERMISSION_MANAGE_CONTACTS,
+		STUDY_PERMISSION_MANAGE_NOTIFICATIONS,
+	},
+	STUDY_ROLE_DATA_EXPORTER: {
+		STUDY_PERMISSION_READ_STUDY_INFO,
+		STUDY_PERMISSION_EXPORT_DATA,
+	},
+	STUDY_ROLE_CONTACT_EXPORTER: {
+		STUDY_PERMISSION_READ_STUDY_INFO,
+		STUDY_PERMISSION_READ_CONTACTS,
+		STUDY_PERMISSION_EXPORT_CONTACTS,
+	},
+	STUDY_ROLE_OWNER: {
+		STUDY_PERMISSION_READ_STUDY_INFO,
+		STUDY_PERMISSION_READ_CONTACTS,
+		STUDY_PERMISSION_MANAGE_CONTACTS,
+		STUDY_PERMISSION_EXPORT_CONTACTS,
+		STUDY_PERMISSION_EXPORT_DATA,
+		STUDY_PERMISSION_MANAGE_NOTIFICATIONS,
+	},
+	// what the researcher app used before roles were introduced, without the later contact export
+	STUDY_ROLE_LEGACY_MEMBER: {
+		STUDY_PERMISSION_READ_STUDY_INFO,
+		STUDY_PERMISSION_READ_CONTACTS,
+		STUDY_PERMISSION_MANAGE_CONTACTS,
+		STUDY_PERMISSION_EXPORT_DATA,
+		STUDY_PERMISSION_MANAGE_NOTIFICATIONS,
+	},
+}
+
+type StudyMember struct {
+	Email string `bson:"email" json:"email"`
+	Role  string `bson:"role" json:"role"`
+}
+
+// IsValidStudyRole tells if the role can be assigned to a member
+func IsValidStudyRole(role string) bool {
+	_, ok := studyRolePermissions[role]
+	return ok && role != STUDY_ROLE_LEGACY_MEMBER
+}
+
+// GetPermissionsForStudyRole returns the list of permissions of a role (empty for unknown roles)
+func GetPermissionsForStudyRole(role string) []string {
+	perms, ok := studyRolePermissions[role]
+	if !ok {
+		return []string{}
+	}
+	return append([]string{}, perms...)
+}
+
+func StudyRoleHasPermission(role string, permission string) bool {
+	for _, p := range studyRolePermissions[role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// GetRoleOfUser returns the role of the user in the study, or an empty string if the user is not a member.
+// Users listed in the legacy AccessControl.Emails list get the legacy member role, which keeps the access the
+// researcher app needs, but neither contact exports nor the rights of study owners.
+func (si StudyInfo) GetRoleOfUser(email string) string {
+	for _, m := range si.AccessControl.Members {
+		if strings.EqualFold(m.Email, email) {
+			return m.Role
+		}
+	}
+	for _, e := range si.AccessControl.Emails {
+		if strings.EqualFold(e, email) {
+			return STUDY_ROLE_LEGACY_MEMBER
+		}
+	}
+	return ""
+}
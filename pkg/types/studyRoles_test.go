@@ -0,0 +1,41 @@
+package types
+
+import "testing"
+
+func TestGetRoleOfUser(t *testing.T) {
+	info := StudyInfo{}
+	info.AccessControl.Members = []StudyMember{
+		{Email: "viewer@example.org", Role: STUDY_ROLE_VIEWER},
+		{Email: "both@example.org", Role: STUDY_ROLE_DATA_EXPORTER},
+	}
+	info.AccessControl.Emails = []string{"legacy@example.org", "both@example.org"}
+
+	tests := []struct {
+		email string
+		want  string
+	}{
+		{"viewer@example.org", STUDY_ROLE_VIEWER},
+		{"Legacy@Example.org", STUDY_ROLE_LEGACY_MEMBER},
+		{"both@example.org", STUDY_ROLE_DATA_EXPORTER},
+		{"other@example.org", ""},
+	}
+	for _, tt := range tests {
+		if got := info.GetRoleOfUser(tt.email); got != tt.want {
+			t.Errorf("GetRoleOfUser(%s) = %s, want %s", tt.email, got, tt.want)
+		}
+	}
+}
+
+func TestLegacyMemberRole(t *testing.T) {
+	if IsValidStudyRole(STUDY_ROLE_LEGACY_MEMBER) {
+		t.Error("legacy member role must not be assignable")
+	}
+	for _, p := range []string{STUDY_PERMISSION_READ_CONTACTS, STUDY_PERMISSION_MANAGE_CONTACTS, STUDY_PERMISSION_EXPORT_DATA, STUDY_PERMISSION_MANAGE_NOTIFICATIONS} {
+		if !StudyRoleHasPermission(STUDY_ROLE_LEGACY_MEMBER, p) {
+			t.Errorf("legacy member role should have permission %s", p)
+		}
+	}
+	if StudyRoleHasPermission(STUDY_ROLE_LEGACY_MEMBER, STUDY_PERMISSION_EXPORT_CONTACTS) {
+		t.Error("legacy member role should not export contacts")
+	}
+}
@@ -7,6 +7,9 @@ type SAMLConfig struct {
 	MetaDataURL     string `yaml:"metadata_url"`
 	SessionCertPath string `yaml:"session_cert"`
 	SessionKeyPath  string `yaml:"session_key"`
+
+	LoginFailedRedirectURL       string `yaml:"login_failed_redirect_url"`
+	AttributeForTekenradarAccess string `yaml:"attribute_for_tekenradar_access"`
 }
 
 type DBConfig struct {
@@ -16,3 +19,29 @@ type DBConfig struct {
 	MaxPoolSize     uint64
 	IdleConnTimeout int
 }
+
+type JWTConfig struct {
+	SigningKeyFiles map[string]string // key id -> path to PEM encoded private key (RSA or Ed25519)
+	ActiveKeyID     string
+	KeysRetiredAt   map[string]int64 // key id (or "hmac" for the legacy key) -> unix time the key stopped being the active key
+	GracePeriod     int              // seconds after retirement, during which tokens signed by the key are still accepted
+	HMACSecret      string           // legacy HS256 key, base64 encoded
+}
+
+type ContactExpiryWarningConfig struct {
+	WarningDays        int    // contacts expiring within this many days are listed in the digest
+	ContactURLTemplate string // link to a contact in the researcher app, with {{studyKey}} and {{contactID}} placeholders
+}
+
+type ContactDataEncryptionConfig struct {
+	KEKFiles          map[string]string // key id -> path to base64 encoded 256 bit key encryption key
+	ActiveKEKID       string            // used to wrap the data keys of new values
+	BlindIndexKeyFile string            // base64 encoded 256 bit key for the search hashes
+}
+
+type DatasetExportConfig struct {
+	Storage        string // DATASET_EXPORT_STORAGE_GRIDFS or DATASET_EXPORT_STORAGE_DISK
+	Directory      string // for disk storage
+	RetentionHours int    // how long results can be downloaded
+	JobURLTemplate string // link to the job in the researcher app, with {{studyKey}}, {{datasetKey}} and {{jobID}} placeholders
+}
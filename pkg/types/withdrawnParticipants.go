@@ -0,0 +1,11 @@
+package types
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+// WithdrawnParticipant marks a participant who withdrew, so that events submitted before the withdrawal (e.g. late
+// retries of a t0-invite) do not create a contact again. Only the hash of the participant ID is stored.
+type WithdrawnParticipant struct {
+	ID                primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	ParticipantIDHash string             `bson:"participantIDHash" json:"participantIDHash"`
+	WithdrawnAt       int64              `bson:"withdrawnAt" json:"withdrawnAt"` // latest withdrawal
+}